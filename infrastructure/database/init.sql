@@ -3,6 +3,9 @@
 -- Enable UUID extension
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
+-- Enable btree_gist so exclusion constraints can mix equality and range operators
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
 -- Users table
 CREATE TABLE users (
     id SERIAL PRIMARY KEY,
@@ -31,6 +34,11 @@ CREATE TABLE resources (
     price_per_hour DECIMAL(10,2) DEFAULT 0.00,
     amenities JSONB,
     is_active BOOLEAN DEFAULT true,
+    -- Shared resources accept overlapping bookings while their seats fit in capacity
+    shared BOOLEAN NOT NULL DEFAULT false,
+    -- Time kept free before and after every booking (e.g. setup and cleaning)
+    setup_buffer_minutes INTEGER NOT NULL DEFAULT 0 CHECK (setup_buffer_minutes >= 0),
+    teardown_buffer_minutes INTEGER NOT NULL DEFAULT 0 CHECK (teardown_buffer_minutes >= 0),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -47,22 +55,133 @@ CREATE TABLE resource_availability (
     UNIQUE(resource_id, day_of_week, start_time, end_time)
 );
 
+-- Recurring booking series (one row per recurrence rule)
+CREATE TABLE booking_series (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    resource_id INTEGER REFERENCES resources(id) ON DELETE CASCADE,
+    rule TEXT NOT NULL,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    canceled_at TIMESTAMPTZ
+);
+
+-- Booking groups (several resources booked together for one time window)
+CREATE TABLE booking_groups (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    canceled_at TIMESTAMPTZ,
+    CHECK (end_time > start_time)
+);
+
 -- Bookings table
 CREATE TABLE bookings (
     id SERIAL PRIMARY KEY,
     uuid UUID DEFAULT uuid_generate_v4() UNIQUE NOT NULL,
     user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
     resource_id INTEGER REFERENCES resources(id) ON DELETE CASCADE,
-    start_time TIMESTAMP NOT NULL,
-    end_time TIMESTAMP NOT NULL,
-    status VARCHAR(50) DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'cancelled', 'completed')),
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    -- Status values match BookingStatus in booking-service
+    status VARCHAR(50) DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'CONFIRMED', 'CHECKED_IN', 'CANCELED', 'COMPLETED')),
     total_price DECIMAL(10,2) DEFAULT 0.00,
     notes TEXT,
     metadata JSONB,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    cancelled_at TIMESTAMP,
-    cancellation_reason TEXT
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    canceled_at TIMESTAMPTZ,
+    cancellation_reason TEXT,
+    series_id INTEGER REFERENCES booking_series(id) ON DELETE SET NULL,
+    -- PENDING bookings are canceled by the hold expiry worker after expires_at
+    expires_at TIMESTAMPTZ,
+    checked_in_at TIMESTAMPTZ,
+    group_id INTEGER REFERENCES booking_groups(id) ON DELETE SET NULL,
+    -- Attendees; bookings of shared resources may overlap while their seats fit in capacity
+    seats INTEGER NOT NULL DEFAULT 1 CHECK (seats > 0),
+    shared BOOLEAN NOT NULL DEFAULT false,
+    CHECK (end_time > start_time),
+    -- Reject overlapping active bookings for the same exclusive resource at the database level
+    CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+        resource_id WITH =,
+        tstzrange(start_time, end_time, '[)') WITH &&
+    ) WHERE (status <> 'CANCELED' AND NOT shared)
+);
+
+-- Waitlist for fully booked slots
+CREATE TABLE waitlist_entries (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    resource_id INTEGER REFERENCES resources(id) ON DELETE CASCADE,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    status VARCHAR(50) DEFAULT 'WAITING' CHECK (status IN ('WAITING', 'OFFERED', 'FULFILLED', 'EXPIRED', 'CANCELED')),
+    notes TEXT,
+    seats INTEGER NOT NULL DEFAULT 1 CHECK (seats > 0),
+    booking_id INTEGER REFERENCES bookings(id) ON DELETE SET NULL,
+    offer_expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CHECK (end_time > start_time)
+);
+
+-- People invited to a booking: internal users or external email addresses
+CREATE TABLE booking_attendees (
+    id SERIAL PRIMARY KEY,
+    booking_id INTEGER NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255),
+    status VARCHAR(50) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'ACCEPTED', 'DECLINED', 'TENTATIVE')),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    responded_at TIMESTAMPTZ,
+    CHECK ((user_id IS NULL) <> (email IS NULL)),
+    UNIQUE (booking_id, user_id),
+    UNIQUE (booking_id, email)
+);
+
+-- iCalendar feeds of a user's or a resource's bookings, reached through a secret
+-- token; only its SHA-256 is stored
+CREATE TABLE booking_calendar_feeds (
+    id SERIAL PRIMARY KEY,
+    owner_type VARCHAR(20) NOT NULL CHECK (owner_type IN ('user', 'resource')),
+    owner_id INTEGER NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMPTZ
+);
+
+-- Temporary quota increases granted by admins to one user
+CREATE TABLE booking_quota_grants (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    extra_active_bookings INTEGER NOT NULL DEFAULT 0 CHECK (extra_active_bookings >= 0),
+    extra_hours_per_week NUMERIC(8, 2) NOT NULL DEFAULT 0 CHECK (extra_hours_per_week >= 0),
+    extra_hours_per_month NUMERIC(8, 2) NOT NULL DEFAULT 0 CHECK (extra_hours_per_month >= 0),
+    reason TEXT,
+    granted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Booking events, written in the same transaction as the booking change and
+-- delivered to the message broker by the booking-service outbox relay
+CREATE TABLE booking_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID UNIQUE NOT NULL,
+    booking_id INTEGER NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMPTZ
 );
 
 -- Notifications table
@@ -113,6 +232,20 @@ CREATE INDEX idx_bookings_status ON bookings(status);
 CREATE INDEX idx_bookings_start_time ON bookings(start_time);
 CREATE INDEX idx_bookings_end_time ON bookings(end_time);
 CREATE INDEX idx_bookings_uuid ON bookings(uuid);
+CREATE INDEX idx_bookings_series_id ON bookings(series_id);
+CREATE INDEX idx_bookings_group_id ON bookings(group_id);
+CREATE INDEX idx_bookings_expires_at ON bookings(expires_at) WHERE status = 'PENDING';
+
+CREATE INDEX idx_waitlist_entries_resource_status ON waitlist_entries(resource_id, status, created_at);
+CREATE INDEX idx_waitlist_entries_user_id ON waitlist_entries(user_id);
+CREATE INDEX idx_waitlist_entries_offer_expires_at ON waitlist_entries(offer_expires_at) WHERE status = 'OFFERED';
+
+CREATE INDEX idx_booking_attendees_user_id ON booking_attendees(user_id);
+CREATE INDEX idx_booking_calendar_feeds_owner ON booking_calendar_feeds(owner_type, owner_id) WHERE revoked_at IS NULL;
+CREATE INDEX idx_booking_quota_grants_user_id ON booking_quota_grants(user_id, expires_at);
+
+CREATE INDEX idx_booking_outbox_pending ON booking_outbox(id) WHERE published_at IS NULL;
+CREATE INDEX idx_booking_outbox_booking_id ON booking_outbox(booking_id);
 
 CREATE INDEX idx_notifications_user_id ON notifications(user_id);
 CREATE INDEX idx_notifications_type ON notifications(type);
@@ -142,39 +275,6 @@ CREATE TRIGGER update_resources_updated_at BEFORE UPDATE ON resources
 CREATE TRIGGER update_bookings_updated_at BEFORE UPDATE ON bookings
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
--- Function to prevent booking conflicts
-CREATE OR REPLACE FUNCTION check_booking_conflict()
-RETURNS TRIGGER AS $$
-DECLARE
-    conflict_count INTEGER;
-BEGIN
-    -- Check for overlapping bookings
-    SELECT COUNT(*)
-    INTO conflict_count
-    FROM bookings
-    WHERE resource_id = NEW.resource_id
-      AND status IN ('pending', 'confirmed')
-      AND id != COALESCE(NEW.id, 0)
-      AND (
-          (NEW.start_time >= start_time AND NEW.start_time < end_time) OR
-          (NEW.end_time > start_time AND NEW.end_time <= end_time) OR
-          (NEW.start_time <= start_time AND NEW.end_time >= end_time)
-      );
-    
-    IF conflict_count > 0 THEN
-        RAISE EXCEPTION 'Booking conflict detected for resource % between % and %', 
-            NEW.resource_id, NEW.start_time, NEW.end_time;
-    END IF;
-    
-    RETURN NEW;
-END;
-$$ LANGUAGE plpgsql;
-
--- Trigger to prevent booking conflicts
-CREATE TRIGGER check_booking_conflict_trigger
-    BEFORE INSERT OR UPDATE ON bookings
-    FOR EACH ROW EXECUTE FUNCTION check_booking_conflict();
-
 -- Audit trigger function
 CREATE OR REPLACE FUNCTION audit_trigger()
 RETURNS TRIGGER AS $$
@@ -241,5 +341,5 @@ INSERT INTO resource_availability (resource_id, day_of_week, start_time, end_tim
 
 -- Sample bookings
 INSERT INTO bookings (user_id, resource_id, start_time, end_time, status, total_price, notes) VALUES
-(2, 1, '2024-06-10 10:00:00', '2024-06-10 12:00:00', 'confirmed', 100.00, 'Team meeting'),
-(3, 2, '2024-06-11 14:00:00', '2024-06-11 15:00:00', 'pending', 30.00, 'Client call');
+(2, 1, '2024-06-10 10:00:00+00', '2024-06-10 12:00:00+00', 'CONFIRMED', 100.00, 'Team meeting'),
+(3, 2, '2024-06-11 14:00:00+00', '2024-06-11 15:00:00+00', 'PENDING', 30.00, 'Client call');
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of BookingAlternative
+const (
+	// AlternativeOtherTime is the same resource at the nearest free time
+	AlternativeOtherTime = "OTHER_TIME"
+	// AlternativeOtherResource is a similar resource at the requested time
+	AlternativeOtherResource = "OTHER_RESOURCE"
+)
+
+const (
+	// alternativeSearchRange is how far before and after the requested time the
+	// nearest free time of the same resource is looked for
+	alternativeSearchRange = 7 * 24 * time.Hour
+	// maxSimilarResources bounds the similar resources checked and suggested
+	maxSimilarResources     = 20
+	maxResourceAlternatives = 3
+)
+
+// BookingConflictError is returned when a booking or a move conflicts with other
+// bookings of its resource. It matches ErrBookingConflict with errors.Is.
+type BookingConflictError struct {
+	Conflicts    []BookingConflict
+	Alternatives []BookingAlternative
+}
+
+func (e *BookingConflictError) Error() string {
+	return ErrBookingConflict.Error()
+}
+
+func (e *BookingConflictError) Unwrap() error {
+	return ErrBookingConflict
+}
+
+// BookingAlternative is a free slot suggested instead of a conflicting booking.
+// Its resource and times can be sent back as they are to book it.
+type BookingAlternative struct {
+	Kind string `json:"kind"`
+	FreeSlot
+}
+
+// bookingConflict describes the bookings in the way of booking and suggests
+// alternatives: the nearest free times before and after it on the same resource,
+// then similar resources free at the same time. A stored booking being moved
+// keeps its resource, so it only gets other times. Suggestions are best effort;
+// when they cannot be worked out the conflicts are reported alone.
+func (s *BookingService) bookingConflict(caller Identity, booking *Booking, occupancy Occupancy) error {
+	conflictErr := &BookingConflictError{}
+
+	conflicts, err := s.conflictingBookings(booking.ResourceID, booking.StartTime, booking.EndTime, occupancy.Buffers)
+	if err == nil {
+		var blocking []*Booking
+		for _, conflict := range conflicts {
+			if conflict.ID != booking.ID {
+				blocking = append(blocking, conflict)
+			}
+		}
+		if len(blocking) > 0 {
+			conflictErr.Conflicts = toBookingConflicts(blocking, occupancy.Buffers)
+		}
+	}
+
+	if !s.config.ResourceValidation {
+		return conflictErr
+	}
+
+	resource, err := s.cachedResource(booking.ResourceID)
+	if err != nil {
+		log.Printf("Error suggesting alternatives to a booking of resource %d: %v", booking.ResourceID, err)
+		return conflictErr
+	}
+
+	for _, slot := range s.nearestFreeSlots(caller, resource, booking) {
+		conflictErr.Alternatives = append(conflictErr.Alternatives, BookingAlternative{Kind: AlternativeOtherTime, FreeSlot: slot})
+	}
+	if booking.ID != 0 {
+		return conflictErr
+	}
+	for _, slot := range s.similarFreeResources(caller, resource, booking) {
+		conflictErr.Alternatives = append(conflictErr.Alternatives, BookingAlternative{Kind: AlternativeOtherResource, FreeSlot: slot})
+	}
+
+	return conflictErr
+}
+
+// nearestFreeSlots returns the free periods of the booking's length on its
+// resource that start closest before and after it, within alternativeSearchRange
+func (s *BookingService) nearestFreeSlots(caller Identity, resource *Resource, booking *Booking) []FreeSlot {
+	var before, after *FreeSlot
+	err := s.freeSlots(caller, resource, booking.StartTime.Add(-alternativeSearchRange), booking.EndTime.Add(alternativeSearchRange),
+		booking.Duration(), booking.Seats, booking.ID, func(slot FreeSlot) bool {
+			switch {
+			case slot.StartTime.Before(booking.StartTime):
+				before = &slot
+			case slot.StartTime.After(booking.StartTime):
+				after = &slot
+				return false
+			}
+			return true
+		})
+	if err != nil {
+		log.Printf("Error looking for free times of resource %d: %v", resource.ID, err)
+	}
+
+	var slots []FreeSlot
+	if before != nil {
+		slots = append(slots, *before)
+	}
+	if after != nil {
+		slots = append(slots, *after)
+	}
+
+	// The closer one is suggested first
+	if len(slots) == 2 && slots[1].StartTime.Sub(booking.StartTime) < booking.StartTime.Sub(slots[0].StartTime) {
+		slots[0], slots[1] = slots[1], slots[0]
+	}
+
+	return slots
+}
+
+// similarFreeResources returns the resources of the same type and location as
+// resource, with room for the booking, that are free at the booking's time.
+// The smallest ones come first.
+func (s *BookingService) similarFreeResources(caller Identity, resource *Resource, booking *Booking) []FreeSlot {
+	// Shared resources need room for the booking's seats; other resources need
+	// as much capacity as the one asked for
+	minCapacity := resource.Capacity
+	if resource.Shared {
+		minCapacity = booking.Seats
+	}
+
+	listed, err := s.resources.ListResources(ResourceQuery{
+		Type:        resource.Type,
+		Location:    resource.Location,
+		MinCapacity: minCapacity,
+	})
+	if err != nil {
+		log.Printf("Error listing resources similar to resource %d: %v", resource.ID, err)
+		return nil
+	}
+
+	var candidates []*Resource
+	seen := map[int]bool{resource.ID: true}
+	for _, candidate := range listed {
+		if seen[candidate.ID] || !candidate.IsActive || candidate.Capacity < minCapacity ||
+			!strings.EqualFold(candidate.Location, resource.Location) {
+			continue
+		}
+		seen[candidate.ID] = true
+		s.resourceCache.Set(candidate.ID, candidate)
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Capacity != candidates[j].Capacity {
+			return candidates[i].Capacity < candidates[j].Capacity
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	if len(candidates) > maxSimilarResources {
+		candidates = candidates[:maxSimilarResources]
+	}
+
+	found := make([]*FreeSlot, len(candidates))
+	forEachResource(candidates, func(i int, candidate *Resource) {
+		slot, err := s.freeAt(caller, candidate, booking.StartTime, booking.EndTime, booking.Seats)
+		if err != nil {
+			log.Printf("Error checking availability of resource %d: %v", candidate.ID, err)
+		}
+		found[i] = slot
+	})
+
+	var slots []FreeSlot
+	for _, slot := range found {
+		if slot != nil && len(slots) < maxResourceAlternatives {
+			slots = append(slots, *slot)
+		}
+	}
+
+	return slots
+}
+
+// freeAt returns the free slot of the resource from start to end, or nil when the
+// resource is closed, taken or off limits for the caller at that time
+func (s *BookingService) freeAt(caller Identity, resource *Resource, start, end time.Time, seats int) (*FreeSlot, error) {
+	if len(s.policies.Evaluate(resource.ID, resource.Type, caller.Role, start, end, time.Now())) > 0 {
+		return nil, nil
+	}
+
+	windows, err := s.resources.GetOpeningWindows(resource.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if !coversPeriod(windows, start, end) {
+		return nil, nil
+	}
+
+	occupancy := resource.Occupancy()
+	conflicts, err := s.conflictingBookings(resource.ID, start, end, occupancy.Buffers)
+	if err != nil {
+		return nil, err
+	}
+	if !occupancy.Fits(&Booking{ResourceID: resource.ID, StartTime: start, EndTime: end, Seats: seats}, conflicts) {
+		return nil, nil
+	}
+
+	slot := newFreeSlot(resource, start, end, occupancy.RemainingSeats(conflicts, start, end))
+	return &slot, nil
+}
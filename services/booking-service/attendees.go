@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidAttendee is returned for attendees without exactly one valid user ID or email
+	ErrInvalidAttendee = errors.New("an attendee needs either a user ID or a valid email")
+	// ErrAttendeeExists is returned when inviting someone already invited to the booking
+	ErrAttendeeExists = errors.New("attendee is already invited to this booking")
+	// ErrAttendeeNotFound is returned for attendees that are not invited to the booking
+	ErrAttendeeNotFound = fmt.Errorf("attendee %w", ErrNotFound)
+	// ErrInvalidRSVP is returned for responses other than accepted, declined or tentative
+	ErrInvalidRSVP = errors.New("status must be ACCEPTED, DECLINED or TENTATIVE")
+)
+
+// RSVPStatus is an attendee's answer to an invitation
+type RSVPStatus string
+
+const (
+	RSVPPending   RSVPStatus = "PENDING"
+	RSVPAccepted  RSVPStatus = "ACCEPTED"
+	RSVPDeclined  RSVPStatus = "DECLINED"
+	RSVPTentative RSVPStatus = "TENTATIVE"
+)
+
+// IsResponse reports whether the status is an answer an attendee can give
+func (s RSVPStatus) IsResponse() bool {
+	return s == RSVPAccepted || s == RSVPDeclined || s == RSVPTentative
+}
+
+// Attendee is someone invited to a booking: an internal user or an external email
+type Attendee struct {
+	ID          int        `json:"id" db:"id"`
+	BookingID   int        `json:"booking_id" db:"booking_id"`
+	UserID      *int       `json:"user_id,omitempty" db:"user_id"`
+	Email       string     `json:"email,omitempty" db:"email"`
+	Status      RSVPStatus `json:"status" db:"status"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty" db:"responded_at"`
+}
+
+// IsUser reports whether the attendee is the given internal user
+func (a *Attendee) IsUser(userID int) bool {
+	return a.UserID != nil && *a.UserID == userID
+}
+
+// AddAttendeeRequest invites an internal user (UserID) or an external guest (Email)
+type AddAttendeeRequest struct {
+	UserID int    `json:"user_id,omitempty"`
+	Email  string `json:"email,omitempty" validate:"omitempty,email"`
+}
+
+// RSVPRequest is an attendee's response to an invitation
+type RSVPRequest struct {
+	Status RSVPStatus `json:"status" validate:"required"`
+}
+
+// ListAttendees returns the attendees of a booking in the order they were invited
+func (s *BookingService) ListAttendees(bookingID int) ([]*Attendee, error) {
+	attendees, err := s.repository.GetAttendees(bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attendees: %w", err)
+	}
+
+	return attendees, nil
+}
+
+// AddAttendee invites a user or an email address to a booking. Internal users
+// must exist in user-service.
+func (s *BookingService) AddAttendee(bookingID int, req AddAttendeeRequest) (*Attendee, error) {
+	booking, err := s.repository.GetByID(bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("booking not found: %w", err)
+	}
+
+	if !booking.CanBeModified() {
+		return nil, fmt.Errorf("booking cannot be modified in its current state: %s", booking.Status)
+	}
+
+	now := time.Now()
+	attendee := &Attendee{
+		BookingID: booking.ID,
+		Status:    RSVPPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	switch {
+	case req.UserID > 0 && email == "":
+		if req.UserID == booking.UserID {
+			return nil, fmt.Errorf("%w: the owner cannot be invited", ErrInvalidAttendee)
+		}
+		if _, err := s.users.GetUser(req.UserID); err != nil {
+			return nil, err
+		}
+		userID := req.UserID
+		attendee.UserID = &userID
+	case req.UserID == 0 && email != "":
+		address, err := mail.ParseAddress(email)
+		if err != nil || address.Address != email {
+			return nil, ErrInvalidAttendee
+		}
+		attendee.Email = email
+	default:
+		return nil, ErrInvalidAttendee
+	}
+
+	if err := s.repository.AddAttendee(booking, attendee, BookingEventAttendeeAdded); err != nil {
+		if errors.Is(err, ErrAttendeeExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to add attendee: %w", err)
+	}
+
+	return attendee, nil
+}
+
+// RemoveAttendee withdraws an invitation
+func (s *BookingService) RemoveAttendee(bookingID, attendeeID int) error {
+	booking, attendee, err := s.attendee(bookingID, attendeeID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.RemoveAttendee(booking, attendee, BookingEventAttendeeRemoved); err != nil {
+		return fmt.Errorf("failed to remove attendee: %w", err)
+	}
+
+	return nil
+}
+
+// RespondToInvitation records an attendee's answer. Bookings that are over or
+// canceled no longer take responses.
+func (s *BookingService) RespondToInvitation(bookingID, attendeeID int, status RSVPStatus) (*Attendee, error) {
+	if !status.IsResponse() {
+		return nil, ErrInvalidRSVP
+	}
+
+	booking, attendee, err := s.attendee(bookingID, attendeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !booking.CanBeModified() {
+		return nil, fmt.Errorf("booking cannot be modified in its current state: %s", booking.Status)
+	}
+
+	now := time.Now()
+	attendee.Status = status
+	attendee.RespondedAt = &now
+	attendee.UpdatedAt = now
+
+	if err := s.repository.UpdateAttendee(booking, attendee, BookingEventAttendeeResponded); err != nil {
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+
+	return attendee, nil
+}
+
+// attendee loads a booking and one of its attendees
+func (s *BookingService) attendee(bookingID, attendeeID int) (*Booking, *Attendee, error) {
+	booking, err := s.repository.GetByID(bookingID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("booking not found: %w", err)
+	}
+
+	attendees, err := s.repository.GetAttendees(bookingID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get attendees: %w", err)
+	}
+
+	for _, attendee := range attendees {
+		if attendee.ID == attendeeID {
+			return booking, attendee, nil
+		}
+	}
+
+	return nil, nil, ErrAttendeeNotFound
+}
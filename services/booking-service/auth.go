@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Roles issued by user-service
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+var (
+	// ErrUnauthenticated is returned for requests without a valid bearer token
+	ErrUnauthenticated = errors.New("missing or invalid bearer token")
+	// ErrMissingJWTSecret is returned when no secret to verify tokens is configured
+	ErrMissingJWTSecret = errors.New("JWT_SECRET is not set")
+)
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	UserID int
+	Role   string
+}
+
+type identityKey struct{}
+
+// tokenClaims mirrors the access token claims issued by user-service
+type tokenClaims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// Authenticator verifies the HS256 access tokens issued by user-service
+type Authenticator struct {
+	secret []byte
+}
+
+// NewAuthenticator returns ErrMissingJWTSecret for an empty secret, so the
+// service never accepts tokens signed with a well-known key
+func NewAuthenticator(secret string) (*Authenticator, error) {
+	if secret == "" {
+		return nil, ErrMissingJWTSecret
+	}
+
+	return &Authenticator{secret: []byte(secret)}, nil
+}
+
+// Authenticate parses an "Authorization: Bearer <token>" header value
+func (a *Authenticator) Authenticate(header string) (Identity, error) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return Identity{}, ErrUnauthenticated
+	}
+
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return a.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return Identity{}, ErrUnauthenticated
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID <= 0 {
+		return Identity{}, ErrUnauthenticated
+	}
+
+	return Identity{UserID: userID, Role: claims.Role}, nil
+}
+
+// Middleware rejects unauthenticated requests with 401 and stores the caller's
+// identity in the request context
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := a.Authenticate(r.Header.Get("Authorization"))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="booking-service"`)
+			writeError(w, http.StatusUnauthorized, ErrorCodeUnauthorized, err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+	})
+}
+
+// requestIdentity returns the caller set by the authentication middleware
+func requestIdentity(r *http.Request) Identity {
+	identity, _ := r.Context().Value(identityKey{}).(Identity)
+	return identity
+}
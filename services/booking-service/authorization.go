@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// RoleManager may act on every booking, like RoleAdmin
+const RoleManager = "manager"
+
+// AccessDeniedError is returned when the caller may not perform an operation.
+// Code is the machine-readable reason sent with the 403 response.
+type AccessDeniedError struct {
+	Code    string
+	Message string
+}
+
+func (e *AccessDeniedError) Error() string {
+	return e.Message
+}
+
+// IsElevated reports whether the caller may act on other users' bookings
+func (i Identity) IsElevated() bool {
+	return i.Role == RoleAdmin || i.Role == RoleManager
+}
+
+// requireElevated allows admins and managers only
+func requireElevated(identity Identity) error {
+	if identity.IsElevated() {
+		return nil
+	}
+	return &AccessDeniedError{
+		Code:    ErrorCodeElevatedRoleRequired,
+		Message: "this operation requires the admin or manager role",
+	}
+}
+
+// requireAdmin allows admins only
+func requireAdmin(identity Identity) error {
+	if identity.Role == RoleAdmin {
+		return nil
+	}
+	return &AccessDeniedError{
+		Code:    ErrorCodeAdminRoleRequired,
+		Message: "this operation requires the admin role",
+	}
+}
+
+// requireOwner allows the user who owns a record, admins and managers
+func requireOwner(identity Identity, ownerID int) error {
+	if identity.UserID == ownerID || identity.IsElevated() {
+		return nil
+	}
+	return &AccessDeniedError{
+		Code:    ErrorCodeNotOwner,
+		Message: "only the owner, an admin or a manager can access this",
+	}
+}
+
+// AuthorizeBooking checks that the caller owns the booking or has an elevated role
+func (s *BookingService) AuthorizeBooking(identity Identity, id int) error {
+	booking, err := s.repository.GetByID(id)
+	if err != nil {
+		return fmt.Errorf("booking not found: %w", err)
+	}
+
+	return requireOwner(identity, booking.UserID)
+}
+
+// AuthorizeSeries checks that the caller owns the series or has an elevated role
+func (s *BookingService) AuthorizeSeries(identity Identity, id int) error {
+	series, err := s.repository.GetSeries(id)
+	if err != nil {
+		return fmt.Errorf("booking series not found: %w", err)
+	}
+
+	return requireOwner(identity, series.UserID)
+}
+
+// AuthorizeAttendees checks that the caller owns the booking, is invited to it or
+// has an elevated role
+func (s *BookingService) AuthorizeAttendees(identity Identity, bookingID int) error {
+	booking, err := s.repository.GetByID(bookingID)
+	if err != nil {
+		return fmt.Errorf("booking not found: %w", err)
+	}
+
+	attendees, err := s.repository.GetAttendees(bookingID)
+	if err != nil {
+		return err
+	}
+	for _, attendee := range attendees {
+		if attendee.IsUser(identity.UserID) {
+			return nil
+		}
+	}
+
+	return requireOwner(identity, booking.UserID)
+}
+
+// AuthorizeAttendee checks that the caller is the attendee, owns the booking or
+// has an elevated role
+func (s *BookingService) AuthorizeAttendee(identity Identity, bookingID, attendeeID int) error {
+	booking, attendee, err := s.attendee(bookingID, attendeeID)
+	if err != nil {
+		return err
+	}
+
+	if attendee.IsUser(identity.UserID) {
+		return nil
+	}
+	return requireOwner(identity, booking.UserID)
+}
+
+// AuthorizeRSVP checks that the caller may answer an invitation. Internal users
+// answer for themselves, with admins as the only exception; the owner, admins and
+// managers record the answers of guests invited by email.
+func (s *BookingService) AuthorizeRSVP(identity Identity, bookingID, attendeeID int) error {
+	booking, attendee, err := s.attendee(bookingID, attendeeID)
+	if err != nil {
+		return err
+	}
+
+	if attendee.UserID == nil {
+		return requireOwner(identity, booking.UserID)
+	}
+	if attendee.IsUser(identity.UserID) || identity.Role == RoleAdmin {
+		return nil
+	}
+	return &AccessDeniedError{
+		Code:    ErrorCodeNotOwner,
+		Message: "only the invited user or an admin can answer this invitation",
+	}
+}
+
+// AuthorizeGroup checks that the caller owns the booking group or has an elevated role
+func (s *BookingService) AuthorizeGroup(identity Identity, id int) error {
+	group, err := s.repository.GetGroup(id)
+	if err != nil {
+		return fmt.Errorf("booking group not found: %w", err)
+	}
+
+	return requireOwner(identity, group.UserID)
+}
+
+// AuthorizeWaitlistEntry checks that the caller owns the entry or has an elevated role
+func (s *BookingService) AuthorizeWaitlistEntry(identity Identity, id int) error {
+	entry, err := s.repository.GetWaitlistEntry(id)
+	if err != nil {
+		return fmt.Errorf("waitlist entry not found: %w", err)
+	}
+
+	return requireOwner(identity, entry.UserID)
+}
+
+// AuthorizeWaitlistOffer checks that the caller is the user waiting on the entry.
+// An offer is theirs to accept, so admins and managers are denied as well.
+func (s *BookingService) AuthorizeWaitlistOffer(identity Identity, id int) error {
+	entry, err := s.repository.GetWaitlistEntry(id)
+	if err != nil {
+		return fmt.Errorf("waitlist entry not found: %w", err)
+	}
+
+	if identity.UserID == entry.UserID {
+		return nil
+	}
+	return &AccessDeniedError{
+		Code:    ErrorCodeNotOwner,
+		Message: "only the waiting user can accept this offer",
+	}
+}
+
+// scopeToCaller restricts a user filter to the caller unless they have an
+// elevated role. An explicit filter for another user is denied.
+func scopeToCaller(identity Identity, userID int) (int, error) {
+	if identity.IsElevated() {
+		return userID, nil
+	}
+	if userID != 0 && userID != identity.UserID {
+		return 0, requireOwner(identity, userID)
+	}
+	return identity.UserID, nil
+}
+
+// authorized writes the response for a failed authorization check and reports
+// whether the request may proceed. Unknown records are answered with 404 and
+// failures to look them up with 500.
+func authorized(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return true
+	}
+
+	var denied *AccessDeniedError
+	switch {
+	case errors.As(err, &denied):
+		writeError(w, http.StatusForbidden, denied.Code, denied.Message)
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("Error authorizing request: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
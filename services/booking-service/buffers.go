@@ -0,0 +1,28 @@
+package main
+
+import "time"
+
+// Buffers is the time a resource is kept free before and after each booking,
+// e.g. to set up a room and clean it afterwards
+type Buffers struct {
+	Before time.Duration
+	After  time.Duration
+}
+
+// Gap is the minimum free time between two bookings of the resource: the
+// teardown after the earlier one plus the setup before the later one
+func (b Buffers) Gap() time.Duration {
+	return b.Before + b.After
+}
+
+// Block returns the period a booking from start to end makes the resource unavailable
+func (b Buffers) Block(start, end time.Time) (time.Time, time.Time) {
+	return start.Add(-b.Before), end.Add(b.After)
+}
+
+// conflictingBookings returns the active bookings whose buffered block overlaps
+// the buffered block of a booking from start to end
+func (s *BookingService) conflictingBookings(resourceID int, start, end time.Time, buffers Buffers) ([]*Booking, error) {
+	gap := buffers.Gap()
+	return s.repository.GetConflictingBookings(resourceID, start.Add(-gap), end.Add(gap))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds the memory used by a ttlCache
+const maxCacheEntries = 10000
+
+// ttlCache is a small concurrency-safe cache whose entries expire after a fixed TTL.
+// A nil value is a valid entry, used to remember lookups that found nothing.
+type ttlCache[V any] struct {
+	ttl     time.Duration
+	entries map[int]cacheEntry[V]
+	mutex   sync.Mutex
+}
+
+type cacheEntry[V any] struct {
+	value     *V
+	expiresAt time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{
+		ttl:     ttl,
+		entries: make(map[int]cacheEntry[V]),
+	}
+}
+
+// Get returns the cached value and whether a live entry exists
+func (c *ttlCache[V]) Get(key int) (*V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return entry.value, true
+}
+
+// Set stores a value until the TTL elapses
+func (c *ttlCache[V]) Set(key int, value *V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxCacheEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		// Everything is still live: start over rather than grow without bound
+		if len(c.entries) >= maxCacheEntries {
+			c.entries = make(map[int]cacheEntry[V])
+		}
+	}
+
+	c.entries[key] = cacheEntry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
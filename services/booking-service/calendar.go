@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrCalendarFeedNotFound is returned for unknown or revoked feed tokens
+var ErrCalendarFeedNotFound = errors.New("calendar feed not found")
+
+// calendarFeedBatch is how many bookings a feed reads from the repository at a time
+const calendarFeedBatch = 500
+
+// CalendarFeedOwner is what a calendar feed lists the bookings of
+type CalendarFeedOwner string
+
+const (
+	CalendarFeedUser     CalendarFeedOwner = "user"
+	CalendarFeedResource CalendarFeedOwner = "resource"
+)
+
+// CalendarFeed is a subscribable iCalendar feed of a user's or a resource's
+// bookings. Only the SHA-256 of its token is stored.
+type CalendarFeed struct {
+	ID        int               `json:"id" db:"id"`
+	OwnerType CalendarFeedOwner `json:"owner_type" db:"owner_type"`
+	OwnerID   int               `json:"owner_id" db:"owner_id"`
+	TokenHash string            `json:"-" db:"token_hash"`
+	CreatedBy int               `json:"created_by" db:"created_by"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	RevokedAt *time.Time        `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// CalendarFeedToken is returned once when a feed is created; the token cannot be read again
+type CalendarFeedToken struct {
+	CalendarFeed
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// CreateCalendarFeed issues a new feed token for a user or resource and revokes
+// the previous one, so a leaked URL is disabled by creating a new feed
+func (s *BookingService) CreateCalendarFeed(caller Identity, ownerType CalendarFeedOwner, ownerID int) (*CalendarFeedToken, error) {
+	switch ownerType {
+	case CalendarFeedUser:
+		if _, err := s.users.GetUser(ownerID); err != nil {
+			return nil, err
+		}
+	case CalendarFeedResource:
+		if s.config.ResourceValidation {
+			if _, err := s.cachedResource(ownerID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate calendar feed token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	feed := &CalendarFeed{
+		OwnerType: ownerType,
+		OwnerID:   ownerID,
+		TokenHash: hashFeedToken(token),
+		CreatedBy: caller.UserID,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repository.CreateCalendarFeed(feed); err != nil {
+		return nil, fmt.Errorf("failed to create calendar feed: %w", err)
+	}
+
+	return &CalendarFeedToken{
+		CalendarFeed: *feed,
+		Token:        token,
+		URL:          s.config.CalendarFeedBaseURL + "/api/v1/calendar/" + token + ".ics",
+	}, nil
+}
+
+// RevokeCalendarFeed disables the feed of a user or resource
+func (s *BookingService) RevokeCalendarFeed(ownerType CalendarFeedOwner, ownerID int) error {
+	if err := s.repository.RevokeCalendarFeeds(ownerType, ownerID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke calendar feed: %w", err)
+	}
+
+	return nil
+}
+
+// WriteCalendarFeed writes the bookings of the feed behind token as an iCalendar
+// (RFC 5545) document. Bookings that started more than CalendarFeedPastDays ago
+// are left out; canceled bookings stay in as cancelled events so subscribed
+// calendars drop them.
+func (s *BookingService) WriteCalendarFeed(w io.Writer, token string) error {
+	feed, err := s.repository.GetCalendarFeed(hashFeedToken(token))
+	if err != nil {
+		return err
+	}
+
+	query := ListBookingsQuery{StartDate: time.Now().AddDate(0, 0, -s.config.CalendarFeedPastDays)}
+	name := fmt.Sprintf("Reservas del recurso %d", feed.OwnerID)
+	switch feed.OwnerType {
+	case CalendarFeedUser:
+		query.UserID = feed.OwnerID
+		query.IncludeAttending = true
+		name = "Mis reservas"
+	case CalendarFeedResource:
+		query.ResourceID = feed.OwnerID
+	}
+
+	var bookings []*Booking
+	for offset := 0; ; offset += calendarFeedBatch {
+		batch, err := s.repository.List(query, calendarFeedBatch, offset)
+		if err != nil {
+			return fmt.Errorf("failed to list bookings: %w", err)
+		}
+		bookings = append(bookings, batch...)
+		if len(batch) < calendarFeedBatch {
+			break
+		}
+	}
+
+	var resourceIDs []int
+	seen := make(map[int]bool)
+	for _, booking := range bookings {
+		if !seen[booking.ResourceID] {
+			seen[booking.ResourceID] = true
+			resourceIDs = append(resourceIDs, booking.ResourceID)
+		}
+	}
+	resources := lookupAll(resourceIDs, s.resourceCache, s.resources.GetResource)
+	if resource := resources[feed.OwnerID]; feed.OwnerType == CalendarFeedResource && resource != nil {
+		name = resource.Name
+	}
+
+	cal := &icalWriter{w: w}
+	cal.line("BEGIN", "VCALENDAR")
+	cal.line("VERSION", "2.0")
+	cal.line("PRODID", "-//Reservas//booking-service//ES")
+	cal.line("CALSCALE", "GREGORIAN")
+	cal.line("METHOD", "PUBLISH")
+	cal.text("X-WR-CALNAME", name)
+	for _, booking := range bookings {
+		s.writeEvent(cal, booking, resources[booking.ResourceID])
+	}
+	cal.line("END", "VCALENDAR")
+
+	return cal.err
+}
+
+// writeEvent writes one booking as a VEVENT. The UID only depends on the booking
+// ID, and SEQUENCE grows with every change, so calendar clients update the event
+// they already have instead of adding a new one.
+func (s *BookingService) writeEvent(cal *icalWriter, booking *Booking, resource *Resource) {
+	summary := fmt.Sprintf("Reserva del recurso %d", booking.ResourceID)
+	if resource != nil {
+		summary = resource.Name
+	}
+
+	cal.line("BEGIN", "VEVENT")
+	cal.line("UID", fmt.Sprintf("booking-%d@%s", booking.ID, s.config.CalendarUIDDomain))
+	cal.line("DTSTAMP", icalTime(booking.UpdatedAt))
+	cal.line("CREATED", icalTime(booking.CreatedAt))
+	cal.line("LAST-MODIFIED", icalTime(booking.UpdatedAt))
+	cal.line("SEQUENCE", fmt.Sprint(int64(booking.UpdatedAt.Sub(booking.CreatedAt)/time.Second)))
+	cal.line("DTSTART", icalTime(booking.StartTime))
+	cal.line("DTEND", icalTime(booking.EndTime))
+	cal.text("SUMMARY", summary)
+	if resource != nil && resource.Location != "" {
+		cal.text("LOCATION", resource.Location)
+	}
+	if booking.Notes != "" {
+		cal.text("DESCRIPTION", booking.Notes)
+	}
+	cal.line("STATUS", icalStatus(booking.Status))
+	cal.line("END", "VEVENT")
+}
+
+// icalStatus maps a booking status to a VEVENT STATUS
+func icalStatus(status BookingStatus) string {
+	switch status {
+	case BookingStatusPending:
+		return "TENTATIVE"
+	case BookingStatusCanceled:
+		return "CANCELLED"
+	default:
+		return "CONFIRMED"
+	}
+}
+
+// icalTime formats a time as an RFC 5545 UTC DATE-TIME
+func icalTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// hashFeedToken returns the stored form of a feed token
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// icalWriter writes RFC 5545 content lines, folded at 75 octets and ended by
+// CRLF. The first write error is kept and later writes are skipped.
+type icalWriter struct {
+	w   io.Writer
+	err error
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// text writes a property whose value is TEXT, escaping special characters
+func (c *icalWriter) text(name, value string) {
+	c.line(name, icalEscaper.Replace(value))
+}
+
+func (c *icalWriter) line(name, value string) {
+	if c.err != nil {
+		return
+	}
+
+	line := name + ":" + value
+	var folded strings.Builder
+	// Continuation lines start with a space, leaving 74 octets of content
+	for limit := 75; len(line) > limit; limit = 74 {
+		// Never split a multi-byte UTF-8 character
+		cut := limit
+		for line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		folded.WriteString(line[:cut])
+		folded.WriteString("\r\n ")
+		line = line[cut:]
+	}
+	folded.WriteString(line)
+	folded.WriteString("\r\n")
+
+	_, c.err = io.WriteString(c.w, folded.String())
+}
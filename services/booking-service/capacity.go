@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrSeatsExceedCapacity is returned when a booking asks for more seats than the resource has
+var ErrSeatsExceedCapacity = errors.New("requested seats exceed the resource capacity")
+
+// Occupancy describes how bookings of a resource may overlap. Bookings of a shared
+// resource may overlap while their seats fit in Capacity at every moment; any other
+// resource takes one booking at a time. The buffers are kept free around every booking.
+type Occupancy struct {
+	Buffers
+	Shared   bool
+	Capacity int
+}
+
+// Fits reports whether the booking can be added next to the existing bookings
+// that lie within the buffer gap of it
+func (o Occupancy) Fits(booking *Booking, existing []*Booking) bool {
+	if !o.Shared {
+		return len(existing) == 0
+	}
+
+	gap := o.Gap()
+	return peakSeats(existing, booking.StartTime.Add(-gap), booking.EndTime.Add(gap))+booking.Seats <= o.Capacity
+}
+
+// RemainingSeats returns how many seats stay free during the whole period from
+// start to end next to the existing bookings. An exclusive resource has one
+// seat, free only if no booking is in the way.
+func (o Occupancy) RemainingSeats(existing []*Booking, start, end time.Time) int {
+	if !o.Shared {
+		if len(existing) > 0 {
+			return 0
+		}
+		return 1
+	}
+
+	gap := o.Gap()
+	return max(o.Capacity-peakSeats(existing, start.Add(-gap), end.Add(gap)), 0)
+}
+
+// peakSeats returns the most seats booked at the same moment between start and end
+func peakSeats(bookings []*Booking, start, end time.Time) int {
+	type change struct {
+		at    time.Time
+		seats int
+	}
+
+	changes := make([]change, 0, 2*len(bookings))
+	for _, booking := range bookings {
+		from, to := booking.StartTime, booking.EndTime
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if from.Before(to) {
+			changes = append(changes, change{from, booking.Seats}, change{to, -booking.Seats})
+		}
+	}
+
+	// A booking ending when another starts frees its seats first
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].at.Equal(changes[j].at) {
+			return changes[i].at.Before(changes[j].at)
+		}
+		return changes[i].seats < changes[j].seats
+	})
+
+	peak, seats := 0, 0
+	for _, change := range changes {
+		seats += change.seats
+		peak = max(peak, seats)
+	}
+
+	return peak
+}
+
+// occupancy returns the buffers, sharing and capacity configured on a resource.
+// Without resource validation every resource is exclusive and has no buffers.
+func (s *BookingService) occupancy(resourceID int) (Occupancy, error) {
+	if !s.config.ResourceValidation {
+		return Occupancy{}, nil
+	}
+
+	resource, err := s.cachedResource(resourceID)
+	if err != nil {
+		return Occupancy{}, err
+	}
+
+	return resource.Occupancy(), nil
+}
+
+// seats returns the seat count of a booking of the resource, one when none is
+// requested. Shared resources cannot be booked beyond their capacity.
+func seats(requested int, occupancy Occupancy) (int, error) {
+	if requested <= 0 {
+		requested = 1
+	}
+	if occupancy.Shared && requested > occupancy.Capacity {
+		return 0, fmt.Errorf("%w: %d seats requested, %d available", ErrSeatsExceedCapacity, requested, occupancy.Capacity)
+	}
+
+	return requested, nil
+}
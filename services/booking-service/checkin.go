@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrCheckInClosed is returned when checking in outside the check-in window
+	ErrCheckInClosed = errors.New("check-in is not open for this booking")
+	// ErrInvalidCheckInToken is returned for missing, forged or expired check-in tokens
+	ErrInvalidCheckInToken = errors.New("invalid or expired check-in token")
+)
+
+// CheckIn marks a CONFIRMED booking as in use. It is accepted from
+// CheckInWindowBefore before StartTime until CheckInGracePeriod after it.
+func (s *BookingService) CheckIn(id int, token string) (*Booking, error) {
+	booking, err := s.repository.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("booking not found: %w", err)
+	}
+
+	now := time.Now()
+	if token != "" || s.config.CheckInRequireToken {
+		if err := s.verifyCheckInToken(booking.ID, token, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if !booking.IsValidTransition(BookingStatusCheckedIn) {
+		return nil, fmt.Errorf("%w: booking cannot be checked in in its current state: %s", ErrInvalidTransition, booking.Status)
+	}
+
+	if !s.checkInOpen(booking, now) {
+		return nil, ErrCheckInClosed
+	}
+
+	booking.Status = BookingStatusCheckedIn
+	booking.CheckedInAt = &now
+	booking.UpdatedAt = now
+
+	// Fails if the no-show worker released the booking in the meantime
+	if err := s.repository.UpdateIfStatus(booking, BookingStatusConfirmed, BookingEventCheckedIn); err != nil {
+		if errors.Is(err, ErrBookingChanged) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to check in booking: %w", err)
+	}
+
+	return booking, nil
+}
+
+// IssueCheckInToken signs a short-lived token for a booking whose check-in window is open
+func (s *BookingService) IssueCheckInToken(id int) (*CheckInToken, error) {
+	booking, err := s.repository.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("booking not found: %w", err)
+	}
+
+	now := time.Now()
+	if booking.Status != BookingStatusConfirmed || !s.checkInOpen(booking, now) {
+		return nil, ErrCheckInClosed
+	}
+
+	expiresAt := now.Add(s.config.CheckInTokenTTL).Truncate(time.Second)
+	payload := fmt.Sprintf("%d.%d", booking.ID, expiresAt.Unix())
+
+	return &CheckInToken{
+		BookingID: booking.ID,
+		Token:     payload + "." + s.signCheckIn(payload),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// ReleaseNoShows cancels CONFIRMED bookings nobody checked in to before the grace
+// period ended, which frees the rest of their slot. Bookings that already ended
+// have no slot left to free and are completed instead.
+func (s *BookingService) ReleaseNoShows(now time.Time) error {
+	bookings, err := s.repository.GetNoShows(now.Add(-s.config.CheckInGracePeriod), now)
+	if err != nil {
+		return fmt.Errorf("failed to get no-show bookings: %w", err)
+	}
+
+	for _, booking := range bookings {
+		// A late check-in or another replica wins silently
+		if err := s.cancel(booking, CancellationReasonNoShow); err != nil && !errors.Is(err, ErrBookingChanged) {
+			log.Printf("Error releasing no-show booking %d: %v", booking.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// RunNoShowWorker periodically releases no-show bookings until the context is done
+func (s *BookingService) RunNoShowWorker(ctx context.Context) {
+	if !s.config.NoShowRelease {
+		return
+	}
+	runPeriodically(ctx, "No-show", s.config.NoShowSweepInterval, s.ReleaseNoShows)
+}
+
+// checkInOpen reports whether now falls inside the booking's check-in window
+func (s *BookingService) checkInOpen(booking *Booking, now time.Time) bool {
+	opensAt := booking.StartTime.Add(-s.config.CheckInWindowBefore)
+	closesAt := booking.StartTime.Add(s.config.CheckInGracePeriod)
+	return !now.Before(opensAt) && now.Before(closesAt)
+}
+
+// verifyCheckInToken checks a "<booking id>.<expiry unix>.<signature>" token
+func (s *BookingService) verifyCheckInToken(bookingID int, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidCheckInToken
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(s.signCheckIn(payload))) {
+		return ErrInvalidCheckInToken
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id != bookingID {
+		return ErrInvalidCheckInToken
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || !now.Before(time.Unix(expiresAt, 0)) {
+		return ErrInvalidCheckInToken
+	}
+
+	return nil
+}
+
+// signCheckIn returns the base64url HMAC-SHA256 signature of a token payload
+func (s *BookingService) signCheckIn(payload string) string {
+	mac := hmac.New(sha256.New, s.checkInSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkInSecret returns the configured token secret, or a random one when none is
+// set. Random secrets only work with a single replica and do not survive restarts.
+func checkInSecret(config Config) []byte {
+	if config.CheckInTokenSecret != "" {
+		return []byte(config.CheckInTokenSecret)
+	}
+
+	log.Println("CHECKIN_TOKEN_SECRET is not set; using a random secret for check-in tokens")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("Failed to generate check-in token secret: %v", err)
+	}
+	return secret
+}
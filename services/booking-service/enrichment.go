@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// maxConcurrentLookups bounds parallel calls to another service per request
+const maxConcurrentLookups = 8
+
+// enrich attaches user and resource details to bookings. Each distinct user and
+// resource is looked up once per call and cached briefly across calls. Details
+// that cannot be resolved are left empty and listed in MissingDetails, so a
+// dependency outage degrades the response instead of failing it.
+func (s *BookingService) enrich(bookings []*Booking) []*BookingWithDetails {
+	var userIDs, resourceIDs []int
+	seenUsers := make(map[int]bool)
+	seenResources := make(map[int]bool)
+	for _, booking := range bookings {
+		if !seenUsers[booking.UserID] {
+			seenUsers[booking.UserID] = true
+			userIDs = append(userIDs, booking.UserID)
+		}
+		if !seenResources[booking.ResourceID] {
+			seenResources[booking.ResourceID] = true
+			resourceIDs = append(resourceIDs, booking.ResourceID)
+		}
+	}
+
+	var users map[int]*User
+	var resources map[int]*Resource
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		users = lookupAll(userIDs, s.userCache, s.users.GetUser)
+	}()
+	go func() {
+		defer wg.Done()
+		resources = lookupAll(resourceIDs, s.resourceCache, s.resources.GetResource)
+	}()
+	wg.Wait()
+
+	enriched := make([]*BookingWithDetails, 0, len(bookings))
+	for _, booking := range bookings {
+		details := &BookingWithDetails{Booking: *booking}
+
+		if user := users[booking.UserID]; user != nil {
+			details.UserName = user.Name
+			details.UserEmail = user.Email
+		} else {
+			details.MissingDetails = append(details.MissingDetails, "user_name", "user_email")
+		}
+
+		if resource := resources[booking.ResourceID]; resource != nil {
+			details.ResourceName = resource.Name
+			details.ResourceType = resource.Type
+		} else {
+			details.MissingDetails = append(details.MissingDetails, "resource_name", "resource_type")
+		}
+
+		enriched = append(enriched, details)
+	}
+
+	return enriched
+}
+
+// lookupAll resolves each ID from the cache or with fetch, running at most
+// maxConcurrentLookups fetches at a time. Unknown IDs are cached as missing.
+// Once the service looks unavailable the remaining fetches are skipped, so an
+// outage costs one timeout rather than one per row. Unresolved IDs are absent
+// from the result.
+func lookupAll[V any](ids []int, cache *ttlCache[V], fetch func(int) (*V, error)) map[int]*V {
+	result := make(map[int]*V, len(ids))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var unavailable atomic.Bool
+	semaphore := make(chan struct{}, maxConcurrentLookups)
+
+	for _, id := range ids {
+		if value, ok := cache.Get(id); ok {
+			if value != nil {
+				mutex.Lock()
+				result[id] = value
+				mutex.Unlock()
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			if unavailable.Load() {
+				return
+			}
+
+			value, err := fetch(id)
+			if err != nil {
+				if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrResourceNotFound) {
+					cache.Set(id, nil)
+					return
+				}
+				if !unavailable.Swap(true) {
+					log.Printf("Error loading booking details: %v", err)
+				}
+				return
+			}
+
+			cache.Set(id, value)
+			mutex.Lock()
+			result[id] = value
+			mutex.Unlock()
+		}(id)
+	}
+
+	wg.Wait()
+	return result
+}
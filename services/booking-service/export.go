@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// exportBatchSize is how many bookings an export reads and enriches at a time
+const exportBatchSize = 500
+
+// Export formats
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// BookingExport is one row of a booking export
+type BookingExport struct {
+	*BookingWithDetails
+	DurationMinutes int     `json:"duration_minutes"`
+	DurationHours   float64 `json:"duration_hours"`
+}
+
+// BookingExportWriter encodes exported bookings. Flush is called after every
+// batch so rows reach the client while the export goes on.
+type BookingExportWriter interface {
+	Write(booking *BookingExport) error
+	Flush() error
+}
+
+// Export passes every booking matching the query to out in list order, with
+// user and resource details. Page and Size are ignored: the bookings are read
+// exportBatchSize at a time after the last one read, so memory use does not grow
+// with the export and each batch is found by index rather than by skipping rows.
+func (s *BookingService) Export(query ListBookingsQuery, out BookingExportWriter) error {
+	for {
+		bookings, err := s.repository.List(query, exportBatchSize, 0)
+		if err != nil {
+			return fmt.Errorf("failed to list bookings: %w", err)
+		}
+
+		for _, booking := range s.enrich(bookings) {
+			duration := booking.Duration()
+			row := &BookingExport{
+				BookingWithDetails: booking,
+				DurationMinutes:    int(duration.Round(time.Minute).Minutes()),
+				DurationHours:      math.Round(duration.Hours()*100) / 100,
+			}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		if len(bookings) < exportBatchSize {
+			return nil
+		}
+		last := bookings[len(bookings)-1]
+		query.AfterStart, query.AfterID = last.StartTime, last.ID
+	}
+}
+
+// NewBookingExportWriter returns the writer of a format, or nil for unknown formats.
+// The CSV header is written before the first row.
+func NewBookingExportWriter(format string, w io.Writer, flush func() error) BookingExportWriter {
+	switch format {
+	case ExportFormatCSV:
+		return &csvExportWriter{csv: csv.NewWriter(w), flush: flush}
+	case ExportFormatNDJSON:
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w), flush: flush}
+	default:
+		return nil
+	}
+}
+
+// bookingExportColumns is the header of CSV exports
+var bookingExportColumns = []string{
+	"id", "user_id", "user_name", "user_email", "resource_id", "resource_name", "resource_type",
+	"start_time", "end_time", "duration_minutes", "duration_hours", "status", "seats", "notes",
+	"series_id", "group_id", "created_at", "canceled_at", "cancellation_reason", "checked_in_at",
+}
+
+type csvExportWriter struct {
+	csv           *csv.Writer
+	flush         func() error
+	headerWritten bool
+}
+
+func (c *csvExportWriter) Write(booking *BookingExport) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	return c.csv.Write([]string{
+		strconv.Itoa(booking.ID),
+		strconv.Itoa(booking.UserID),
+		booking.UserName,
+		booking.UserEmail,
+		strconv.Itoa(booking.ResourceID),
+		booking.ResourceName,
+		booking.ResourceType,
+		booking.StartTime.Format(time.RFC3339),
+		booking.EndTime.Format(time.RFC3339),
+		strconv.Itoa(booking.DurationMinutes),
+		strconv.FormatFloat(booking.DurationHours, 'f', 2, 64),
+		string(booking.Status),
+		strconv.Itoa(booking.Seats),
+		booking.Notes,
+		optionalID(booking.SeriesID),
+		optionalID(booking.GroupID),
+		booking.CreatedAt.Format(time.RFC3339),
+		optionalTime(booking.CanceledAt),
+		booking.CancellationReason,
+		optionalTime(booking.CheckedInAt),
+	})
+}
+
+// Flush writes the header of empty exports too, so the file always names its columns
+func (c *csvExportWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	c.csv.Flush()
+	if err := c.csv.Error(); err != nil {
+		return err
+	}
+	return c.flush()
+}
+
+func (c *csvExportWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.csv.Write(bookingExportColumns)
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+	flush   func() error
+}
+
+func (n *ndjsonExportWriter) Write(booking *BookingExport) error {
+	return n.encoder.Encode(booking)
+}
+
+func (n *ndjsonExportWriter) Flush() error {
+	return n.flush()
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// optionalID formats an optional ID as an empty CSV field when unset
+func optionalID(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
+
+// optionalTime formats an optional time as an empty CSV field when unset
+func optionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -2,4 +2,10 @@ module booking-service
 
 go 1.24
 
-require github.com/gorilla/mux v1.8.0
+require (
+	github.com/golang-jwt/jwt/v5 v5.2.2
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/mux v1.8.0
+	github.com/lib/pq v1.10.9
+	github.com/rabbitmq/amqp091-go v1.10.0
+)
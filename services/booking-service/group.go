@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidGroup is returned when a booking group lists no resources or one twice
+	ErrInvalidGroup = errors.New("a booking group needs distinct resources")
+	// ErrGroupMember is returned when moving one booking of a group on its own
+	ErrGroupMember = errors.New("booking belongs to a booking group; reschedule the group instead")
+	// ErrGroupNotModifiable is returned when rescheduling a group without active bookings
+	ErrGroupNotModifiable = errors.New("booking group cannot be modified: no active bookings")
+	// ErrInvalidGroupTime is returned when a reschedule leaves a group ending before it starts
+	ErrInvalidGroupTime = errors.New("end time must be after start time")
+)
+
+// GroupConflictError is returned when member resources of a group are taken.
+// It matches ErrBookingConflict with errors.Is.
+type GroupConflictError struct {
+	Blocked []ResourceConflict
+}
+
+func (e *GroupConflictError) Error() string {
+	ids := make([]string, len(e.Blocked))
+	for i, blocked := range e.Blocked {
+		ids[i] = fmt.Sprint(blocked.ResourceID)
+	}
+	return fmt.Sprintf("resources %s are not available for the selected time slot", strings.Join(ids, ", "))
+}
+
+func (e *GroupConflictError) Unwrap() error {
+	return ErrBookingConflict
+}
+
+// CreateGroup books every requested resource for the same time window. Either all
+// bookings are created or none is; a conflict reports each blocked resource.
+func (s *BookingService) CreateGroup(caller Identity, req CreateBookingGroupRequest) (*BookingGroupWithMembers, error) {
+	seen := make(map[int]bool)
+	for _, resourceID := range req.ResourceIDs {
+		if seen[resourceID] {
+			return nil, ErrInvalidGroup
+		}
+		seen[resourceID] = true
+	}
+	if len(req.ResourceIDs) == 0 {
+		return nil, ErrInvalidGroup
+	}
+
+	now := time.Now()
+	group := &BookingGroup{
+		UserID:    caller.UserID,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Notes:     req.Notes,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Members share the earliest hold expiry, so an unconfirmed group expires as a whole
+	var expiresAt *time.Time
+	bookings := make([]*Booking, len(req.ResourceIDs))
+	for i, resourceID := range req.ResourceIDs {
+		memberExpiry := s.holdExpiry(resourceID, now)
+		if expiresAt == nil || memberExpiry.Before(*expiresAt) {
+			expiresAt = memberExpiry
+		}
+		bookings[i] = &Booking{
+			UserID:     caller.UserID,
+			ResourceID: resourceID,
+			StartTime:  req.StartTime,
+			EndTime:    req.EndTime,
+			Status:     BookingStatusPending,
+			Notes:      req.Notes,
+			Seats:      req.Seats,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+	}
+	for _, booking := range bookings {
+		booking.ExpiresAt = expiresAt
+	}
+
+	occupancies, err := s.checkGroupMembers(caller, bookings)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.CreateGroupIfAvailable(group, bookings, occupancies, BookingEventCreated); err != nil {
+		if errors.Is(err, ErrBookingConflict) {
+			return nil, s.groupConflict(bookings, err)
+		}
+		return nil, fmt.Errorf("failed to create booking group: %w", err)
+	}
+
+	return &BookingGroupWithMembers{BookingGroup: *group, Members: bookings}, nil
+}
+
+// GetGroup retrieves a group with the booking of each resource
+func (s *BookingService) GetGroup(id int) (*BookingGroupWithMembers, error) {
+	group, err := s.repository.GetGroup(id)
+	if err != nil {
+		return nil, fmt.Errorf("booking group not found: %w", err)
+	}
+
+	members, err := s.repository.GetByGroupID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+
+	return &BookingGroupWithMembers{BookingGroup: *group, Members: members}, nil
+}
+
+// RescheduleGroup moves every modifiable booking of the group to a new time
+// window, all at once or not at all
+func (s *BookingService) RescheduleGroup(caller Identity, id int, req UpdateBookingRequest) (*BookingGroupWithMembers, error) {
+	group, err := s.repository.GetGroup(id)
+	if err != nil {
+		return nil, fmt.Errorf("booking group not found: %w", err)
+	}
+
+	members, err := s.repository.GetByGroupID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+
+	var bookings []*Booking
+	for _, member := range members {
+		if member.CanBeModified() {
+			bookings = append(bookings, member)
+		}
+	}
+	if group.CanceledAt != nil || len(bookings) == 0 {
+		return nil, ErrGroupNotModifiable
+	}
+
+	now := time.Now()
+	if req.StartTime != nil {
+		group.StartTime = *req.StartTime
+	}
+	if req.EndTime != nil {
+		group.EndTime = *req.EndTime
+	}
+	if req.Notes != nil {
+		group.Notes = *req.Notes
+	}
+	if !group.StartTime.Before(group.EndTime) {
+		return nil, ErrInvalidGroupTime
+	}
+	group.UpdatedAt = now
+
+	for _, booking := range bookings {
+		booking.StartTime = group.StartTime
+		booking.EndTime = group.EndTime
+		booking.Notes = group.Notes
+		if req.Seats != nil {
+			booking.Seats = *req.Seats
+		}
+		booking.UpdatedAt = now
+	}
+
+	occupancies, err := s.checkGroupMembers(caller, bookings)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.UpdateGroupIfAvailable(group, bookings, occupancies, BookingEventUpdated); err != nil {
+		if errors.Is(err, ErrBookingConflict) {
+			return nil, s.groupConflict(bookings, err)
+		}
+		return nil, fmt.Errorf("failed to reschedule booking group: %w", err)
+	}
+
+	return s.GetGroup(id)
+}
+
+// CancelGroup cancels every booking of the group that has not started yet, all
+// at once or not at all
+func (s *BookingService) CancelGroup(id int) (*GroupResult, error) {
+	group, err := s.repository.GetGroup(id)
+	if err != nil {
+		return nil, fmt.Errorf("booking group not found: %w", err)
+	}
+
+	members, err := s.repository.GetByGroupID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+
+	now := time.Now()
+	bookings := []*Booking{}
+	var fromStatuses []BookingStatus
+	for _, booking := range members {
+		if !booking.StartTime.After(now) || !booking.IsValidTransition(BookingStatusCanceled) {
+			continue
+		}
+
+		fromStatuses = append(fromStatuses, booking.Status)
+		booking.Status = BookingStatusCanceled
+		booking.UpdatedAt = now
+		booking.CanceledAt = &now
+		bookings = append(bookings, booking)
+	}
+
+	group.CanceledAt = &now
+	group.UpdatedAt = now
+	if err := s.repository.UpdateGroupIfStatus(group, bookings, fromStatuses, BookingEventCanceled); err != nil {
+		if errors.Is(err, ErrBookingChanged) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to cancel booking group: %w", err)
+	}
+
+	for _, booking := range bookings {
+		s.closeWaitlistOffer(booking.ID, WaitlistStatusCanceled)
+		s.promoteWaitlist(booking.ResourceID, booking.StartTime, booking.EndTime)
+	}
+
+	return &GroupResult{Group: group, Bookings: bookings}, nil
+}
+
+// checkGroupMembers validates every member resource, its policies for the group
+// owner, seats and the caller's quota, and returns the occupancy of each member
+// resource
+func (s *BookingService) checkGroupMembers(caller Identity, bookings []*Booking) ([]Occupancy, error) {
+	if len(bookings) == 0 {
+		return nil, nil
+	}
+	owner, err := s.bookingOwner(caller, bookings[0].UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	occupancies := make([]Occupancy, len(bookings))
+	for i, booking := range bookings {
+		if err := s.validateResource(booking.ResourceID, booking.StartTime, booking.EndTime); err != nil {
+			return nil, fmt.Errorf("resource %d: %w", booking.ResourceID, err)
+		}
+
+		if err := s.checkPolicy(owner, booking.ResourceID, booking.StartTime, booking.EndTime); err != nil {
+			return nil, err
+		}
+
+		occupancy, err := s.occupancy(booking.ResourceID)
+		if err != nil {
+			return nil, err
+		}
+		if booking.Seats, err = seats(booking.Seats, occupancy); err != nil {
+			return nil, fmt.Errorf("resource %d: %w", booking.ResourceID, err)
+		}
+		occupancies[i] = occupancy
+	}
+
+	if err := s.checkQuota(caller, bookings...); err != nil {
+		return nil, err
+	}
+
+	return occupancies, nil
+}
+
+// groupConflict describes which member resources are taken. A member never blocks
+// itself when the group is moved. If the slot was freed after the atomic check
+// failed, the resource named by the repository is reported without conflicts.
+func (s *BookingService) groupConflict(bookings []*Booking, err error) error {
+	conflictErr := &GroupConflictError{}
+	for _, booking := range bookings {
+		occupancy, occupancyErr := s.occupancy(booking.ResourceID)
+		if occupancyErr != nil {
+			continue
+		}
+
+		conflicts, lookupErr := s.conflictingBookings(booking.ResourceID, booking.StartTime, booking.EndTime, occupancy.Buffers)
+		if lookupErr != nil {
+			continue
+		}
+
+		var blocking []*Booking
+		for _, conflict := range conflicts {
+			if conflict.ID != booking.ID {
+				blocking = append(blocking, conflict)
+			}
+		}
+		if !occupancy.Fits(booking, blocking) {
+			conflictErr.Blocked = append(conflictErr.Blocked, ResourceConflict{
+				ResourceID: booking.ResourceID,
+				Conflicts:  toBookingConflicts(blocking, occupancy.Buffers),
+			})
+		}
+	}
+
+	var resourceErr *ResourceConflictError
+	if len(conflictErr.Blocked) == 0 && errors.As(err, &resourceErr) {
+		conflictErr.Blocked = append(conflictErr.Blocked, ResourceConflict{
+			ResourceID: resourceErr.ResourceID,
+			Conflicts:  []BookingConflict{},
+		})
+	}
+
+	return conflictErr
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -14,16 +18,15 @@ type BookingHandler struct {
 	bookingService *BookingService
 }
 
-func NewBookingHandler() *BookingHandler {
+func NewBookingHandler(bookingService *BookingService) *BookingHandler {
 	return &BookingHandler{
-		bookingService: NewBookingService(),
+		bookingService: bookingService,
 	}
 }
 
 // CreateBooking handles POST /api/v1/bookings
 func (h *BookingHandler) CreateBooking(w http.ResponseWriter, r *http.Request) {
-	// TODO: Extract user ID from JWT token
-	userID := 1 // Placeholder
+	caller := requestIdentity(r)
 
 	var req CreateBookingRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -42,9 +45,32 @@ func (h *BookingHandler) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	booking, err := h.bookingService.Create(userID, req)
+	if req.Seats < 0 {
+		http.Error(w, "Seats must be positive", http.StatusBadRequest)
+		return
+	}
+
+	if req.Recurrence != "" {
+		h.createSeries(w, caller, req)
+		return
+	}
+
+	booking, err := h.bookingService.Create(caller, req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusConflict)
+		if errors.Is(err, ErrBookingConflict) && req.JoinWaitlist {
+			h.joinWaitlist(w, caller, JoinWaitlistRequest{
+				ResourceID: req.ResourceID,
+				StartTime:  req.StartTime,
+				EndTime:    req.EndTime,
+				Notes:      req.Notes,
+				Seats:      req.Seats,
+			}, http.StatusAccepted)
+			return
+		}
+		if writeResourceError(w, err) || writePolicyError(w, err) || writeQuotaError(w, err) || writeConflictError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -54,6 +80,161 @@ func (h *BookingHandler) CreateBooking(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// createSeries handles POST /api/v1/bookings for requests with a recurrence rule
+func (h *BookingHandler) createSeries(w http.ResponseWriter, caller Identity, req CreateBookingRequest) {
+	result, err := h.bookingService.CreateSeries(caller, req)
+	if err != nil {
+		if writeResourceError(w, err) || writePolicyError(w, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidRecurrence) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// Report which occurrences failed; the series conflicts only if none was booked
+	status := http.StatusCreated
+	if len(result.Bookings) == 0 {
+		status = http.StatusConflict
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding booking series response: %v", err)
+	}
+}
+
+// writeError writes a JSON error body with a machine-readable code
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(ErrorResponse{Code: code, Message: message}); err != nil {
+		log.Printf("Error encoding error response: %v", err)
+	}
+}
+
+// writeResourceError writes resource validation failures with their error code
+// and reports whether err was one
+func writeResourceError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, ErrResourceNotFound):
+		writeError(w, http.StatusUnprocessableEntity, ErrorCodeResourceNotFound, err.Error())
+	case errors.Is(err, ErrResourceInactive):
+		writeError(w, http.StatusConflict, ErrorCodeResourceInactive, err.Error())
+	case errors.Is(err, ErrOutsideOpeningHours):
+		writeError(w, http.StatusConflict, ErrorCodeOutsideOpeningHours, err.Error())
+	case errors.Is(err, ErrResourceServiceUnavailable):
+		writeError(w, http.StatusServiceUnavailable, ErrorCodeResourceServiceUnavailable, err.Error())
+	case errors.Is(err, ErrSeatsExceedCapacity):
+		writeError(w, http.StatusUnprocessableEntity, ErrorCodeSeatsExceedCapacity, err.Error())
+	default:
+		return false
+	}
+	return true
+}
+
+// writePolicyError writes every failed policy rule and reports whether err was a
+// policy violation
+func writePolicyError(w http.ResponseWriter, err error) bool {
+	var violation *PolicyViolationError
+	if !errors.As(err, &violation) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	response := ErrorResponse{
+		Code:       ErrorCodePolicyViolation,
+		Message:    "Booking violates one or more booking policies",
+		Violations: violation.Violations,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding error response: %v", err)
+	}
+	return true
+}
+
+// writeQuotaError writes the limits a booking would exceed and reports whether
+// err was a quota error
+func writeQuotaError(w http.ResponseWriter, err error) bool {
+	var exceeded *QuotaExceededError
+	if !errors.As(err, &exceeded) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	response := ErrorResponse{
+		Code:    ErrorCodeQuotaExceeded,
+		Message: err.Error(),
+		Quota:   exceeded.Exceeded,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding error response: %v", err)
+	}
+	return true
+}
+
+// writeGroupConflictError writes the member resources that blocked a booking group
+// and reports whether err was a group conflict
+func writeGroupConflictError(w http.ResponseWriter, err error) bool {
+	var conflict *GroupConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	response := ErrorResponse{
+		Code:             ErrorCodeBookingConflict,
+		Message:          err.Error(),
+		BlockedResources: conflict.Blocked,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding error response: %v", err)
+	}
+	return true
+}
+
+// writeConflictError writes the bookings in the way of a booking with the
+// alternatives suggested for it and reports whether err was a booking conflict
+func writeConflictError(w http.ResponseWriter, err error) bool {
+	var conflict *BookingConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	response := ErrorResponse{
+		Code:         ErrorCodeBookingConflict,
+		Message:      err.Error(),
+		Conflicts:    conflict.Conflicts,
+		Alternatives: conflict.Alternatives,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding error response: %v", err)
+	}
+	return true
+}
+
+// writeUserError writes user-service lookup failures and reports whether err was one
+func writeUserError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrUserServiceUnavailable):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		return false
+	}
+	return true
+}
+
 // parseListBookingsQuery extracts and validates query parameters for listing bookings
 func parseListBookingsQuery(r *http.Request) ListBookingsQuery {
 	query := ListBookingsQuery{}
@@ -105,6 +286,12 @@ func parseListBookingsQuery(r *http.Request) ListBookingsQuery {
 func (h *BookingHandler) ListBookings(w http.ResponseWriter, r *http.Request) {
 	query := parseListBookingsQuery(r)
 
+	userID, err := scopeToCaller(requestIdentity(r), query.UserID)
+	if !authorized(w, err) {
+		return
+	}
+	query.UserID = userID
+
 	bookings, err := h.bookingService.List(query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -117,6 +304,75 @@ func (h *BookingHandler) ListBookings(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// exportWriteTimeout is how long an export may take to write each batch
+const exportWriteTimeout = 30 * time.Second
+
+// ExportBookings handles GET /api/v1/bookings/export. It takes the filters of
+// ListBookings and streams every matching booking as CSV or NDJSON, chosen by the
+// format parameter or the Accept header.
+func (h *BookingHandler) ExportBookings(w http.ResponseWriter, r *http.Request) {
+	query := parseListBookingsQuery(r)
+
+	userID, err := scopeToCaller(requestIdentity(r), query.UserID)
+	if !authorized(w, err) {
+		return
+	}
+	query.UserID = userID
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportFormatCSV
+		accept := r.Header.Get("Accept")
+		if strings.Contains(accept, "application/x-ndjson") || strings.Contains(accept, "application/jsonl") {
+			format = ExportFormatNDJSON
+		}
+	}
+
+	// Long exports outlive the server's write timeout, so each batch gets its own
+	controller := http.NewResponseController(w)
+	extendDeadline := func() error {
+		if err := controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+	flush := func() error {
+		if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return extendDeadline()
+	}
+
+	body := &countingWriter{w: w}
+	out := NewBookingExportWriter(format, body, flush)
+	if out == nil {
+		http.Error(w, "Format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == ExportFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="bookings.%s"`, format))
+	if err := extendDeadline(); err != nil {
+		log.Printf("Error extending export write deadline: %v", err)
+	}
+
+	if err := h.bookingService.Export(query, out); err != nil {
+		if body.n == 0 {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Rows were already sent, so the connection is dropped for the client to
+		// see an incomplete export rather than a file that looks finished
+		log.Printf("Error exporting bookings: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
 // GetBooking handles GET /api/v1/bookings/{id}
 func (h *BookingHandler) GetBooking(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -125,6 +381,11 @@ func (h *BookingHandler) GetBooking(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
 		return
 	}
+	// Attendees see the bookings they are invited to
+	if !authorized(w, h.bookingService.AuthorizeAttendees(requestIdentity(r), id)) {
+		return
+	}
+
 	booking, err := h.bookingService.GetByID(id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -145,6 +406,9 @@ func (h *BookingHandler) UpdateBooking(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
 		return
 	}
+	if !authorized(w, h.bookingService.AuthorizeBooking(requestIdentity(r), id)) {
+		return
+	}
 
 	var req UpdateBookingRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -159,9 +423,29 @@ func (h *BookingHandler) UpdateBooking(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	booking, err := h.bookingService.Update(id, req)
+	if req.Seats != nil && *req.Seats < 1 {
+		http.Error(w, "Seats must be positive", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("scope") == UpdateScopeFollowing {
+		h.updateFollowing(w, requestIdentity(r), id, req)
+		return
+	}
+
+	booking, err := h.bookingService.Update(requestIdentity(r), id, req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if writeResourceError(w, err) || writeUserError(w, err) || writePolicyError(w, err) || writeQuotaError(w, err) ||
+			writeConflictError(w, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrBookingConflict), errors.Is(err, ErrBookingChanged):
+			status = http.StatusConflict
+		case errors.Is(err, ErrGroupMember):
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
@@ -171,6 +455,32 @@ func (h *BookingHandler) UpdateBooking(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// updateFollowing handles PUT /api/v1/bookings/{id}?scope=following
+func (h *BookingHandler) updateFollowing(w http.ResponseWriter, caller Identity, id int, req UpdateBookingRequest) {
+	result, err := h.bookingService.UpdateFollowing(caller, id, req)
+	if err != nil {
+		if writeResourceError(w, err) || writeUserError(w, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrNotInSeries):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrBookingChanged):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding booking series response: %v", err)
+	}
+}
+
 // CancelBooking handles DELETE /api/v1/bookings/{id}
 func (h *BookingHandler) CancelBooking(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -179,9 +489,19 @@ func (h *BookingHandler) CancelBooking(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
 		return
 	}
+	if !authorized(w, h.bookingService.AuthorizeBooking(requestIdentity(r), id)) {
+		return
+	}
 
 	if err := h.bookingService.Cancel(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrBookingChanged):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
@@ -190,6 +510,10 @@ func (h *BookingHandler) CancelBooking(w http.ResponseWriter, r *http.Request) {
 
 // ConfirmBooking handles POST /api/v1/bookings/{id}/confirm
 func (h *BookingHandler) ConfirmBooking(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, requireElevated(requestIdentity(r))) {
+		return
+	}
+
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -199,7 +523,56 @@ func (h *BookingHandler) ConfirmBooking(w http.ResponseWriter, r *http.Request)
 
 	booking, err := h.bookingService.Confirm(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrHoldExpired), errors.Is(err, ErrBookingChanged):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(booking); err != nil {
+		log.Printf("Error encoding booking response: %v", err)
+	}
+}
+
+// CheckInBooking handles POST /api/v1/bookings/{id}/check-in
+func (h *BookingHandler) CheckInBooking(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeBooking(requestIdentity(r), id)) {
+		return
+	}
+
+	// The body is optional; it only carries a token scanned from a room display
+	var req CheckInRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		req.Token = token
+	}
+
+	booking, err := h.bookingService.CheckIn(id, req.Token)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidCheckInToken):
+			status = http.StatusForbidden
+		case errors.Is(err, ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrCheckInClosed), errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrBookingChanged):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -208,6 +581,36 @@ func (h *BookingHandler) ConfirmBooking(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// GetCheckInToken handles GET /api/v1/bookings/{id}/check-in-token
+func (h *BookingHandler) GetCheckInToken(w http.ResponseWriter, r *http.Request) {
+	// Tokens are shown on room displays, which sign in with a manager account;
+	// letting owners fetch them would defeat the on-site check
+	if !authorized(w, requireElevated(requestIdentity(r))) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.bookingService.IssueCheckInToken(id)
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, ErrCheckInClosed) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(token); err != nil {
+		log.Printf("Error encoding check-in token response: %v", err)
+	}
+}
+
 // GetUserBookings handles GET /api/v1/users/{user_id}/bookings
 func (h *BookingHandler) GetUserBookings(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -216,9 +619,14 @@ func (h *BookingHandler) GetUserBookings(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
+	if !authorized(w, requireOwner(requestIdentity(r), userID)) {
+		return
+	}
 
+	// Bookings the user is invited to are listed next to their own
 	query := ListBookingsQuery{
-		UserID: userID,
+		UserID:           userID,
+		IncludeAttending: true,
 	}
 
 	// Parse additional query parameters
@@ -249,6 +657,82 @@ func (h *BookingHandler) GetUserBookings(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// GetUserQuota handles GET /api/v1/users/{user_id}/quota
+func (h *BookingHandler) GetUserQuota(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["user_id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	identity := requestIdentity(r)
+	if !authorized(w, requireOwner(identity, userID)) {
+		return
+	}
+
+	quota, err := h.bookingService.GetQuota(identity, userID)
+	if err != nil {
+		if writeUserError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(quota); err != nil {
+		log.Printf("Error encoding quota response: %v", err)
+	}
+}
+
+// GrantUserQuota handles POST /api/v1/users/{user_id}/quota/grants
+func (h *BookingHandler) GrantUserQuota(w http.ResponseWriter, r *http.Request) {
+	identity := requestIdentity(r)
+	if !authorized(w, requireAdmin(identity)) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["user_id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req GrantQuotaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if req.ExtraActiveBookings < 0 || req.ExtraHoursPerWeek < 0 || req.ExtraHoursPerMonth < 0 {
+		http.Error(w, "Quota increases cannot be negative", http.StatusBadRequest)
+		return
+	}
+	if req.ExtraActiveBookings == 0 && req.ExtraHoursPerWeek == 0 && req.ExtraHoursPerMonth == 0 {
+		http.Error(w, "At least one quota increase is required", http.StatusBadRequest)
+		return
+	}
+	if !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "Expiry must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	grant, err := h.bookingService.GrantQuota(identity.UserID, userID, req)
+	if err != nil {
+		if writeUserError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(grant); err != nil {
+		log.Printf("Error encoding quota grant response: %v", err)
+	}
+}
+
 // CheckAvailability handles POST /api/v1/bookings/check-availability
 func (h *BookingHandler) CheckAvailability(w http.ResponseWriter, r *http.Request) {
 	var req AvailabilityCheckRequest
@@ -257,8 +741,54 @@ func (h *BookingHandler) CheckAvailability(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Validate request
+	resourceIDs := req.AllResourceIDs()
+	windows := req.AllWindows()
+	if len(resourceIDs) == 0 {
+		http.Error(w, "At least one resource ID is required", http.StatusBadRequest)
+		return
+	}
+	if len(windows) == 0 {
+		http.Error(w, "At least one time window is required", http.StatusBadRequest)
+		return
+	}
+	if len(resourceIDs) > MaxAvailabilityResources || len(windows) > MaxAvailabilityWindows {
+		http.Error(w, fmt.Sprintf("At most %d resources and %d windows can be checked at once",
+			MaxAvailabilityResources, MaxAvailabilityWindows), http.StatusBadRequest)
+		return
+	}
+	for _, window := range windows {
+		if !window.StartTime.Before(window.EndTime) {
+			http.Error(w, "End time must be after start time", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Seats < 0 {
+		http.Error(w, "Seats must be positive", http.StatusBadRequest)
+		return
+	}
+
+	if req.IsBatch() {
+		responses, err := h.bookingService.CheckAvailabilityBatch(req)
+		if err != nil {
+			if writeResourceError(w, err) {
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(responses); err != nil {
+			log.Printf("Error encoding availability response: %v", err)
+		}
+		return
+	}
+
 	response, err := h.bookingService.CheckAvailability(req)
 	if err != nil {
+		if writeResourceError(w, err) {
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -267,3 +797,737 @@ func (h *BookingHandler) CheckAvailability(w http.ResponseWriter, r *http.Reques
 		log.Printf("Error encoding availability response: %v", err)
 	}
 }
+
+// FindSlots handles POST /api/v1/bookings/find-slots
+func (h *BookingHandler) FindSlots(w http.ResponseWriter, r *http.Request) {
+	var req SlotSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if req.DurationMinutes <= 0 {
+		http.Error(w, "Duration must be positive", http.StatusBadRequest)
+		return
+	}
+	if !req.Window.StartTime.Before(req.Window.EndTime) {
+		http.Error(w, "Window end time must be after its start time", http.StatusBadRequest)
+		return
+	}
+	if req.Window.EndTime.Sub(req.Window.StartTime) > MaxSlotSearchDays*24*time.Hour {
+		http.Error(w, fmt.Sprintf("The window cannot be longer than %d days", MaxSlotSearchDays), http.StatusBadRequest)
+		return
+	}
+	if req.Limit < 0 || req.Limit > MaxSlotSearchLimit {
+		http.Error(w, fmt.Sprintf("Limit must be between 1 and %d", MaxSlotSearchLimit), http.StatusBadRequest)
+		return
+	}
+	if req.Seats < 0 || req.MinCapacity < 0 {
+		http.Error(w, "Seats and minimum capacity must be positive", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.bookingService.FindSlots(requestIdentity(r), req)
+	if err != nil {
+		if errors.Is(err, ErrInvalidPreferredHours) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if writeResourceError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding slot search response: %v", err)
+	}
+}
+
+// GetSeries handles GET /api/v1/booking-series/{id}
+func (h *BookingHandler) GetSeries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeSeries(requestIdentity(r), id)) {
+		return
+	}
+
+	series, err := h.bookingService.GetSeries(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(series); err != nil {
+		log.Printf("Error encoding booking series response: %v", err)
+	}
+}
+
+// CancelSeries handles DELETE /api/v1/booking-series/{id}
+func (h *BookingHandler) CancelSeries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeSeries(requestIdentity(r), id)) {
+		return
+	}
+
+	result, err := h.bookingService.CancelSeries(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding booking series response: %v", err)
+	}
+}
+
+// CreateBookingGroup handles POST /api/v1/booking-groups
+func (h *BookingHandler) CreateBookingGroup(w http.ResponseWriter, r *http.Request) {
+	var req CreateBookingGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if req.StartTime.After(req.EndTime) || req.StartTime.Equal(req.EndTime) {
+		http.Error(w, "End time must be after start time", http.StatusBadRequest)
+		return
+	}
+
+	if req.StartTime.Before(time.Now()) {
+		http.Error(w, "Cannot create booking in the past", http.StatusBadRequest)
+		return
+	}
+
+	if req.Seats < 0 {
+		http.Error(w, "Seats must be positive", http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.bookingService.CreateGroup(requestIdentity(r), req)
+	if err != nil {
+		if writeGroupConflictError(w, err) || writeResourceError(w, err) ||
+			writePolicyError(w, err) || writeQuotaError(w, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidGroup) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(group); err != nil {
+		log.Printf("Error encoding booking group response: %v", err)
+	}
+}
+
+// GetBookingGroup handles GET /api/v1/booking-groups/{id}
+func (h *BookingHandler) GetBookingGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeGroup(requestIdentity(r), id)) {
+		return
+	}
+
+	group, err := h.bookingService.GetGroup(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(group); err != nil {
+		log.Printf("Error encoding booking group response: %v", err)
+	}
+}
+
+// RescheduleBookingGroup handles PUT /api/v1/booking-groups/{id}
+func (h *BookingHandler) RescheduleBookingGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeGroup(requestIdentity(r), id)) {
+		return
+	}
+
+	var req UpdateBookingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Seats != nil && *req.Seats < 1 {
+		http.Error(w, "Seats must be positive", http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.bookingService.RescheduleGroup(requestIdentity(r), id, req)
+	if err != nil {
+		if writeGroupConflictError(w, err) || writeResourceError(w, err) || writeUserError(w, err) ||
+			writePolicyError(w, err) || writeQuotaError(w, err) {
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrInvalidGroupTime):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrGroupNotModifiable), errors.Is(err, ErrBookingChanged):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(group); err != nil {
+		log.Printf("Error encoding booking group response: %v", err)
+	}
+}
+
+// CancelBookingGroup handles DELETE /api/v1/booking-groups/{id}
+func (h *BookingHandler) CancelBookingGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeGroup(requestIdentity(r), id)) {
+		return
+	}
+
+	result, err := h.bookingService.CancelGroup(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrBookingChanged):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding booking group response: %v", err)
+	}
+}
+
+// ListBookingAttendees handles GET /api/v1/bookings/{id}/attendees
+func (h *BookingHandler) ListBookingAttendees(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeAttendees(requestIdentity(r), id)) {
+		return
+	}
+
+	attendees, err := h.bookingService.ListAttendees(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(attendees); err != nil {
+		log.Printf("Error encoding attendees response: %v", err)
+	}
+}
+
+// AddBookingAttendee handles POST /api/v1/bookings/{id}/attendees
+func (h *BookingHandler) AddBookingAttendee(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeBooking(requestIdentity(r), id)) {
+		return
+	}
+
+	var req AddAttendeeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	attendee, err := h.bookingService.AddAttendee(id, req)
+	if err != nil {
+		if writeUserError(w, err) {
+			return
+		}
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrAttendeeExists) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(attendee); err != nil {
+		log.Printf("Error encoding attendee response: %v", err)
+	}
+}
+
+// RemoveBookingAttendee handles DELETE /api/v1/bookings/{id}/attendees/{attendee_id}
+func (h *BookingHandler) RemoveBookingAttendee(w http.ResponseWriter, r *http.Request) {
+	id, attendeeID, ok := attendeeVars(w, r)
+	if !ok {
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeAttendee(requestIdentity(r), id, attendeeID)) {
+		return
+	}
+
+	if err := h.bookingService.RemoveAttendee(id, attendeeID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrAttendeeNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RespondToInvitation handles PUT /api/v1/bookings/{id}/attendees/{attendee_id}/rsvp
+func (h *BookingHandler) RespondToInvitation(w http.ResponseWriter, r *http.Request) {
+	id, attendeeID, ok := attendeeVars(w, r)
+	if !ok {
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeRSVP(requestIdentity(r), id, attendeeID)) {
+		return
+	}
+
+	var req RSVPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	attendee, err := h.bookingService.RespondToInvitation(id, attendeeID, req.Status)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrAttendeeNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(attendee); err != nil {
+		log.Printf("Error encoding attendee response: %v", err)
+	}
+}
+
+// attendeeVars parses the booking and attendee IDs of an attendee route
+func attendeeVars(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	attendeeID, err := strconv.Atoi(vars["attendee_id"])
+	if err != nil {
+		http.Error(w, "Invalid attendee ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return id, attendeeID, true
+}
+
+// CreateCalendarFeed handles POST /api/v1/users/{user_id}/calendar-feed and
+// POST /api/v1/resources/{resource_id}/calendar-feed
+func (h *BookingHandler) CreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	ownerType, ownerID, ok := calendarFeedOwner(w, r)
+	if !ok {
+		return
+	}
+
+	feed, err := h.bookingService.CreateCalendarFeed(requestIdentity(r), ownerType, ownerID)
+	if err != nil {
+		if writeUserError(w, err) || writeResourceError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("Error encoding calendar feed response: %v", err)
+	}
+}
+
+// RevokeCalendarFeed handles DELETE /api/v1/users/{user_id}/calendar-feed and
+// DELETE /api/v1/resources/{resource_id}/calendar-feed
+func (h *BookingHandler) RevokeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	ownerType, ownerID, ok := calendarFeedOwner(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.bookingService.RevokeCalendarFeed(ownerType, ownerID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCalendarFeed handles GET /api/v1/calendar/{token}.ics. Calendar clients
+// cannot send bearer tokens, so the feed token in the URL is the only credential.
+func (h *BookingHandler) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if err := h.bookingService.WriteCalendarFeed(w, vars["token"]); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrCalendarFeedNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+}
+
+// calendarFeedOwner reads the user or resource of a calendar feed route and
+// checks the caller may manage its feed: users their own, managers and admins
+// any user's or resource's
+func calendarFeedOwner(w http.ResponseWriter, r *http.Request) (CalendarFeedOwner, int, bool) {
+	vars := mux.Vars(r)
+	identity := requestIdentity(r)
+
+	if value, ok := vars["user_id"]; ok {
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return "", 0, false
+		}
+		return CalendarFeedUser, userID, authorized(w, requireOwner(identity, userID))
+	}
+
+	resourceID, err := strconv.Atoi(vars["resource_id"])
+	if err != nil {
+		http.Error(w, "Invalid resource ID", http.StatusBadRequest)
+		return "", 0, false
+	}
+	return CalendarFeedResource, resourceID, authorized(w, requireElevated(identity))
+}
+
+// JoinWaitlist handles POST /api/v1/waitlist
+func (h *BookingHandler) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	caller := requestIdentity(r)
+
+	var req JoinWaitlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if !req.StartTime.Before(req.EndTime) {
+		http.Error(w, "End time must be after start time", http.StatusBadRequest)
+		return
+	}
+
+	if req.StartTime.Before(time.Now()) {
+		http.Error(w, "Cannot join the waitlist for a slot in the past", http.StatusBadRequest)
+		return
+	}
+
+	if req.Seats < 0 {
+		http.Error(w, "Seats must be positive", http.StatusBadRequest)
+		return
+	}
+
+	h.joinWaitlist(w, caller, req, http.StatusCreated)
+}
+
+// joinWaitlist queues the caller and writes the entry with the given status code
+func (h *BookingHandler) joinWaitlist(w http.ResponseWriter, caller Identity, req JoinWaitlistRequest, status int) {
+	entry, err := h.bookingService.JoinWaitlist(caller, req)
+	if err != nil {
+		if writeResourceError(w, err) || writeQuotaError(w, err) {
+			return
+		}
+		code := http.StatusInternalServerError
+		if errors.Is(err, ErrSlotAvailable) {
+			code = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		log.Printf("Error encoding waitlist entry response: %v", err)
+	}
+}
+
+// ListWaitlist handles GET /api/v1/waitlist
+func (h *BookingHandler) ListWaitlist(w http.ResponseWriter, r *http.Request) {
+	query := ListWaitlistQuery{}
+
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		if id, err := strconv.Atoi(userID); err == nil {
+			query.UserID = id
+		}
+	}
+
+	if resourceID := r.URL.Query().Get("resource_id"); resourceID != "" {
+		if id, err := strconv.Atoi(resourceID); err == nil {
+			query.ResourceID = id
+		}
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		query.Status = WaitlistStatus(status)
+	}
+
+	userID, err := scopeToCaller(requestIdentity(r), query.UserID)
+	if !authorized(w, err) {
+		return
+	}
+	query.UserID = userID
+
+	if page := r.URL.Query().Get("page"); page != "" {
+		if p, err := strconv.Atoi(page); err == nil {
+			query.Page = p
+		}
+	}
+
+	if size := r.URL.Query().Get("size"); size != "" {
+		if s, err := strconv.Atoi(size); err == nil {
+			query.Size = s
+		}
+	}
+
+	entries, err := h.bookingService.ListWaitlist(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		log.Printf("Error encoding waitlist response: %v", err)
+	}
+}
+
+// GetWaitlistEntry handles GET /api/v1/waitlist/{id}
+func (h *BookingHandler) GetWaitlistEntry(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid waitlist entry ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeWaitlistEntry(requestIdentity(r), id)) {
+		return
+	}
+
+	entry, err := h.bookingService.GetWaitlistEntry(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		log.Printf("Error encoding waitlist entry response: %v", err)
+	}
+}
+
+// CancelWaitlistEntry handles DELETE /api/v1/waitlist/{id}
+func (h *BookingHandler) CancelWaitlistEntry(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid waitlist entry ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeWaitlistEntry(requestIdentity(r), id)) {
+		return
+	}
+
+	if err := h.bookingService.CancelWaitlistEntry(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrWaitlistEntryClosed) || errors.Is(err, ErrWaitlistEntryChanged) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptWaitlistOffer handles POST /api/v1/waitlist/{id}/accept
+func (h *BookingHandler) AcceptWaitlistOffer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid waitlist entry ID", http.StatusBadRequest)
+		return
+	}
+	if !authorized(w, h.bookingService.AuthorizeWaitlistOffer(requestIdentity(r), id)) {
+		return
+	}
+
+	booking, err := h.bookingService.AcceptWaitlistOffer(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrNoWaitlistOffer), errors.Is(err, ErrInvalidTransition),
+			errors.Is(err, ErrHoldExpired), errors.Is(err, ErrBookingChanged):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(booking); err != nil {
+		log.Printf("Error encoding booking response: %v", err)
+	}
+}
+
+// maxImportSize bounds the body of an import request
+const maxImportSize = 10 << 20
+
+// importTimeout is how long an import may take to read its file, store its rows
+// and write the result
+const importTimeout = 5 * time.Minute
+
+// ImportBookings handles POST /api/v1/admin/bookings/import. The body is the CSV or
+// iCalendar file itself; rows are only validated unless mode=commit. With
+// "Accept: text/csv" the response is the error report of the failed rows.
+func (h *BookingHandler) ImportBookings(w http.ResponseWriter, r *http.Request) {
+	identity := requestIdentity(r)
+	if !authorized(w, requireAdmin(identity)) {
+		return
+	}
+
+	query := r.URL.Query()
+	opts := ImportOptions{
+		Format: ImportFormat(query.Get("format")),
+		DryRun: query.Get("mode") != "commit",
+	}
+	if opts.Format == "" {
+		opts.Format = ImportFormatCSV
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "text/calendar") {
+			opts.Format = ImportFormatICS
+		}
+	}
+	if mode := query.Get("mode"); mode != "" && mode != "dry-run" && mode != "commit" {
+		http.Error(w, "Mode must be dry-run or commit", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if userID := query.Get("user_id"); userID != "" {
+		if opts.UserID, err = strconv.Atoi(userID); err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+	}
+	if resourceID := query.Get("resource_id"); resourceID != "" {
+		if opts.ResourceID, err = strconv.Atoi(resourceID); err != nil {
+			http.Error(w, "Invalid resource ID", http.StatusBadRequest)
+			return
+		}
+	}
+	if tz := query.Get("tz"); tz != "" {
+		if opts.Location, err = time.LoadLocation(tz); err != nil {
+			http.Error(w, "Invalid time zone", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Every row is checked against the resource and user services before the
+	// response is written, which outlives the server's timeouts
+	controller := http.NewResponseController(w)
+	deadline := time.Now().Add(importTimeout)
+	if err := controller.SetReadDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Error extending import read deadline: %v", err)
+	}
+	if err := controller.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Error extending import write deadline: %v", err)
+	}
+
+	result, err := h.bookingService.Import(identity, http.MaxBytesReader(w, r.Body, maxImportSize), opts)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidImport) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="import-errors.csv"`)
+		w.Header().Set("X-Import-Total", strconv.Itoa(result.Total))
+		w.Header().Set("X-Import-Failed", strconv.Itoa(result.Failed))
+		if err := WriteImportReport(w, result); err != nil {
+			log.Printf("Error writing import report: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding import response: %v", err)
+	}
+}
+
+// SweepCompletedBookings handles POST /api/v1/admin/bookings/complete-sweep
+func (h *BookingHandler) SweepCompletedBookings(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, requireAdmin(requestIdentity(r))) {
+		return
+	}
+
+	now := time.Now()
+	completed, err := h.bookingService.CompleteFinishedBookings(now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(SweepResult{Completed: completed, SweptAt: now}); err != nil {
+		log.Printf("Error encoding sweep response: %v", err)
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+// testJWTSecret signs the access tokens sent by handler tests
+const testJWTSecret = "booking-service-test-secret"
+
+// newTestConfig returns the default configuration without resource validation,
+// so tests do not call resource-service
+func newTestConfig() Config {
+	cfg := LoadConfig()
+	cfg.ResourceValidation = false
+	return cfg
+}
+
+// newTestRouter routes the booking API to a service on repo
+func newTestRouter(t *testing.T, repo BookingRepository, cfg Config) *mux.Router {
+	t.Helper()
+
+	authenticator, err := NewAuthenticator(testJWTSecret)
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+	return newRouter(NewBookingHandler(NewBookingService(repo, cfg)), authenticator)
+}
+
+// newTestUserService starts a stand-in user-service that knows the given users
+// and returns its URL
+func newTestUserService(t *testing.T, users ...User) string {
+	t.Helper()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(mux.Vars(r)["id"])
+		for _, user := range users {
+			if user.ID == id {
+				writeFakeJSON(w, user)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// testToken returns an access token for the user, as issued by user-service
+func testToken(t *testing.T, userID int, role string) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(userID),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+// serveJSON sends a request with body encoded as JSON and returns the recorded response
+func serveJSON(t *testing.T, handler http.Handler, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("failed to encode request: %v", err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestCreateBookingParallelRequestsBookSlotOnce(t *testing.T) {
+	const requests = 300
+
+	forEachRepository(t, func(t *testing.T, repo testRepository) {
+		router := newTestRouter(t, repo, newTestConfig())
+		token := testToken(t, repo.UserID, RoleUser)
+		start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+		body := CreateBookingRequest{ResourceID: repo.ResourceID, StartTime: start, EndTime: start.Add(time.Hour)}
+
+		// Every request waits for the others before it is sent
+		ready := make(chan struct{})
+		statuses := make([]int, requests)
+		var wg sync.WaitGroup
+		for i := range requests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-ready
+				statuses[i] = serveJSON(t, router, http.MethodPost, "/api/v1/bookings", token, body).Code
+			}()
+		}
+		close(ready)
+		wg.Wait()
+
+		created := 0
+		for _, status := range statuses {
+			switch status {
+			case http.StatusCreated:
+				created++
+			case http.StatusConflict:
+			default:
+				t.Errorf("unexpected status %d", status)
+			}
+		}
+		if created != 1 {
+			t.Errorf("%d of %d parallel requests created the booking, want exactly 1", created, requests)
+		}
+
+		stored, err := repo.List(ListBookingsQuery{ResourceID: repo.ResourceID}, requests, 0)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(stored) != 1 {
+			t.Errorf("%d bookings stored for the slot, want 1", len(stored))
+		}
+	})
+}
+
+// createTestBooking books an hour of resource 1 for the user through the API
+func createTestBooking(t *testing.T, router http.Handler, token string, start time.Time) *Booking {
+	t.Helper()
+
+	body := CreateBookingRequest{ResourceID: 1, StartTime: start, EndTime: start.Add(time.Hour)}
+	recorder := serveJSON(t, router, http.MethodPost, "/api/v1/bookings", token, body)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("POST /api/v1/bookings: status %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var booking Booking
+	if err := json.NewDecoder(recorder.Body).Decode(&booking); err != nil {
+		t.Fatalf("failed to decode booking: %v", err)
+	}
+	return &booking
+}
+
+func TestCancelAndConfirmStatusCodes(t *testing.T) {
+	router := newTestRouter(t, NewInMemoryBookingRepository(), newTestConfig())
+	userToken := testToken(t, 1, RoleUser)
+	adminToken := testToken(t, 2, RoleAdmin)
+	booking := createTestBooking(t, router, userToken, time.Now().Add(24*time.Hour).Truncate(time.Hour))
+	path := "/api/v1/bookings/" + strconv.Itoa(booking.ID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"confirm unknown booking", http.MethodPost, "/api/v1/bookings/999/confirm", adminToken, http.StatusNotFound},
+		{"cancel unknown booking", http.MethodDelete, "/api/v1/bookings/999", userToken, http.StatusNotFound},
+		{"cancel", http.MethodDelete, path, userToken, http.StatusNoContent},
+		{"cancel canceled booking", http.MethodDelete, path, userToken, http.StatusConflict},
+		{"confirm canceled booking", http.MethodPost, path + "/confirm", adminToken, http.StatusConflict},
+		{"check in canceled booking", http.MethodPost, path + "/check-in", userToken, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		if got := serveJSON(t, router, tt.method, tt.path, tt.token, nil).Code; got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConfirmRequiresElevatedRole(t *testing.T) {
+	router := newTestRouter(t, NewInMemoryBookingRepository(), newTestConfig())
+	ownerToken := testToken(t, 1, RoleUser)
+	booking := createTestBooking(t, router, ownerToken, time.Now().Add(24*time.Hour).Truncate(time.Hour))
+	path := "/api/v1/bookings/" + strconv.Itoa(booking.ID) + "/confirm"
+
+	if got := serveJSON(t, router, http.MethodPost, path, ownerToken, nil).Code; got != http.StatusForbidden {
+		t.Errorf("owner confirming: status %d, want %d", got, http.StatusForbidden)
+	}
+	if got := serveJSON(t, router, http.MethodPost, path, testToken(t, 2, RoleManager), nil).Code; got != http.StatusOK {
+		t.Errorf("manager confirming: status %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestWaiterAcceptsWaitlistOffer(t *testing.T) {
+	router := newTestRouter(t, NewInMemoryBookingRepository(), newTestConfig())
+	ownerToken := testToken(t, 1, RoleUser)
+	waiterToken := testToken(t, 2, RoleUser)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	booking := createTestBooking(t, router, ownerToken, start)
+
+	recorder := serveJSON(t, router, http.MethodPost, "/api/v1/waitlist", waiterToken,
+		JoinWaitlistRequest{ResourceID: 1, StartTime: start, EndTime: start.Add(time.Hour)})
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("POST /api/v1/waitlist: status %d: %s", recorder.Code, recorder.Body)
+	}
+	var entry WaitlistEntry
+	if err := json.NewDecoder(recorder.Body).Decode(&entry); err != nil {
+		t.Fatalf("failed to decode waitlist entry: %v", err)
+	}
+	path := "/api/v1/waitlist/" + strconv.Itoa(entry.ID) + "/accept"
+
+	if got := serveJSON(t, router, http.MethodPost, path, waiterToken, nil).Code; got != http.StatusConflict {
+		t.Errorf("accepting before an offer: status %d, want %d", got, http.StatusConflict)
+	}
+
+	// Canceling the booking offers the slot to the waiter
+	bookingPath := "/api/v1/bookings/" + strconv.Itoa(booking.ID)
+	if got := serveJSON(t, router, http.MethodDelete, bookingPath, ownerToken, nil).Code; got != http.StatusNoContent {
+		t.Fatalf("canceling the booking: status %d", got)
+	}
+	for _, token := range []string{ownerToken, testToken(t, 3, RoleAdmin)} {
+		if got := serveJSON(t, router, http.MethodPost, path, token, nil).Code; got != http.StatusForbidden {
+			t.Errorf("another user accepting: status %d, want %d", got, http.StatusForbidden)
+		}
+	}
+
+	recorder = serveJSON(t, router, http.MethodPost, path, waiterToken, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("waiter accepting: status %d: %s", recorder.Code, recorder.Body)
+	}
+	var offered Booking
+	if err := json.NewDecoder(recorder.Body).Decode(&offered); err != nil {
+		t.Fatalf("failed to decode booking: %v", err)
+	}
+	if offered.UserID != 2 || offered.Status != BookingStatusConfirmed || offered.ExpiresAt != nil {
+		t.Errorf("accepted booking of user %d has status %s and expiry %v", offered.UserID, offered.Status, offered.ExpiresAt)
+	}
+
+	if got := serveJSON(t, router, http.MethodPost, path, waiterToken, nil).Code; got != http.StatusConflict {
+		t.Errorf("accepting twice: status %d, want %d", got, http.StatusConflict)
+	}
+}
+
+func TestRescheduleBookingGroupStatusCodes(t *testing.T) {
+	router := newTestRouter(t, NewInMemoryBookingRepository(), newTestConfig())
+	token := testToken(t, 1, RoleUser)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	recorder := serveJSON(t, router, http.MethodPost, "/api/v1/booking-groups", token,
+		CreateBookingGroupRequest{ResourceIDs: []int{1, 2}, StartTime: start, EndTime: start.Add(time.Hour)})
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("POST /api/v1/booking-groups: status %d: %s", recorder.Code, recorder.Body)
+	}
+	var group BookingGroupWithMembers
+	if err := json.NewDecoder(recorder.Body).Decode(&group); err != nil {
+		t.Fatalf("failed to decode booking group: %v", err)
+	}
+	path := "/api/v1/booking-groups/" + strconv.Itoa(group.ID)
+
+	// Resource 1 is taken three hours later
+	taken := start.Add(3 * time.Hour)
+	createTestBooking(t, router, token, taken)
+
+	at := func(start time.Time, duration time.Duration) UpdateBookingRequest {
+		end := start.Add(duration)
+		return UpdateBookingRequest{StartTime: &start, EndTime: &end}
+	}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		want   int
+	}{
+		{"unknown group", http.MethodPut, "/api/v1/booking-groups/999", at(start, time.Hour), http.StatusNotFound},
+		{"end before start", http.MethodPut, path, at(start, -time.Hour), http.StatusBadRequest},
+		{"conflict", http.MethodPut, path, at(taken, time.Hour), http.StatusConflict},
+		{"reschedule", http.MethodPut, path, at(start.Add(time.Hour), time.Hour), http.StatusOK},
+		{"cancel unknown group", http.MethodDelete, "/api/v1/booking-groups/999", nil, http.StatusNotFound},
+		{"cancel", http.MethodDelete, path, nil, http.StatusOK},
+		{"reschedule canceled group", http.MethodPut, path, at(start, time.Hour), http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		if recorder := serveJSON(t, router, tt.method, tt.path, token, tt.body); recorder.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, recorder.Code, tt.want, recorder.Body)
+		}
+	}
+}
+
+func TestConflictingUpdateKeepsBooking(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo testRepository) {
+		router := newTestRouter(t, repo, newTestConfig())
+		token := testToken(t, repo.UserID, RoleUser)
+		start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+
+		var bookings []Booking
+		for _, slot := range []time.Time{start, start.Add(2 * time.Hour)} {
+			body := CreateBookingRequest{ResourceID: repo.ResourceID, StartTime: slot, EndTime: slot.Add(time.Hour), Notes: "planning"}
+			recorder := serveJSON(t, router, http.MethodPost, "/api/v1/bookings", token, body)
+			if recorder.Code != http.StatusCreated {
+				t.Fatalf("POST /api/v1/bookings: status %d: %s", recorder.Code, recorder.Body)
+			}
+			var booking Booking
+			if err := json.NewDecoder(recorder.Body).Decode(&booking); err != nil {
+				t.Fatalf("failed to decode booking: %v", err)
+			}
+			bookings = append(bookings, booking)
+		}
+
+		// The second booking moves onto the first one
+		second := bookings[1]
+		path := "/api/v1/bookings/" + strconv.Itoa(second.ID)
+		newStart, newEnd, notes := start.Add(30*time.Minute), start.Add(90*time.Minute), "moved"
+		recorder := serveJSON(t, router, http.MethodPut, path, token,
+			UpdateBookingRequest{StartTime: &newStart, EndTime: &newEnd, Notes: &notes})
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("conflicting PUT: status %d, want %d: %s", recorder.Code, http.StatusConflict, recorder.Body)
+		}
+
+		stored, err := repo.GetByID(second.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if !stored.StartTime.Equal(second.StartTime) || !stored.EndTime.Equal(second.EndTime) || stored.Notes != second.Notes {
+			t.Errorf("a conflicting PUT changed the booking to %s-%s with notes %q", stored.StartTime, stored.EndTime, stored.Notes)
+		}
+
+		// Peek at the outbox without marking anything delivered
+		_, err = repo.DeliverOutboxEvents(1000, func(events []*OutboxEvent) int {
+			for _, event := range events {
+				if event.BookingID == second.ID && event.Type == string(BookingEventUpdated) {
+					t.Errorf("a conflicting PUT queued %s", event.Type)
+				}
+			}
+			return 0
+		})
+		if err != nil {
+			t.Fatalf("DeliverOutboxEvents: %v", err)
+		}
+	})
+}
+
+// failingRepository fails every write of a new booking as an unreachable database would
+type failingRepository struct {
+	BookingRepository
+}
+
+func (failingRepository) CreateIfAvailable(*Booking, Occupancy, ...BookingEventType) error {
+	return errors.New("database is unavailable")
+}
+
+func TestCreateBookingFailureIsNotConflict(t *testing.T) {
+	router := newTestRouter(t, failingRepository{NewInMemoryBookingRepository()}, newTestConfig())
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	body := CreateBookingRequest{ResourceID: 1, StartTime: start, EndTime: start.Add(time.Hour)}
+
+	recorder := serveJSON(t, router, http.MethodPost, "/api/v1/bookings", testToken(t, 1, RoleUser), body)
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want %d: %s", recorder.Code, http.StatusInternalServerError, recorder.Body)
+	}
+}
+
+func TestAttendeeAccess(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.UserServiceURL = newTestUserService(t, User{ID: 2, Name: "Invitee", Role: RoleUser, IsActive: true})
+	router := newTestRouter(t, NewInMemoryBookingRepository(), cfg)
+	ownerToken := testToken(t, 1, RoleUser)
+	inviteeToken := testToken(t, 2, RoleUser)
+	booking := createTestBooking(t, router, ownerToken, time.Now().Add(24*time.Hour).Truncate(time.Hour))
+	path := "/api/v1/bookings/" + strconv.Itoa(booking.ID)
+
+	invite := func(req AddAttendeeRequest) string {
+		t.Helper()
+		recorder := serveJSON(t, router, http.MethodPost, path+"/attendees", ownerToken, req)
+		if recorder.Code != http.StatusCreated {
+			t.Fatalf("POST attendees: status %d: %s", recorder.Code, recorder.Body)
+		}
+		var attendee Attendee
+		if err := json.NewDecoder(recorder.Body).Decode(&attendee); err != nil {
+			t.Fatalf("failed to decode attendee: %v", err)
+		}
+		return path + "/attendees/" + strconv.Itoa(attendee.ID) + "/rsvp"
+	}
+	userRSVP := invite(AddAttendeeRequest{UserID: 2})
+	guestRSVP := invite(AddAttendeeRequest{Email: "guest@example.com"})
+	accept := RSVPRequest{Status: RSVPAccepted}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"invitee reads the booking", http.MethodGet, path, inviteeToken, http.StatusOK},
+		{"stranger reads the booking", http.MethodGet, path, testToken(t, 3, RoleUser), http.StatusForbidden},
+		{"owner answers for a user", http.MethodPut, userRSVP, ownerToken, http.StatusForbidden},
+		{"manager answers for a user", http.MethodPut, userRSVP, testToken(t, 4, RoleManager), http.StatusForbidden},
+		{"invitee answers", http.MethodPut, userRSVP, inviteeToken, http.StatusOK},
+		{"admin answers for a user", http.MethodPut, userRSVP, testToken(t, 5, RoleAdmin), http.StatusOK},
+		{"owner answers for a guest", http.MethodPut, guestRSVP, ownerToken, http.StatusOK},
+		{"invitee answers for a guest", http.MethodPut, guestRSVP, inviteeToken, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		if recorder := serveJSON(t, router, tt.method, tt.path, tt.token, accept); recorder.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, recorder.Code, tt.want, recorder.Body)
+		}
+	}
+}
+
+func TestCompleteSweepRequiresAdmin(t *testing.T) {
+	router := newTestRouter(t, NewInMemoryBookingRepository(), newTestConfig())
+
+	tests := []struct {
+		role string
+		want int
+	}{
+		{RoleUser, http.StatusForbidden},
+		{RoleManager, http.StatusForbidden},
+		{RoleAdmin, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		recorder := serveJSON(t, router, http.MethodPost, "/api/v1/admin/bookings/complete-sweep", testToken(t, 1, tt.role), nil)
+		if recorder.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.role, recorder.Code, tt.want, recorder.Body)
+		}
+	}
+}
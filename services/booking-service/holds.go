@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrHoldExpired is returned when confirming a PENDING booking whose hold already expired
+var ErrHoldExpired = errors.New("booking hold has expired")
+
+// holdExpiry returns when a PENDING booking created now for the resource stops
+// holding its slot. Per-type timeouts need the resource type, so the resource
+// is only looked up, through the resource cache, when such overrides are
+// configured.
+func (s *BookingService) holdExpiry(resourceID int, now time.Time) *time.Time {
+	timeout := s.config.HoldTimeout
+
+	if len(s.config.HoldTimeoutByType) > 0 {
+		resource, err := s.cachedResource(resourceID)
+		if err != nil {
+			log.Printf("Using default hold timeout for resource %d: %v", resourceID, err)
+		} else if typeTimeout, ok := s.config.HoldTimeoutByType[resource.Type]; ok {
+			timeout = typeTimeout
+		}
+	}
+
+	expiresAt := now.Add(timeout)
+	return &expiresAt
+}
+
+// ExpireHolds cancels PENDING bookings whose hold expired and frees their slots
+func (s *BookingService) ExpireHolds(now time.Time) error {
+	bookings, err := s.repository.GetExpiredHolds(now)
+	if err != nil {
+		return fmt.Errorf("failed to get expired holds: %w", err)
+	}
+
+	for _, booking := range bookings {
+		// A concurrent confirmation or another replica wins silently
+		if err := s.cancel(booking, CancellationReasonHoldExpired); err != nil && !errors.Is(err, ErrBookingChanged) {
+			log.Printf("Error expiring booking %d: %v", booking.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// RunHoldExpiryWorker periodically expires PENDING bookings until the context is done
+func (s *BookingService) RunHoldExpiryWorker(ctx context.Context) {
+	runPeriodically(ctx, "Hold expiry", s.config.HoldSweepInterval, s.ExpireHolds)
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidImport is returned for files that cannot be read as a whole
+	ErrInvalidImport = errors.New("invalid import file")
+	// ErrInvalidImportRow is returned for rows that cannot be mapped to a booking
+	ErrInvalidImportRow = errors.New("invalid row")
+)
+
+// maxImportRows bounds the rows of one import file
+const maxImportRows = 5000
+
+// ImportFormat is the file format of a booking import
+type ImportFormat string
+
+const (
+	ImportFormatCSV ImportFormat = "csv"
+	ImportFormatICS ImportFormat = "ics"
+)
+
+// ImportOptions controls a booking import
+type ImportOptions struct {
+	Format ImportFormat
+	// DryRun validates every row without storing anything
+	DryRun bool
+	// UserID and ResourceID are used for rows that name no user or resource,
+	// such as the events of an iCalendar file
+	UserID     int
+	ResourceID int
+	// Location is the time zone of times without an offset
+	Location *time.Location
+}
+
+// ImportRow is one booking read from an import file
+type ImportRow struct {
+	// Row is the line of a CSV file or the position of an iCalendar event
+	Row int `json:"row"`
+	// UID identifies the event of an iCalendar file
+	UID    string `json:"uid,omitempty"`
+	UserID int    `json:"user_id"`
+	CreateBookingRequest
+	// err is why the row could not be read
+	err error
+}
+
+// Import row outcomes
+const (
+	ImportRowValid    = "valid"
+	ImportRowImported = "imported"
+	ImportRowSkipped  = "skipped"
+	ImportRowFailed   = "failed"
+)
+
+// ImportRowResult is the outcome of one row
+type ImportRowResult struct {
+	ImportRow
+	Status    string `json:"status"`
+	BookingID int    `json:"booking_id,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportResult summarizes an import. In a dry run Valid counts the rows that
+// would be imported; otherwise Imported counts the stored ones.
+type ImportResult struct {
+	DryRun   bool               `json:"dry_run"`
+	Total    int                `json:"total"`
+	Valid    int                `json:"valid"`
+	Imported int                `json:"imported"`
+	Skipped  int                `json:"skipped"`
+	Failed   int                `json:"failed"`
+	Rows     []*ImportRowResult `json:"rows"`
+}
+
+// Import re-creates existing reservations from a CSV or iCalendar file. Each row
+// is validated like a booking its user makes (resource, opening hours, policies,
+// capacity and conflicts, including with earlier rows of the file) and stored as
+// CONFIRMED. Rows are independent: a failed row does not stop the others. Quotas
+// do not apply, as for any booking made on someone else's behalf.
+func (s *BookingService) Import(caller Identity, file io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	var rows []*ImportRow
+	var err error
+	switch opts.Format {
+	case ImportFormatCSV:
+		rows, err = parseImportCSV(file, opts)
+	case ImportFormatICS:
+		rows, err = parseImportICS(file, opts)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidImport, opts.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{DryRun: opts.DryRun, Total: len(rows), Rows: make([]*ImportRowResult, 0, len(rows))}
+	owners := make(map[int]*User)
+	// Bookings accepted in a dry run, by resource, so later rows conflict with them
+	staged := make(map[int][]*Booking)
+	for _, row := range rows {
+		rowResult := &ImportRowResult{ImportRow: *row}
+		result.Rows = append(result.Rows, rowResult)
+
+		if errors.Is(row.err, errImportRowSkipped) {
+			rowResult.Status = ImportRowSkipped
+			rowResult.Error = row.err.Error()
+			result.Skipped++
+			continue
+		}
+
+		booking, err := s.importRow(caller, row, owners, staged, opts.DryRun)
+		if err != nil {
+			rowResult.Status = ImportRowFailed
+			rowResult.Code = importErrorCode(err)
+			rowResult.Error = err.Error()
+			result.Failed++
+			continue
+		}
+
+		if opts.DryRun {
+			rowResult.Status = ImportRowValid
+			result.Valid++
+		} else {
+			rowResult.Status = ImportRowImported
+			rowResult.BookingID = booking.ID
+			result.Imported++
+		}
+	}
+
+	return result, nil
+}
+
+// importRow validates one row and, unless this is a dry run, stores its booking
+func (s *BookingService) importRow(caller Identity, row *ImportRow, owners map[int]*User, staged map[int][]*Booking, dryRun bool) (*Booking, error) {
+	if row.err != nil {
+		return nil, row.err
+	}
+
+	switch {
+	case row.UserID <= 0:
+		return nil, fmt.Errorf("%w: user_id is required", ErrInvalidImportRow)
+	case row.ResourceID <= 0:
+		return nil, fmt.Errorf("%w: resource_id is required", ErrInvalidImportRow)
+	case !row.StartTime.Before(row.EndTime):
+		return nil, fmt.Errorf("%w: end time must be after start time", ErrInvalidImportRow)
+	case row.StartTime.Before(time.Now()):
+		return nil, fmt.Errorf("%w: cannot create booking in the past", ErrInvalidImportRow)
+	case row.Seats < 0:
+		return nil, fmt.Errorf("%w: seats must be positive", ErrInvalidImportRow)
+	}
+
+	owner, ok := owners[row.UserID]
+	if !ok {
+		var err error
+		if owner, err = s.users.GetUser(row.UserID); err != nil {
+			if !errors.Is(err, ErrUserNotFound) {
+				return nil, err
+			}
+		}
+		owners[row.UserID] = owner
+	}
+	if owner == nil {
+		return nil, ErrUserNotFound
+	}
+
+	booking, occupancy, err := s.newBooking(caller, Identity{UserID: row.UserID, Role: owner.Role}, row.CreateBookingRequest)
+	if err != nil {
+		return nil, err
+	}
+	booking.Status = BookingStatusConfirmed
+	booking.ExpiresAt = nil
+
+	if !dryRun {
+		if err := s.repository.CreateIfAvailable(booking, occupancy, BookingEventCreated); err != nil {
+			if errors.Is(err, ErrBookingConflict) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to create booking: %w", err)
+		}
+		return booking, nil
+	}
+
+	existing, err := s.conflictingBookings(booking.ResourceID, booking.StartTime, booking.EndTime, occupancy.Buffers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check conflicts: %w", err)
+	}
+	gap := occupancy.Gap()
+	for _, other := range staged[booking.ResourceID] {
+		if other.StartTime.Before(booking.EndTime.Add(gap)) && other.EndTime.After(booking.StartTime.Add(-gap)) {
+			existing = append(existing, other)
+		}
+	}
+	if !occupancy.Fits(booking, existing) {
+		return nil, ErrBookingConflict
+	}
+
+	staged[booking.ResourceID] = append(staged[booking.ResourceID], booking)
+	return booking, nil
+}
+
+// importErrorCode returns the ErrorResponse code that describes why a row failed
+func importErrorCode(err error) string {
+	var violation *PolicyViolationError
+	var exceeded *QuotaExceededError
+	switch {
+	case errors.Is(err, ErrInvalidImportRow):
+		return ErrorCodeInvalidImportRow
+	case errors.Is(err, ErrBookingConflict):
+		return ErrorCodeBookingConflict
+	case errors.As(err, &violation):
+		return ErrorCodePolicyViolation
+	case errors.As(err, &exceeded):
+		return ErrorCodeQuotaExceeded
+	case errors.Is(err, ErrResourceNotFound):
+		return ErrorCodeResourceNotFound
+	case errors.Is(err, ErrResourceInactive):
+		return ErrorCodeResourceInactive
+	case errors.Is(err, ErrOutsideOpeningHours):
+		return ErrorCodeOutsideOpeningHours
+	case errors.Is(err, ErrResourceServiceUnavailable):
+		return ErrorCodeResourceServiceUnavailable
+	case errors.Is(err, ErrSeatsExceedCapacity):
+		return ErrorCodeSeatsExceedCapacity
+	case errors.Is(err, ErrUserNotFound):
+		return ErrorCodeUserNotFound
+	case errors.Is(err, ErrUserServiceUnavailable):
+		return ErrorCodeUserServiceUnavailable
+	default:
+		return ErrorCodeInternal
+	}
+}
+
+// importReportHeader is the header of the error report written by WriteImportReport
+var importReportHeader = []string{
+	"row", "uid", "user_id", "resource_id", "start_time", "end_time", "seats", "notes", "code", "error",
+}
+
+// WriteImportReport writes the failed rows of an import as CSV, so they can be
+// fixed and imported again
+func WriteImportReport(w io.Writer, result *ImportResult) error {
+	report := csv.NewWriter(w)
+	if err := report.Write(importReportHeader); err != nil {
+		return err
+	}
+
+	for _, row := range result.Rows {
+		if row.Status != ImportRowFailed {
+			continue
+		}
+
+		record := []string{
+			strconv.Itoa(row.Row), row.UID, "", "", "", "", "", row.Notes, row.Code, row.Error,
+		}
+		if row.UserID > 0 {
+			record[2] = strconv.Itoa(row.UserID)
+		}
+		if row.ResourceID > 0 {
+			record[3] = strconv.Itoa(row.ResourceID)
+		}
+		if !row.StartTime.IsZero() {
+			record[4] = row.StartTime.Format(time.RFC3339)
+		}
+		if !row.EndTime.IsZero() {
+			record[5] = row.EndTime.Format(time.RFC3339)
+		}
+		if row.Seats > 0 {
+			record[6] = strconv.Itoa(row.Seats)
+		}
+		if err := report.Write(record); err != nil {
+			return err
+		}
+	}
+
+	report.Flush()
+	return report.Error()
+}
+
+// parseImportCSV reads a CSV file with a header row. The columns are user_id,
+// resource_id, start_time and end_time, and optionally notes and seats; others
+// are ignored. Times are RFC 3339 or "2006-01-02 15:04" in opts.Location.
+func parseImportCSV(file io.Reader, opts ImportOptions) ([]*ImportRow, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot read the header: %v", ErrInvalidImport, err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"start_time", "end_time"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing column %s", ErrInvalidImport, required)
+		}
+	}
+
+	var rows []*ImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidImport, maxImportRows)
+		}
+
+		row := &ImportRow{Row: line, UserID: opts.UserID}
+		row.ResourceID = opts.ResourceID
+		rows = append(rows, row)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+			}
+			row.err = fmt.Errorf("%w: %v", ErrInvalidImportRow, parseErr.Err)
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row.Notes = field("notes")
+		var problems []string
+		for _, err := range []error{
+			parseImportInt(field("user_id"), "user_id", &row.UserID),
+			parseImportInt(field("resource_id"), "resource_id", &row.ResourceID),
+			parseImportInt(field("seats"), "seats", &row.Seats),
+			parseImportTime(field("start_time"), "start_time", opts.Location, &row.StartTime),
+			parseImportTime(field("end_time"), "end_time", opts.Location, &row.EndTime),
+		} {
+			if err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+		if len(problems) > 0 {
+			row.err = fmt.Errorf("%w: %s", ErrInvalidImportRow, strings.Join(problems, "; "))
+		}
+	}
+
+	return rows, nil
+}
+
+// parseImportInt sets target from a non-empty value
+func parseImportInt(value, name string, target *int) error {
+	if value == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s %q is not a number", name, value)
+	}
+	*target = n
+	return nil
+}
+
+// parseImportTime sets target from an RFC 3339 time or a local time in location
+func parseImportTime(value, name string, location *time.Location, target *time.Time) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", name)
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		*target = t
+		return nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			*target = t
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s %q is not a valid time", name, value)
+}
+
+// errImportRowSkipped marks iCalendar events that are not imported on purpose
+var errImportRowSkipped = errors.New("skipped")
+
+// parseImportICS reads the VEVENTs of an iCalendar (RFC 5545) file. DTSTART and
+// DTEND or DURATION give the time, SUMMARY and DESCRIPTION the notes; user and
+// resource come from opts. Cancelled events are skipped, and all-day and
+// recurring events are reported as invalid.
+func parseImportICS(file io.Reader, opts ImportOptions) ([]*ImportRow, error) {
+	lines, err := unfoldICS(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("%w: not an iCalendar file", ErrInvalidImport)
+	}
+
+	var rows []*ImportRow
+	var event map[string]icsProperty
+	// nested counts the open components inside an event, such as VALARM, whose
+	// properties are ignored
+	nested := 0
+	for _, line := range lines {
+		property := parseICSProperty(line)
+		switch {
+		case event == nil:
+			if property.name == "BEGIN" && strings.EqualFold(property.value, "VEVENT") {
+				event = make(map[string]icsProperty)
+			}
+		case property.name == "BEGIN":
+			nested++
+		case property.name == "END" && nested > 0:
+			nested--
+		case property.name == "END":
+			if len(rows) == maxImportRows {
+				return nil, fmt.Errorf("%w: more than %d events", ErrInvalidImport, maxImportRows)
+			}
+			rows = append(rows, icsEventRow(len(rows)+1, event, opts))
+			event = nil
+		case nested == 0:
+			event[property.name] = property
+		}
+	}
+
+	return rows, nil
+}
+
+// icsEventRow maps the properties of one VEVENT to a row
+func icsEventRow(position int, event map[string]icsProperty, opts ImportOptions) *ImportRow {
+	row := &ImportRow{Row: position, UID: event["UID"].value, UserID: opts.UserID}
+	row.ResourceID = opts.ResourceID
+
+	notes := unescapeICSText(event["SUMMARY"].value)
+	if description := unescapeICSText(event["DESCRIPTION"].value); description != "" {
+		notes = strings.TrimSpace(notes + "\n" + description)
+	}
+	row.Notes = notes
+
+	var problem error
+	start, err := event["DTSTART"].time(opts.Location)
+	if err != nil {
+		problem = fmt.Errorf("DTSTART: %v", err)
+	}
+	row.StartTime = start
+
+	switch {
+	case event["DTEND"].value != "":
+		if row.EndTime, err = event["DTEND"].time(opts.Location); err != nil && problem == nil {
+			problem = fmt.Errorf("DTEND: %v", err)
+		}
+	case event["DURATION"].value != "":
+		duration, err := parseICSDuration(event["DURATION"].value)
+		if err != nil && problem == nil {
+			problem = fmt.Errorf("DURATION: %v", err)
+		}
+		if problem == nil {
+			row.EndTime = start.Add(duration)
+		}
+	case problem == nil:
+		problem = errors.New("DTEND or DURATION is required")
+	}
+
+	switch {
+	case strings.EqualFold(event["STATUS"].value, "CANCELLED"):
+		row.err = fmt.Errorf("%w: cancelled event", errImportRowSkipped)
+	case event["RRULE"].value != "" || event["RDATE"].value != "":
+		row.err = fmt.Errorf("%w: recurring events are not supported", ErrInvalidImportRow)
+	case problem != nil:
+		row.err = fmt.Errorf("%w: %v", ErrInvalidImportRow, problem)
+	}
+
+	return row
+}
+
+// icsProperty is one content line: NAME;PARAM=VALUE:VALUE
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICSProperty splits a content line. Colons inside quoted parameter values
+// do not end the name.
+func parseICSProperty(line string) icsProperty {
+	quoted := false
+	split := len(line)
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if c == ':' && !quoted {
+			split = i
+			break
+		}
+	}
+
+	property := icsProperty{params: make(map[string]string)}
+	if split < len(line) {
+		property.value = line[split+1:]
+	}
+
+	parts := strings.Split(line[:split], ";")
+	property.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		if name, value, ok := strings.Cut(param, "="); ok {
+			property.params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+		}
+	}
+
+	return property
+}
+
+// time parses a DATE-TIME value: UTC with a Z suffix, in the TZID parameter's
+// zone, or floating in location. DATE values (all-day events) are rejected.
+func (p icsProperty) time(location *time.Location) (time.Time, error) {
+	if p.value == "" {
+		return time.Time{}, errors.New("missing")
+	}
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(p.value) == len("20060102") {
+		return time.Time{}, errors.New("all-day events are not supported")
+	}
+
+	if strings.HasSuffix(p.value, "Z") {
+		return time.Parse("20060102T150405Z", p.value)
+	}
+	if tzid := p.params["TZID"]; tzid != "" {
+		zone, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unknown time zone %q", tzid)
+		}
+		location = zone
+	}
+	return time.ParseInLocation("20060102T150405", p.value, location)
+}
+
+// parseICSDuration parses a non-negative RFC 5545 duration such as PT1H30M or P1D
+func parseICSDuration(value string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(value, "+"), "P")
+	if !ok {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+	var total time.Duration
+	for rest != "" {
+		if rest[0] == 'T' {
+			units = map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+			rest = rest[1:]
+			continue
+		}
+
+		end := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		if end <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		unit, ok := units[rest[end]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		n, err := strconv.Atoi(rest[:end])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		total += time.Duration(n) * unit
+		rest = rest[end+1:]
+	}
+
+	return total, nil
+}
+
+var icsUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// unescapeICSText decodes a TEXT value
+func unescapeICSText(value string) string {
+	return strings.TrimSpace(icsUnescaper.Replace(value))
+}
+
+// unfoldICS returns the content lines of an iCalendar file, joining folded lines
+func unfoldICS(file io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, strings.TrimPrefix(line, "\ufeff"))
+	}
+
+	return lines, scanner.Err()
+}
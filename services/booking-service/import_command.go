@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runImportCommand implements "booking-service import", which imports a CSV or
+// iCalendar file straight into the configured storage. Events of imported
+// bookings are left in the outbox for the running service to deliver. It
+// returns the process exit code: 1 if the file or any row failed.
+func runImportCommand(cfg Config, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "file format, csv or ics (default: from the file extension)")
+	commit := flags.Bool("commit", false, "store the valid rows; without it rows are only validated")
+	userID := flags.Int("user-id", 0, "owner of rows that name no user")
+	resourceID := flags.Int("resource-id", 0, "resource of rows that name no resource")
+	tz := flags.String("tz", "UTC", "time zone of times without an offset")
+	reportPath := flags.String("report", "", "write the failed rows as CSV to this file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: booking-service import [flags] FILE")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// Rows committed to memory storage would vanish when the command exits
+	if *commit && cfg.StorageDriver != StorageDriverPostgres {
+		fmt.Fprintf(os.Stderr, "-commit needs STORAGE_DRIVER=%s, not %q\n", StorageDriverPostgres, cfg.StorageDriver)
+		return 2
+	}
+
+	path := flags.Arg(0)
+	opts := ImportOptions{
+		Format:     ImportFormat(*format),
+		DryRun:     !*commit,
+		UserID:     *userID,
+		ResourceID: *resourceID,
+	}
+	if opts.Format == "" {
+		opts.Format = ImportFormat(strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."))
+	}
+
+	location, err := time.LoadLocation(*tz)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid time zone %q: %v\n", *tz, err)
+		return 2
+	}
+	opts.Location = location
+
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	repo, err := NewBookingRepository(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize storage: %v\n", err)
+		return 1
+	}
+	service := NewBookingService(repo, cfg)
+
+	result, err := service.Import(Identity{Role: RoleAdmin}, file, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	for _, row := range result.Rows {
+		if row.Status == ImportRowFailed {
+			fmt.Printf("row %d: %s: %s\n", row.Row, row.Code, row.Error)
+		}
+	}
+	if result.DryRun {
+		fmt.Printf("dry run: %d rows, %d valid, %d skipped, %d failed\n", result.Total, result.Valid, result.Skipped, result.Failed)
+	} else {
+		fmt.Printf("%d rows, %d imported, %d skipped, %d failed\n", result.Total, result.Imported, result.Skipped, result.Failed)
+	}
+
+	if *reportPath != "" {
+		report, err := os.Create(*reportPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer report.Close()
+		if err := WriteImportReport(report, result); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write report: %v\n", err)
+			return 1
+		}
+	}
+
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
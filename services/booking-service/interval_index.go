@@ -0,0 +1,215 @@
+package main
+
+import (
+	"time"
+)
+
+// intervalTree is a treap of bookings ordered by (StartTime, ID). Every node keeps
+// the latest EndTime of the active (non-canceled) bookings in its subtree, so
+// overlap queries skip whole subtrees and cost O(log n + k).
+type intervalTree struct {
+	root *intervalNode
+	seed uint64
+}
+
+type intervalNode struct {
+	booking  *Booking
+	start    time.Time
+	id       int
+	priority uint64
+	size     int
+	maxEnd   time.Time // latest EndTime of active bookings in this subtree
+	left     *intervalNode
+	right    *intervalNode
+}
+
+func newIntervalTree() *intervalTree {
+	return &intervalTree{seed: 0x9E3779B97F4A7C15}
+}
+
+// Insert adds a booking to the tree. The booking must not be mutated while indexed.
+func (t *intervalTree) Insert(booking *Booking) {
+	node := &intervalNode{
+		booking:  booking,
+		start:    booking.StartTime,
+		id:       booking.ID,
+		priority: t.nextPriority(),
+	}
+	node.update()
+	t.root = insertNode(t.root, node)
+}
+
+// Remove deletes the booking indexed with the given start time and ID
+func (t *intervalTree) Remove(start time.Time, id int) {
+	t.root = removeNode(t.root, start, id)
+}
+
+// Overlapping calls fn for every active booking overlapping [start, end) in start order
+func (t *intervalTree) Overlapping(start, end time.Time, fn func(*Booking)) {
+	var visit func(n *intervalNode)
+	visit = func(n *intervalNode) {
+		if n == nil || !n.maxEnd.After(start) {
+			return
+		}
+		visit(n.left)
+		if !n.start.Before(end) {
+			return // this node and the right subtree start too late
+		}
+		if n.booking.Status != BookingStatusCanceled && n.booking.EndTime.After(start) {
+			fn(n.booking)
+		}
+		visit(n.right)
+	}
+	visit(t.root)
+}
+
+// AscendFrom calls fn in start order for every booking starting at or after from,
+// until fn returns false
+func (t *intervalTree) AscendFrom(from time.Time, fn func(*Booking) bool) {
+	var visit func(n *intervalNode) bool
+	visit = func(n *intervalNode) bool {
+		if n == nil {
+			return true
+		}
+		if n.start.Before(from) {
+			return visit(n.right)
+		}
+		return visit(n.left) && fn(n.booking) && visit(n.right)
+	}
+	visit(t.root)
+}
+
+// Page calls fn in start order for at most limit bookings after skipping offset.
+// Subtree sizes let it skip the offset in O(log n).
+func (t *intervalTree) Page(offset, limit int, fn func(*Booking)) {
+	var visit func(n *intervalNode)
+	visit = func(n *intervalNode) {
+		if n == nil || limit <= 0 {
+			return
+		}
+		if skip := n.left.getSize() + 1; offset >= skip {
+			offset -= skip
+			visit(n.right)
+			return
+		}
+		visit(n.left)
+		if limit <= 0 {
+			return
+		}
+		if offset > 0 {
+			offset--
+		} else {
+			fn(n.booking)
+			limit--
+		}
+		visit(n.right)
+	}
+	visit(t.root)
+}
+
+// nextPriority returns a pseudo-random heap priority (xorshift64)
+func (t *intervalTree) nextPriority() uint64 {
+	t.seed ^= t.seed << 13
+	t.seed ^= t.seed >> 7
+	t.seed ^= t.seed << 17
+	return t.seed
+}
+
+func (n *intervalNode) getSize() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// less reports whether the node sorts before the given key
+func (n *intervalNode) less(start time.Time, id int) bool {
+	if n.start.Equal(start) {
+		return n.id < id
+	}
+	return n.start.Before(start)
+}
+
+// update recomputes the subtree size and active end time from the children
+func (n *intervalNode) update() {
+	n.size = 1 + n.left.getSize() + n.right.getSize()
+	n.maxEnd = time.Time{}
+	if n.booking.Status != BookingStatusCanceled {
+		n.maxEnd = n.booking.EndTime
+	}
+	if n.left != nil && n.left.maxEnd.After(n.maxEnd) {
+		n.maxEnd = n.left.maxEnd
+	}
+	if n.right != nil && n.right.maxEnd.After(n.maxEnd) {
+		n.maxEnd = n.right.maxEnd
+	}
+}
+
+func insertNode(n, node *intervalNode) *intervalNode {
+	if n == nil {
+		return node
+	}
+	if node.priority > n.priority {
+		node.left, node.right = splitNode(n, node.start, node.id)
+		node.update()
+		return node
+	}
+	if n.less(node.start, node.id) {
+		n.right = insertNode(n.right, node)
+	} else {
+		n.left = insertNode(n.left, node)
+	}
+	n.update()
+	return n
+}
+
+func removeNode(n *intervalNode, start time.Time, id int) *intervalNode {
+	if n == nil {
+		return nil
+	}
+	if n.id == id && n.start.Equal(start) {
+		return mergeNodes(n.left, n.right)
+	}
+	if n.less(start, id) {
+		n.right = removeNode(n.right, start, id)
+	} else {
+		n.left = removeNode(n.left, start, id)
+	}
+	n.update()
+	return n
+}
+
+// splitNode splits a subtree into nodes sorting before the key and the rest
+func splitNode(n *intervalNode, start time.Time, id int) (*intervalNode, *intervalNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.less(start, id) {
+		left, right := splitNode(n.right, start, id)
+		n.right = left
+		n.update()
+		return n, right
+	}
+	left, right := splitNode(n.left, start, id)
+	n.left = right
+	n.update()
+	return left, n
+}
+
+// mergeNodes joins two subtrees where every key in left sorts before right
+func mergeNodes(left, right *intervalNode) *intervalNode {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	if left.priority > right.priority {
+		left.right = mergeNodes(left.right, right)
+		left.update()
+		return left
+	}
+	right.left = mergeNodes(left, right.left)
+	right.update()
+	return right
+}
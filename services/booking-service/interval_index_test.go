@@ -0,0 +1,373 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testIndexBase is the earliest start time of generated bookings
+var testIndexBase = time.Date(2030, time.January, 7, 8, 0, 0, 0, time.UTC)
+
+// generateBookings returns n bookings with IDs 1 to n spread over span. Starts
+// fall on 15-minute steps, so many bookings share a start time and sort by ID,
+// and about one in ten is canceled.
+func generateBookings(n int, span time.Duration, seed uint64) []*Booking {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	steps := max(int64(span/(15*time.Minute)), 1)
+
+	bookings := make([]*Booking, n)
+	for i := range bookings {
+		start := testIndexBase.Add(time.Duration(rng.Int64N(steps)) * 15 * time.Minute)
+		status := BookingStatusConfirmed
+		if rng.IntN(10) == 0 {
+			status = BookingStatusCanceled
+		}
+		bookings[i] = &Booking{
+			ID:        i + 1,
+			StartTime: start,
+			EndTime:   start.Add(time.Duration(1+rng.IntN(16)) * 15 * time.Minute),
+			Status:    status,
+		}
+	}
+	return bookings
+}
+
+// buildTree indexes the bookings in the given order
+func buildTree(bookings []*Booking) *intervalTree {
+	tree := newIntervalTree()
+	for _, booking := range bookings {
+		tree.Insert(booking)
+	}
+	return tree
+}
+
+// bookingBefore is the (StartTime, ID) order of the index
+func bookingBefore(a, b *Booking) int {
+	if c := a.StartTime.Compare(b.StartTime); c != 0 {
+		return c
+	}
+	return a.ID - b.ID
+}
+
+// sortedBookings is the brute-force index: the bookings in index order
+func sortedBookings(bookings []*Booking) []*Booking {
+	sorted := slices.Clone(bookings)
+	slices.SortFunc(sorted, bookingBefore)
+	return sorted
+}
+
+// collect returns the bookings of a subtree in order
+func collect(n *intervalNode) []*Booking {
+	var bookings []*Booking
+	var visit func(n *intervalNode)
+	visit = func(n *intervalNode) {
+		if n == nil {
+			return
+		}
+		visit(n.left)
+		bookings = append(bookings, n.booking)
+		visit(n.right)
+	}
+	visit(n)
+	return bookings
+}
+
+// checkNode verifies the treap invariants of a subtree: keys in order, parents
+// with higher priorities than their children, and correct sizes and end times
+func checkNode(t *testing.T, n *intervalNode) {
+	t.Helper()
+	if n == nil {
+		return
+	}
+
+	for _, child := range []*intervalNode{n.left, n.right} {
+		if child != nil && child.priority > n.priority {
+			t.Fatalf("node %d has a child %d with a higher priority", n.id, child.id)
+		}
+	}
+
+	bookings := collect(n)
+	if !slices.IsSortedFunc(bookings, bookingBefore) {
+		t.Fatalf("subtree of node %d is out of order", n.id)
+	}
+	if n.size != len(bookings) {
+		t.Fatalf("node %d has size %d, want %d", n.id, n.size, len(bookings))
+	}
+	var maxEnd time.Time
+	for _, booking := range bookings {
+		if booking.Status != BookingStatusCanceled && booking.EndTime.After(maxEnd) {
+			maxEnd = booking.EndTime
+		}
+	}
+	if !n.maxEnd.Equal(maxEnd) {
+		t.Fatalf("node %d has maxEnd %s, want %s", n.id, n.maxEnd, maxEnd)
+	}
+
+	checkNode(t, n.left)
+	checkNode(t, n.right)
+}
+
+// checkTree verifies the invariants of the tree and that it holds want in order
+func checkTree(t *testing.T, root *intervalNode, want []*Booking) {
+	t.Helper()
+	checkNode(t, root)
+	if got := collect(root); !slices.Equal(bookingIDs(got), bookingIDs(want)) {
+		t.Fatalf("tree holds %v, want %v", bookingIDs(got), bookingIDs(want))
+	}
+}
+
+func bookingIDs(bookings []*Booking) []int {
+	ids := make([]int, len(bookings))
+	for i, booking := range bookings {
+		ids[i] = booking.ID
+	}
+	return ids
+}
+
+func TestIntervalTreeInsertRemove(t *testing.T) {
+	at := func(minutes int) time.Time { return testIndexBase.Add(time.Duration(minutes) * time.Minute) }
+	booking := func(id, start, end int) *Booking {
+		return &Booking{ID: id, StartTime: at(start), EndTime: at(end), Status: BookingStatusConfirmed}
+	}
+	canceled := func(b *Booking) *Booking {
+		b.Status = BookingStatusCanceled
+		return b
+	}
+
+	tests := []struct {
+		name     string
+		bookings []*Booking
+		// remove lists the IDs removed after every booking is inserted
+		remove []int
+	}{
+		{name: "empty"},
+		{name: "single", bookings: []*Booking{booking(1, 0, 60)}, remove: []int{1}},
+		{
+			name:     "ascending starts",
+			bookings: []*Booking{booking(1, 0, 60), booking(2, 30, 90), booking(3, 60, 120), booking(4, 90, 150)},
+			remove:   []int{1, 4},
+		},
+		{
+			name:     "descending starts",
+			bookings: []*Booking{booking(4, 90, 150), booking(3, 60, 120), booking(2, 30, 90), booking(1, 0, 60)},
+			remove:   []int{3},
+		},
+		{
+			name:     "shared start ordered by ID",
+			bookings: []*Booking{booking(3, 0, 30), booking(1, 0, 90), booking(2, 0, 60), booking(4, 0, 15)},
+			remove:   []int{2, 3},
+		},
+		{
+			name:     "canceled bookings keep their place",
+			bookings: []*Booking{booking(1, 0, 600), canceled(booking(2, 10, 900)), booking(3, 20, 30), canceled(booking(4, 40, 50))},
+			remove:   []int{1},
+		},
+		{
+			name:     "missing ID",
+			bookings: []*Booking{booking(1, 0, 60), booking(2, 30, 90)},
+			remove:   []int{5},
+		},
+		{name: "generated", bookings: generateBookings(500, 48*time.Hour, 1), remove: []int{1, 50, 250, 499, 500, 1000}},
+		{name: "generated crowded", bookings: generateBookings(500, time.Hour, 2), remove: []int{7, 8, 9, 100, 300}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root *intervalNode
+			tree := newIntervalTree()
+			for i, b := range tt.bookings {
+				node := &intervalNode{booking: b, start: b.StartTime, id: b.ID, priority: tree.nextPriority()}
+				node.update()
+				root = insertNode(root, node)
+				checkTree(t, root, sortedBookings(tt.bookings[:i+1]))
+			}
+
+			want := sortedBookings(tt.bookings)
+			for _, id := range tt.remove {
+				start := testIndexBase
+				if i := slices.IndexFunc(want, func(b *Booking) bool { return b.ID == id }); i >= 0 {
+					start = want[i].StartTime
+					want = slices.Delete(want, i, i+1)
+				}
+				root = removeNode(root, start, id)
+				checkTree(t, root, want)
+			}
+		})
+	}
+}
+
+func TestIntervalTreeSplitMerge(t *testing.T) {
+	bookings := generateBookings(300, 24*time.Hour, 3)
+	sorted := sortedBookings(bookings)
+
+	tests := []struct {
+		name  string
+		start time.Time
+		id    int
+	}{
+		{name: "before every booking", start: testIndexBase.Add(-time.Hour)},
+		{name: "after every booking", start: testIndexBase.Add(48 * time.Hour)},
+		{name: "first booking", start: sorted[0].StartTime, id: sorted[0].ID},
+		{name: "last booking", start: sorted[len(sorted)-1].StartTime, id: sorted[len(sorted)-1].ID},
+		{name: "middle booking", start: sorted[150].StartTime, id: sorted[150].ID},
+		{name: "shared start below every ID", start: sorted[150].StartTime},
+		{name: "shared start above every ID", start: sorted[150].StartTime, id: len(bookings) + 1},
+		{name: "between steps", start: sorted[150].StartTime.Add(time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &Booking{StartTime: tt.start, ID: tt.id}
+			cut, _ := slices.BinarySearchFunc(sorted, key, bookingBefore)
+
+			left, right := splitNode(buildTree(bookings).root, tt.start, tt.id)
+			checkTree(t, left, sorted[:cut])
+			checkTree(t, right, sorted[cut:])
+
+			checkTree(t, mergeNodes(left, right), sorted)
+		})
+	}
+
+	t.Run("merge with empty", func(t *testing.T) {
+		root := buildTree(bookings).root
+		checkTree(t, mergeNodes(nil, root), sorted)
+		checkTree(t, mergeNodes(root, nil), sorted)
+		checkTree(t, mergeNodes(nil, nil), nil)
+	})
+}
+
+func TestIntervalTreeOverlapping(t *testing.T) {
+	bookings := generateBookings(1000, 7*24*time.Hour, 4)
+	tree := buildTree(bookings)
+	at := func(d time.Duration) time.Time { return testIndexBase.Add(d) }
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{name: "before every booking", start: at(-2 * time.Hour), end: at(-time.Hour)},
+		{name: "ending at the first start", start: at(-time.Hour), end: at(0)},
+		{name: "first hour", start: at(0), end: at(time.Hour)},
+		{name: "instant", start: at(50 * time.Hour), end: at(50*time.Hour + time.Nanosecond)},
+		{name: "one day", start: at(72 * time.Hour), end: at(96 * time.Hour)},
+		{name: "off step", start: at(30*time.Hour + 7*time.Minute), end: at(31*time.Hour + 53*time.Minute)},
+		{name: "whole range", start: at(-time.Hour), end: at(30 * 24 * time.Hour)},
+		{name: "after every booking", start: at(9 * 24 * time.Hour), end: at(10 * 24 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []*Booking
+			for _, booking := range sortedBookings(bookings) {
+				if booking.Status != BookingStatusCanceled && booking.StartTime.Before(tt.end) && booking.EndTime.After(tt.start) {
+					want = append(want, booking)
+				}
+			}
+
+			var got []*Booking
+			tree.Overlapping(tt.start, tt.end, func(booking *Booking) { got = append(got, booking) })
+			if !slices.Equal(bookingIDs(got), bookingIDs(want)) {
+				t.Errorf("Overlapping returned %v, want %v", bookingIDs(got), bookingIDs(want))
+			}
+		})
+	}
+}
+
+func TestIntervalTreeAscendFrom(t *testing.T) {
+	bookings := generateBookings(500, 24*time.Hour, 5)
+	tree := buildTree(bookings)
+	sorted := sortedBookings(bookings)
+
+	for _, from := range []time.Time{testIndexBase.Add(-time.Hour), sorted[100].StartTime, sorted[100].StartTime.Add(time.Minute), testIndexBase.Add(48 * time.Hour)} {
+		for _, limit := range []int{1, 10, len(bookings)} {
+			t.Run(fmt.Sprintf("%s limit %d", from.Format(time.Kitchen), limit), func(t *testing.T) {
+				var want []*Booking
+				for _, booking := range sorted {
+					if !booking.StartTime.Before(from) && len(want) < limit {
+						want = append(want, booking)
+					}
+				}
+
+				var got []*Booking
+				tree.AscendFrom(from, func(booking *Booking) bool {
+					got = append(got, booking)
+					return len(got) < limit
+				})
+				if !slices.Equal(bookingIDs(got), bookingIDs(want)) {
+					t.Errorf("AscendFrom returned %v, want %v", bookingIDs(got), bookingIDs(want))
+				}
+			})
+		}
+	}
+}
+
+func TestIntervalTreePage(t *testing.T) {
+	bookings := generateBookings(500, 24*time.Hour, 6)
+	tree := buildTree(bookings)
+	sorted := sortedBookings(bookings)
+
+	tests := []struct {
+		offset, limit int
+	}{
+		{0, 0}, {0, 1}, {0, 20}, {1, 20}, {137, 20}, {480, 20}, {499, 20}, {500, 20}, {600, 20}, {0, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("offset %d limit %d", tt.offset, tt.limit), func(t *testing.T) {
+			want := sorted[min(tt.offset, len(sorted)):min(tt.offset+tt.limit, len(sorted))]
+
+			var got []*Booking
+			tree.Page(tt.offset, tt.limit, func(booking *Booking) { got = append(got, booking) })
+			if !slices.Equal(bookingIDs(got), bookingIDs(want)) {
+				t.Errorf("Page returned %v, want %v", bookingIDs(got), bookingIDs(want))
+			}
+		})
+	}
+}
+
+// benchmarkSizes go up to about a million bookings on one resource
+var benchmarkSizes = []int{1_000, 10_000, 100_000, 1_000_000}
+
+// benchmarkTrees builds a tree per size, with bookings spread over a year
+func benchmarkTrees(b *testing.B, fn func(b *testing.B, tree *intervalTree, n int)) {
+	for _, n := range benchmarkSizes {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			tree := buildTree(generateBookings(n, 365*24*time.Hour, 7))
+			b.ResetTimer()
+			fn(b, tree, n)
+		})
+	}
+}
+
+func BenchmarkIntervalTreeInsertRemove(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, tree *intervalTree, n int) {
+		extra := generateBookings(1024, 365*24*time.Hour, 8)
+		for i := range b.N {
+			booking := extra[i%len(extra)]
+			booking.ID = n + 1
+			tree.Insert(booking)
+			tree.Remove(booking.StartTime, booking.ID)
+		}
+	})
+}
+
+func BenchmarkIntervalTreeOverlapping(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, tree *intervalTree, n int) {
+		rng := rand.New(rand.NewPCG(9, 9))
+		for range b.N {
+			start := testIndexBase.Add(time.Duration(rng.Int64N(365*24)) * time.Hour)
+			tree.Overlapping(start, start.Add(time.Hour), func(*Booking) {})
+		}
+	})
+}
+
+func BenchmarkIntervalTreePage(b *testing.B) {
+	benchmarkTrees(b, func(b *testing.B, tree *intervalTree, n int) {
+		for range b.N {
+			tree.Page(n/2, 20, func(*Booking) {})
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// completionBatchSize bounds how many bookings one sweep query loads
+const completionBatchSize = 500
+
+// CompleteFinishedBookings moves every CONFIRMED or CHECKED_IN booking that ended
+// before now to COMPLETED and returns how many it completed. Each change is a
+// compare-and-set on the previous status, so replicas sweeping at the same time
+// never complete (or announce) the same booking twice.
+func (s *BookingService) CompleteFinishedBookings(now time.Time) (int, error) {
+	completed := 0
+
+	for {
+		bookings, err := s.repository.GetFinishedBookings(now, completionBatchSize)
+		if err != nil {
+			return completed, fmt.Errorf("failed to get finished bookings: %w", err)
+		}
+
+		progressed := false
+		for _, booking := range bookings {
+			if !booking.IsValidTransition(BookingStatusCompleted) {
+				continue
+			}
+
+			fromStatus := booking.Status
+			booking.Status = BookingStatusCompleted
+			booking.UpdatedAt = time.Now()
+
+			if err := s.repository.UpdateIfStatus(booking, fromStatus, BookingEventCompleted); err != nil {
+				if errors.Is(err, ErrBookingChanged) {
+					progressed = true // another replica handled it
+					continue
+				}
+				log.Printf("Error completing booking %d: %v", booking.ID, err)
+				continue
+			}
+
+			progressed = true
+			completed++
+		}
+
+		// Stop after the last batch, or when a batch only contains bookings
+		// that keep failing, so a broken row cannot loop forever
+		if len(bookings) < completionBatchSize || !progressed {
+			return completed, nil
+		}
+	}
+}
+
+// RunCompletionWorker periodically completes finished bookings until the context is done
+func (s *BookingService) RunCompletionWorker(ctx context.Context) {
+	runPeriodically(ctx, "Completion", s.config.CompletionSweepInterval, func(now time.Time) error {
+		_, err := s.CompleteFinishedBookings(now)
+		return err
+	})
+}
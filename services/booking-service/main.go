@@ -13,21 +13,41 @@ import (
 )
 
 func main() {
-	// Initialize router
-	r := mux.NewRouter()
+	cfg := LoadConfig()
 
-	// Initialize handlers
-	bookingHandler := NewBookingHandler()
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImportCommand(cfg, os.Args[2:]))
+	}
 
-	// Routes
-	api := r.PathPrefix("/api/v1").Subrouter()
-	api.HandleFunc("/bookings", bookingHandler.CreateBooking).Methods("POST")
-	api.HandleFunc("/bookings", bookingHandler.ListBookings).Methods("GET")
-	api.HandleFunc("/bookings/{id}", bookingHandler.GetBooking).Methods("GET")
-	api.HandleFunc("/bookings/{id}", bookingHandler.UpdateBooking).Methods("PUT")
-	api.HandleFunc("/bookings/{id}", bookingHandler.CancelBooking).Methods("DELETE")
-	api.HandleFunc("/bookings/{id}/confirm", bookingHandler.ConfirmBooking).Methods("POST")
-	api.HandleFunc("/users/{user_id}/bookings", bookingHandler.GetUserBookings).Methods("GET")
+	authenticator, err := NewAuthenticator(cfg.JWTSecret)
+	if err != nil {
+		log.Fatalf("Booking Service failed to initialize authentication: %v", err)
+	}
+
+	// Initialize repository and service
+	repo, err := NewBookingRepository(cfg)
+	if err != nil {
+		log.Fatalf("Booking Service failed to initialize storage: %v", err)
+	}
+	service := NewBookingService(repo, cfg)
+
+	publisher, err := NewEventPublisher(cfg)
+	if err != nil {
+		log.Fatalf("Booking Service failed to initialize event publisher: %v", err)
+	}
+	relay := NewOutboxRelay(repo, publisher, cfg)
+
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	go relay.Run(workerCtx)
+	go service.RunWaitlistWorker(workerCtx)
+	go service.RunHoldExpiryWorker(workerCtx)
+	go service.RunCompletionWorker(workerCtx)
+	go service.RunNoShowWorker(workerCtx)
+
+	// Initialize handlers and router
+	bookingHandler := NewBookingHandler(service)
+	r := newRouter(bookingHandler, authenticator)
 
 	// Server configuration
 	server := &http.Server{
@@ -52,6 +72,8 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down Booking Service...")
+	stopWorkers()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
@@ -59,5 +81,60 @@ func main() {
 		return
 	}
 
+	// Events still in the outbox are delivered by the next relay to run
+	if err := publisher.Close(); err != nil {
+		log.Printf("Error closing event publisher: %v", err)
+	}
+
 	log.Println("Booking Service stopped")
 }
+
+// newRouter routes the booking API to its handlers
+func newRouter(bookingHandler *BookingHandler, authenticator *Authenticator) *mux.Router {
+	r := mux.NewRouter()
+
+	// Calendar feeds authenticate with the token in their URL, so they are
+	// routed before the authenticated API
+	r.HandleFunc("/api/v1/calendar/{token}.ics", bookingHandler.GetCalendarFeed).Methods("GET")
+
+	// Routes
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(authenticator.Middleware)
+	api.HandleFunc("/bookings", bookingHandler.CreateBooking).Methods("POST")
+	api.HandleFunc("/bookings", bookingHandler.ListBookings).Methods("GET")
+	api.HandleFunc("/bookings/check-availability", bookingHandler.CheckAvailability).Methods("POST")
+	api.HandleFunc("/bookings/find-slots", bookingHandler.FindSlots).Methods("POST")
+	api.HandleFunc("/bookings/export", bookingHandler.ExportBookings).Methods("GET")
+	api.HandleFunc("/bookings/{id}", bookingHandler.GetBooking).Methods("GET")
+	api.HandleFunc("/bookings/{id}", bookingHandler.UpdateBooking).Methods("PUT")
+	api.HandleFunc("/bookings/{id}", bookingHandler.CancelBooking).Methods("DELETE")
+	api.HandleFunc("/bookings/{id}/confirm", bookingHandler.ConfirmBooking).Methods("POST")
+	api.HandleFunc("/bookings/{id}/check-in", bookingHandler.CheckInBooking).Methods("POST")
+	api.HandleFunc("/bookings/{id}/check-in-token", bookingHandler.GetCheckInToken).Methods("GET")
+	api.HandleFunc("/bookings/{id}/attendees", bookingHandler.ListBookingAttendees).Methods("GET")
+	api.HandleFunc("/bookings/{id}/attendees", bookingHandler.AddBookingAttendee).Methods("POST")
+	api.HandleFunc("/bookings/{id}/attendees/{attendee_id}", bookingHandler.RemoveBookingAttendee).Methods("DELETE")
+	api.HandleFunc("/bookings/{id}/attendees/{attendee_id}/rsvp", bookingHandler.RespondToInvitation).Methods("PUT")
+	api.HandleFunc("/users/{user_id}/bookings", bookingHandler.GetUserBookings).Methods("GET")
+	api.HandleFunc("/users/{user_id}/quota", bookingHandler.GetUserQuota).Methods("GET")
+	api.HandleFunc("/users/{user_id}/quota/grants", bookingHandler.GrantUserQuota).Methods("POST")
+	api.HandleFunc("/users/{user_id}/calendar-feed", bookingHandler.CreateCalendarFeed).Methods("POST")
+	api.HandleFunc("/users/{user_id}/calendar-feed", bookingHandler.RevokeCalendarFeed).Methods("DELETE")
+	api.HandleFunc("/resources/{resource_id}/calendar-feed", bookingHandler.CreateCalendarFeed).Methods("POST")
+	api.HandleFunc("/resources/{resource_id}/calendar-feed", bookingHandler.RevokeCalendarFeed).Methods("DELETE")
+	api.HandleFunc("/booking-series/{id}", bookingHandler.GetSeries).Methods("GET")
+	api.HandleFunc("/booking-series/{id}", bookingHandler.CancelSeries).Methods("DELETE")
+	api.HandleFunc("/booking-groups", bookingHandler.CreateBookingGroup).Methods("POST")
+	api.HandleFunc("/booking-groups/{id}", bookingHandler.GetBookingGroup).Methods("GET")
+	api.HandleFunc("/booking-groups/{id}", bookingHandler.RescheduleBookingGroup).Methods("PUT")
+	api.HandleFunc("/booking-groups/{id}", bookingHandler.CancelBookingGroup).Methods("DELETE")
+	api.HandleFunc("/waitlist", bookingHandler.JoinWaitlist).Methods("POST")
+	api.HandleFunc("/waitlist", bookingHandler.ListWaitlist).Methods("GET")
+	api.HandleFunc("/waitlist/{id}", bookingHandler.GetWaitlistEntry).Methods("GET")
+	api.HandleFunc("/waitlist/{id}", bookingHandler.CancelWaitlistEntry).Methods("DELETE")
+	api.HandleFunc("/waitlist/{id}/accept", bookingHandler.AcceptWaitlistOffer).Methods("POST")
+	api.HandleFunc("/admin/bookings/complete-sweep", bookingHandler.SweepCompletedBookings).Methods("POST")
+	api.HandleFunc("/admin/bookings/import", bookingHandler.ImportBookings).Methods("POST")
+
+	return r
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"time"
 )
 
@@ -10,6 +11,7 @@ type BookingStatus string
 const (
 	BookingStatusPending   BookingStatus = "PENDING"
 	BookingStatusConfirmed BookingStatus = "CONFIRMED"
+	BookingStatusCheckedIn BookingStatus = "CHECKED_IN"
 	BookingStatusCanceled  BookingStatus = "CANCELED"
 	BookingStatusCompleted BookingStatus = "COMPLETED"
 )
@@ -26,6 +28,98 @@ type Booking struct {
 	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
 	UpdatedAt  time.Time     `json:"updated_at" db:"updated_at"`
 	CanceledAt *time.Time    `json:"canceled_at,omitempty" db:"canceled_at"`
+	SeriesID   *int          `json:"series_id,omitempty" db:"series_id"`
+	GroupID    *int          `json:"group_id,omitempty" db:"group_id"`
+	// Seats is the number of attendees; on shared resources it counts against the capacity
+	Seats int `json:"seats" db:"seats"`
+	// Shared records that the booking was made on a shared resource, whose bookings
+	// are exempt from the bookings_no_overlap constraint
+	Shared bool `json:"-" db:"shared"`
+	// ExpiresAt is when a PENDING booking stops holding its slot unless confirmed
+	ExpiresAt          *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CancellationReason string     `json:"cancellation_reason,omitempty" db:"cancellation_reason"`
+	CheckedInAt        *time.Time `json:"checked_in_at,omitempty" db:"checked_in_at"`
+}
+
+// Cancellation reasons recorded by the service itself
+const (
+	CancellationReasonHoldExpired = "hold_expired"
+	CancellationReasonNoShow      = "no_show"
+)
+
+// BookingSeries groups the occurrences created from one recurrence rule
+type BookingSeries struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	ResourceID int        `json:"resource_id" db:"resource_id"`
+	Rule       string     `json:"rule" db:"rule"`
+	StartTime  time.Time  `json:"start_time" db:"start_time"` // First occurrence
+	EndTime    time.Time  `json:"end_time" db:"end_time"`
+	Notes      string     `json:"notes" db:"notes"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	CanceledAt *time.Time `json:"canceled_at,omitempty" db:"canceled_at"`
+}
+
+// BookingSeriesWithOccurrences represents a series with its bookings
+type BookingSeriesWithOccurrences struct {
+	BookingSeries
+	Occurrences []*Booking `json:"occurrences"`
+}
+
+// OccurrenceFailure reports an occurrence of a series that could not be booked or changed
+type OccurrenceFailure struct {
+	BookingID int       `json:"booking_id,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Reason    string    `json:"reason"`
+}
+
+// SeriesResult reports the outcome of an operation over several occurrences
+type SeriesResult struct {
+	Series   *BookingSeries      `json:"series"`
+	Bookings []*Booking          `json:"bookings"`
+	Failed   []OccurrenceFailure `json:"failed,omitempty"`
+}
+
+// BookingGroup books several resources for the same time window as one unit
+type BookingGroup struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	StartTime  time.Time  `json:"start_time" db:"start_time"`
+	EndTime    time.Time  `json:"end_time" db:"end_time"`
+	Notes      string     `json:"notes" db:"notes"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	CanceledAt *time.Time `json:"canceled_at,omitempty" db:"canceled_at"`
+}
+
+// BookingGroupWithMembers represents a group with the booking of each resource
+type BookingGroupWithMembers struct {
+	BookingGroup
+	Members []*Booking `json:"members"`
+}
+
+// CreateBookingGroupRequest represents the request to book several resources together
+type CreateBookingGroupRequest struct {
+	ResourceIDs []int     `json:"resource_ids" validate:"required,min=1"`
+	StartTime   time.Time `json:"start_time" validate:"required"`
+	EndTime     time.Time `json:"end_time" validate:"required"`
+	Notes       string    `json:"notes" validate:"max=500"`
+	// Seats is booked on every member resource
+	Seats int `json:"seats,omitempty" validate:"omitempty,min=1"`
+}
+
+// GroupResult reports the outcome of an operation over the members of a group
+type GroupResult struct {
+	Group    *BookingGroup `json:"group"`
+	Bookings []*Booking    `json:"bookings"`
+}
+
+// ResourceConflict lists the bookings that block one member resource of a group
+type ResourceConflict struct {
+	ResourceID int               `json:"resource_id"`
+	Conflicts  []BookingConflict `json:"conflicts"`
 }
 
 // BookingWithDetails represents a booking with user and resource details
@@ -35,6 +129,8 @@ type BookingWithDetails struct {
 	UserEmail    string `json:"user_email"`
 	ResourceName string `json:"resource_name"`
 	ResourceType string `json:"resource_type"`
+	// MissingDetails lists the fields above that could not be loaded
+	MissingDetails []string `json:"missing_details,omitempty"`
 }
 
 // CreateBookingRequest represents the request to create a booking
@@ -43,13 +139,26 @@ type CreateBookingRequest struct {
 	StartTime  time.Time `json:"start_time" validate:"required"`
 	EndTime    time.Time `json:"end_time" validate:"required"`
 	Notes      string    `json:"notes" validate:"max=500"`
+	Seats      int       `json:"seats,omitempty" validate:"omitempty,min=1"`
+	// JoinWaitlist queues the user for the slot instead of failing when it is taken
+	JoinWaitlist bool `json:"join_waitlist,omitempty"`
+	// Recurrence is an optional RFC 5545 RRULE (e.g. "FREQ=WEEKLY;BYDAY=MO;COUNT=10").
+	// When set, StartTime and EndTime describe the first occurrence of a series.
+	Recurrence string `json:"recurrence,omitempty"`
 }
 
+// Update scopes for bookings that belong to a series
+const (
+	UpdateScopeThis      = "this"
+	UpdateScopeFollowing = "following"
+)
+
 // UpdateBookingRequest represents the request to update a booking
 type UpdateBookingRequest struct {
 	StartTime *time.Time `json:"start_time,omitempty"`
 	EndTime   *time.Time `json:"end_time,omitempty"`
 	Notes     *string    `json:"notes,omitempty" validate:"omitempty,max=500"`
+	Seats     *int       `json:"seats,omitempty" validate:"omitempty,min=1"`
 }
 
 // ListBookingsQuery represents query parameters for listing bookings
@@ -61,6 +170,12 @@ type ListBookingsQuery struct {
 	EndDate    time.Time     `query:"end_date"`
 	Page       int           `query:"page"`
 	Size       int           `query:"size"`
+	// IncludeAttending also matches bookings UserID is invited to
+	IncludeAttending bool
+	// AfterStart and AfterID, when AfterID is set, resume the listing after the
+	// booking with that start time and ID, so long listings page without OFFSET
+	AfterStart time.Time
+	AfterID    int
 }
 
 // BookingConflict represents a booking conflict
@@ -68,16 +183,78 @@ type BookingConflict struct {
 	ConflictingBookingID int       `json:"conflicting_booking_id"`
 	ConflictStartTime    time.Time `json:"conflict_start_time"`
 	ConflictEndTime      time.Time `json:"conflict_end_time"`
-	Message              string    `json:"message"`
+	Seats                int       `json:"seats"`
+	// The conflicting booking including the resource's setup and teardown buffers
+	BlockedStartTime time.Time `json:"blocked_start_time"`
+	BlockedEndTime   time.Time `json:"blocked_end_time"`
+	Message          string    `json:"message"`
+}
+
+// CheckInRequest represents the request to check in to a booking
+type CheckInRequest struct {
+	// Token is the signed check-in token shown by the room display, if any
+	Token string `json:"token,omitempty"`
+}
+
+// CheckInToken is a short-lived signed token a room display can show as a QR code
+type CheckInToken struct {
+	BookingID int       `json:"booking_id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
+// SweepResult reports the outcome of a manually triggered lifecycle sweep
+type SweepResult struct {
+	Completed int       `json:"completed"`
+	SweptAt   time.Time `json:"swept_at"`
+}
+
+// ErrorResponse is the JSON body of errors that carry a machine-readable code
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Violations lists every failed rule of a POLICY_VIOLATION error
+	Violations []PolicyViolation `json:"violations,omitempty"`
+	// Quota lists the limits a QUOTA_EXCEEDED booking would go over
+	Quota []QuotaUsage `json:"quota,omitempty"`
+	// BlockedResources lists the member resources that block a booking group
+	BlockedResources []ResourceConflict `json:"blocked_resources,omitempty"`
+	// Conflicts lists the bookings in the way of a BOOKING_CONFLICT booking
+	Conflicts []BookingConflict `json:"conflicts,omitempty"`
+	// Alternatives suggests free slots to book instead of a BOOKING_CONFLICT booking
+	Alternatives []BookingAlternative `json:"alternatives,omitempty"`
+}
+
+// Error codes returned in ErrorResponse
+const (
+	ErrorCodeResourceNotFound           = "RESOURCE_NOT_FOUND"
+	ErrorCodeResourceInactive           = "RESOURCE_INACTIVE"
+	ErrorCodeOutsideOpeningHours        = "OUTSIDE_OPENING_HOURS"
+	ErrorCodeResourceServiceUnavailable = "RESOURCE_SERVICE_UNAVAILABLE"
+	ErrorCodeUnauthorized               = "UNAUTHORIZED"
+	ErrorCodeNotOwner                   = "NOT_OWNER"
+	ErrorCodeElevatedRoleRequired       = "ELEVATED_ROLE_REQUIRED"
+	ErrorCodePolicyViolation            = "POLICY_VIOLATION"
+	ErrorCodeQuotaExceeded              = "QUOTA_EXCEEDED"
+	ErrorCodeBookingConflict            = "BOOKING_CONFLICT"
+	ErrorCodeAdminRoleRequired          = "ADMIN_ROLE_REQUIRED"
+	ErrorCodeSeatsExceedCapacity        = "SEATS_EXCEED_CAPACITY"
+	ErrorCodeInvalidImportRow           = "INVALID_IMPORT_ROW"
+	ErrorCodeUserNotFound               = "USER_NOT_FOUND"
+	ErrorCodeUserServiceUnavailable     = "USER_SERVICE_UNAVAILABLE"
+	ErrorCodeInternal                   = "INTERNAL_ERROR"
+)
+
 // BookingEvent represents an event for the messaging system
 type BookingEvent struct {
+	EventID   string    `json:"event_id"`
 	Type      string    `json:"type"`
 	BookingID int       `json:"booking_id"`
 	UserID    int       `json:"user_id"`
 	Timestamp time.Time `json:"timestamp"`
 	Data      Booking   `json:"data"`
+	// Attendee is the invitee an attendee event is about
+	Attendee *Attendee `json:"attendee,omitempty"`
 }
 
 // BookingEventType defines the types of booking events
@@ -88,28 +265,157 @@ const (
 	BookingEventUpdated   BookingEventType = "booking.updated"
 	BookingEventConfirmed BookingEventType = "booking.confirmed"
 	BookingEventCanceled  BookingEventType = "booking.canceled"
+	BookingEventCompleted BookingEventType = "booking.completed"
+	BookingEventCheckedIn BookingEventType = "booking.checked_in"
+	// BookingEventWaitlistOffered is published when a waitlisted user is given a freed slot
+	BookingEventWaitlistOffered BookingEventType = "booking.waitlist_offered"
+	// Attendee events carry the invitee in Attendee
+	BookingEventAttendeeAdded     BookingEventType = "booking.attendee_added"
+	BookingEventAttendeeRemoved   BookingEventType = "booking.attendee_removed"
+	BookingEventAttendeeResponded BookingEventType = "booking.attendee_responded"
 )
 
-// AvailabilityCheckRequest represents a request to check availability
-type AvailabilityCheckRequest struct {
+// WaitlistStatus represents the status of a waitlist entry
+type WaitlistStatus string
+
+const (
+	WaitlistStatusWaiting   WaitlistStatus = "WAITING"   // Queued for the slot
+	WaitlistStatusOffered   WaitlistStatus = "OFFERED"   // Holds a PENDING booking awaiting confirmation
+	WaitlistStatusFulfilled WaitlistStatus = "FULFILLED" // Offered booking was confirmed
+	WaitlistStatusExpired   WaitlistStatus = "EXPIRED"   // Offer not confirmed in time or slot already started
+	WaitlistStatusCanceled  WaitlistStatus = "CANCELED"  // Removed by the user
+)
+
+// WaitlistEntry represents a user queued for a resource and time window
+type WaitlistEntry struct {
+	ID             int            `json:"id" db:"id"`
+	UserID         int            `json:"user_id" db:"user_id"`
+	ResourceID     int            `json:"resource_id" db:"resource_id"`
+	StartTime      time.Time      `json:"start_time" db:"start_time"`
+	EndTime        time.Time      `json:"end_time" db:"end_time"`
+	Status         WaitlistStatus `json:"status" db:"status"`
+	Notes          string         `json:"notes" db:"notes"`
+	Seats          int            `json:"seats" db:"seats"`
+	BookingID      *int           `json:"booking_id,omitempty" db:"booking_id"`
+	OfferExpiresAt *time.Time     `json:"offer_expires_at,omitempty" db:"offer_expires_at"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// JoinWaitlistRequest represents the request to join the waitlist
+type JoinWaitlistRequest struct {
 	ResourceID int       `json:"resource_id" validate:"required"`
 	StartTime  time.Time `json:"start_time" validate:"required"`
 	EndTime    time.Time `json:"end_time" validate:"required"`
+	Notes      string    `json:"notes" validate:"max=500"`
+	Seats      int       `json:"seats,omitempty" validate:"omitempty,min=1"`
+}
+
+// ListWaitlistQuery represents query parameters for listing waitlist entries
+type ListWaitlistQuery struct {
+	UserID     int            `query:"user_id"`
+	ResourceID int            `query:"resource_id"`
+	BookingID  int            `query:"booking_id"`
+	Status     WaitlistStatus `query:"status"`
+	Page       int            `query:"page"`
+	Size       int            `query:"size"`
+}
+
+// IsOpen checks if the entry can still receive or hold an offer
+func (e *WaitlistEntry) IsOpen() bool {
+	return e.Status == WaitlistStatusWaiting || e.Status == WaitlistStatusOffered
+}
+
+// Limits for multi-resource availability checks
+const (
+	MaxAvailabilityResources = 100
+	MaxAvailabilityWindows   = 50
+)
+
+// AvailabilityCheckRequest represents a request to check availability.
+// A single resource and time range can be given with ResourceID, StartTime and
+// EndTime; several resources and windows can be checked at once with
+// ResourceIDs and Windows.
+type AvailabilityCheckRequest struct {
+	ResourceID  int          `json:"resource_id,omitempty"`
+	StartTime   time.Time    `json:"start_time,omitempty"`
+	EndTime     time.Time    `json:"end_time,omitempty"`
+	ResourceIDs []int        `json:"resource_ids,omitempty"`
+	Windows     []TimeWindow `json:"windows,omitempty"`
+	// Seats needed on shared resources; one when omitted
+	Seats int `json:"seats,omitempty"`
+}
+
+// TimeWindow represents a time range to check
+type TimeWindow struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// WindowAvailability represents the availability of a resource in one time window
+type WindowAvailability struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Available bool      `json:"available"`
+	// RemainingSeats is the number of seats free during the whole window
+	RemainingSeats int `json:"remaining_seats"`
 }
 
 // AvailabilityCheckResponse represents the response of availability check
 type AvailabilityCheckResponse struct {
-	Available bool              `json:"available"`
-	Conflicts []BookingConflict `json:"conflicts,omitempty"`
+	ResourceID int  `json:"resource_id,omitempty"`
+	Available  bool `json:"available"`
+	// RemainingSeats is the number of seats free during every requested window
+	RemainingSeats int                  `json:"remaining_seats"`
+	Conflicts      []BookingConflict    `json:"conflicts,omitempty"`
+	Windows        []WindowAvailability `json:"windows,omitempty"`
+}
+
+// IsBatch reports whether the request uses the multi-resource form
+func (r *AvailabilityCheckRequest) IsBatch() bool {
+	return len(r.ResourceIDs) > 0 || len(r.Windows) > 0
 }
 
+// SeatsNeeded returns the seats the request checks for, one when omitted
+func (r *AvailabilityCheckRequest) SeatsNeeded() int {
+	return max(r.Seats, 1)
+}
+
+// AllResourceIDs returns the distinct resources named by the request
+func (r *AvailabilityCheckRequest) AllResourceIDs() []int {
+	seen := make(map[int]bool)
+	var ids []int
+	for _, id := range append([]int{r.ResourceID}, r.ResourceIDs...) {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// AllWindows returns the time windows named by the request
+func (r *AvailabilityCheckRequest) AllWindows() []TimeWindow {
+	var windows []TimeWindow
+	if !r.StartTime.IsZero() || !r.EndTime.IsZero() {
+		windows = append(windows, TimeWindow{StartTime: r.StartTime, EndTime: r.EndTime})
+	}
+	return append(windows, r.Windows...)
+}
+
+// ErrInvalidTransition is returned when a booking cannot move to the requested status
+var ErrInvalidTransition = errors.New("invalid booking status transition")
+
 // IsValidTransition checks if a status transition is valid
 func (b *Booking) IsValidTransition(newStatus BookingStatus) bool {
 	switch b.Status {
 	case BookingStatusPending:
 		return newStatus == BookingStatusConfirmed || newStatus == BookingStatusCanceled
 	case BookingStatusConfirmed:
-		return newStatus == BookingStatusCanceled || newStatus == BookingStatusCompleted
+		return newStatus == BookingStatusCanceled || newStatus == BookingStatusCompleted ||
+			newStatus == BookingStatusCheckedIn
+	case BookingStatusCheckedIn:
+		return newStatus == BookingStatusCompleted
 	case BookingStatusCanceled, BookingStatusCompleted:
 		return false // Terminal states
 	default:
@@ -129,7 +435,7 @@ func (b *Booking) Duration() time.Duration {
 
 // IsActive checks if the booking is currently active
 func (b *Booking) IsActive(now time.Time) bool {
-	return b.Status == BookingStatusConfirmed &&
+	return (b.Status == BookingStatusConfirmed || b.Status == BookingStatusCheckedIn) &&
 		now.After(b.StartTime) &&
 		now.Before(b.EndTime)
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// outboxPublishTimeout bounds a single delivery to the publisher
+const outboxPublishTimeout = 10 * time.Second
+
+// OutboxEvent is a booking event stored in the same write as the booking change
+// that caused it, waiting for the relay to deliver it
+type OutboxEvent struct {
+	ID        int64     // Delivery order
+	EventID   string    // Unique per event; consumers use it to drop duplicates
+	BookingID int       // Events of one booking are delivered in ID order
+	Type      string    // e.g. "booking.created"
+	Payload   []byte    // JSON-encoded BookingEvent
+	CreatedAt time.Time // When the change was written
+}
+
+// newOutboxEvents builds the outbox records for a stored booking. It must be
+// called after the booking has its ID.
+func newOutboxEvents(booking *Booking, eventTypes []BookingEventType) ([]*OutboxEvent, error) {
+	return newAttendeeEvents(booking, nil, eventTypes)
+}
+
+// newAttendeeEvents builds the outbox records for a change to an attendee of a
+// booking. The attendee is nil for changes to the booking itself.
+func newAttendeeEvents(booking *Booking, attendee *Attendee, eventTypes []BookingEventType) ([]*OutboxEvent, error) {
+	now := time.Now()
+	events := make([]*OutboxEvent, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		event := BookingEvent{
+			EventID:   uuid.NewString(),
+			Type:      string(eventType),
+			BookingID: booking.ID,
+			UserID:    booking.UserID,
+			Timestamp: now,
+			Data:      *booking,
+			Attendee:  attendee,
+		}
+
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+		}
+
+		events = append(events, &OutboxEvent{
+			EventID:   event.EventID,
+			BookingID: booking.ID,
+			Type:      event.Type,
+			Payload:   payload,
+			CreatedAt: now,
+		})
+	}
+
+	return events, nil
+}
+
+// EventPublisher delivers outbox events to consumers. Publish must only return
+// nil once the event is safely handed over; the relay retries everything else.
+type EventPublisher interface {
+	Publish(ctx context.Context, event *OutboxEvent) error
+	Close() error
+}
+
+// NewEventPublisher creates the publisher selected by EVENT_PUBLISHER
+func NewEventPublisher(cfg Config) (EventPublisher, error) {
+	switch cfg.EventPublisher {
+	case EventPublisherInProcess:
+		return NewInProcessPublisher(), nil
+	case EventPublisherAMQP:
+		return NewAMQPPublisher(cfg.RabbitMQURL, cfg.EventExchange), nil
+	default:
+		return nil, fmt.Errorf("unknown event publisher: %s", cfg.EventPublisher)
+	}
+}
+
+// InProcessPublisher hands events to handlers in the same process. It is used for
+// development and tests; without handlers it only logs the events.
+type InProcessPublisher struct {
+	handlers []func(event *OutboxEvent) error
+	mutex    sync.RWMutex
+}
+
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{}
+}
+
+// Subscribe registers a handler for every published event. A handler error fails
+// the delivery, so the event is published again later.
+func (p *InProcessPublisher) Subscribe(handler func(event *OutboxEvent) error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.handlers = append(p.handlers, handler)
+}
+
+func (p *InProcessPublisher) Publish(_ context.Context, event *OutboxEvent) error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	log.Printf("Publishing event: %s for booking %d (%s)", event.Type, event.BookingID, event.EventID)
+	for _, handler := range p.handlers {
+		if err := handler(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *InProcessPublisher) Close() error {
+	return nil
+}
+
+// OutboxRelay delivers stored booking events to a publisher, at least once and in
+// the order they were written. An event that cannot be published stops the batch,
+// so later events of the same booking never overtake it.
+type OutboxRelay struct {
+	repository BookingRepository
+	publisher  EventPublisher
+	config     Config
+}
+
+func NewOutboxRelay(repository BookingRepository, publisher EventPublisher, config Config) *OutboxRelay {
+	return &OutboxRelay{
+		repository: repository,
+		publisher:  publisher,
+		config:     config,
+	}
+}
+
+// RelayOutbox publishes pending events until the outbox is empty or a delivery fails
+func (r *OutboxRelay) RelayOutbox(_ time.Time) error {
+	for {
+		batchFull := false
+		_, err := r.repository.DeliverOutboxEvents(r.config.OutboxBatchSize, func(events []*OutboxEvent) int {
+			for i, event := range events {
+				if err := r.publish(event); err != nil {
+					log.Printf("Error publishing event %s (%s for booking %d): %v",
+						event.EventID, event.Type, event.BookingID, err)
+					return i
+				}
+			}
+			batchFull = len(events) == r.config.OutboxBatchSize
+			return len(events)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to relay outbox: %w", err)
+		}
+		if !batchFull {
+			return nil
+		}
+	}
+}
+
+// Run periodically relays the outbox until the context is done
+func (r *OutboxRelay) Run(ctx context.Context) {
+	runPeriodically(ctx, "Outbox relay", r.config.OutboxRelayInterval, r.RelayOutbox)
+}
+
+func (r *OutboxRelay) publish(event *OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), outboxPublishTimeout)
+	defer cancel()
+
+	return r.publisher.Publish(ctx, event)
+}
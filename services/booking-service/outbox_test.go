@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// recordedEvent is an event as a subscriber saw it
+type recordedEvent struct {
+	EventID   string
+	BookingID int
+	Type      string
+}
+
+func TestOutboxRelayRedeliversAfterFailure(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo testRepository) {
+		// Three bookings change in interleaved order
+		start := time.Now().Add(72 * time.Hour).Truncate(time.Hour)
+		var bookings []*Booking
+		for i := range 3 {
+			booking := newTestBooking(repo, start.Add(time.Duration(i)*time.Hour), time.Hour)
+			if err := repo.CreateIfAvailable(booking, Occupancy{}, BookingEventCreated); err != nil {
+				t.Fatalf("CreateIfAvailable: %v", err)
+			}
+			bookings = append(bookings, booking)
+		}
+		update := func(booking *Booking) {
+			booking.Notes += "+"
+			if err := repo.Update(booking, BookingEventUpdated); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+		}
+		cancel := func(booking *Booking) {
+			booking.Status = BookingStatusCanceled
+			if err := repo.UpdateIfStatus(booking, BookingStatusPending, BookingEventCanceled); err != nil {
+				t.Fatalf("UpdateIfStatus: %v", err)
+			}
+		}
+		update(bookings[0])
+		cancel(bookings[1])
+		update(bookings[2])
+		update(bookings[0])
+		cancel(bookings[0])
+
+		want := map[int][]string{
+			bookings[0].ID: {"booking.created", "booking.updated", "booking.updated", "booking.canceled"},
+			bookings[1].ID: {"booking.created", "booking.canceled"},
+			bookings[2].ID: {"booking.created", "booking.updated"},
+		}
+
+		cfg := newTestConfig()
+		cfg.OutboxBatchSize = 2
+
+		// relay runs a relay with a fresh publisher, as after a restart. Its
+		// subscriber fails after accepting accept of this test's events, or never
+		// when accept is negative.
+		relay := func(accept int) []recordedEvent {
+			var received []recordedEvent
+			publisher := NewInProcessPublisher()
+			publisher.Subscribe(func(event *OutboxEvent) error {
+				if _, ours := want[event.BookingID]; !ours {
+					return nil
+				}
+				if len(received) == accept {
+					return errors.New("broker unavailable")
+				}
+				received = append(received, recordedEvent{EventID: event.EventID, BookingID: event.BookingID, Type: event.Type})
+				return nil
+			})
+			if err := NewOutboxRelay(repo, publisher, cfg).RelayOutbox(time.Now()); err != nil {
+				t.Fatalf("RelayOutbox: %v", err)
+			}
+			return received
+		}
+
+		beforeFailure := relay(3)
+		if len(beforeFailure) != 3 {
+			t.Fatalf("the failing relay delivered %d events, want 3", len(beforeFailure))
+		}
+		afterRestart := relay(-1)
+		if again := relay(-1); len(again) != 0 {
+			t.Errorf("a third relay delivered %d events again", len(again))
+		}
+
+		seen := make(map[string]bool)
+		got := make(map[int][]string)
+		for _, event := range append(beforeFailure, afterRestart...) {
+			if seen[event.EventID] {
+				t.Errorf("event %s (%s) was delivered twice", event.EventID, event.Type)
+			}
+			seen[event.EventID] = true
+			got[event.BookingID] = append(got[event.BookingID], event.Type)
+		}
+		for bookingID, types := range want {
+			if !slices.Equal(got[bookingID], types) {
+				t.Errorf("booking %d events arrived as %v, want %v", bookingID, got[bookingID], types)
+			}
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Policy rules reported in PolicyViolation
+const (
+	PolicyRuleMinDuration     = "MIN_DURATION"
+	PolicyRuleMaxDuration     = "MAX_DURATION"
+	PolicyRuleMinLeadTime     = "MIN_LEAD_TIME"
+	PolicyRuleMaxAdvance      = "MAX_ADVANCE"
+	PolicyRuleGranularity     = "GRANULARITY"
+	PolicyRuleRoleNotAllowed  = "ROLE_NOT_ALLOWED"
+	PolicyRuleRoleMaxDuration = "ROLE_MAX_DURATION"
+)
+
+// PolicyDuration is a duration written as a Go duration string ("90m", "2h") in JSON
+type PolicyDuration time.Duration
+
+func (d PolicyDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *PolicyDuration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = PolicyDuration(parsed)
+	return nil
+}
+
+// BookingPolicy is a set of booking rules for all resources, a resource type or a
+// single resource. Rules left unset impose no limit.
+type BookingPolicy struct {
+	ResourceType string `json:"resource_type,omitempty"`
+	ResourceID   int    `json:"resource_id,omitempty"`
+
+	MinDuration    *PolicyDuration `json:"min_duration,omitempty"`
+	MaxDuration    *PolicyDuration `json:"max_duration,omitempty"`
+	MinLeadTime    *PolicyDuration `json:"min_lead_time,omitempty"`
+	MaxAdvanceDays *int            `json:"max_advance_days,omitempty"`
+	// Granularity requires start and end times on multiples of it (e.g. "15m")
+	Granularity *PolicyDuration `json:"granularity,omitempty"`
+	// AllowedRoles lists the roles that may book; empty allows every role
+	AllowedRoles []string `json:"allowed_roles,omitempty"`
+	// RoleMaxDuration limits how long each role may book
+	RoleMaxDuration map[string]PolicyDuration `json:"role_max_duration,omitempty"`
+}
+
+// Scope describes which bookings the policy applies to
+func (p BookingPolicy) Scope() string {
+	switch {
+	case p.ResourceID != 0:
+		return fmt.Sprintf("resource:%d", p.ResourceID)
+	case p.ResourceType != "":
+		return "resource_type:" + p.ResourceType
+	default:
+		return "global"
+	}
+}
+
+// specificity orders policies from global to per resource
+func (p BookingPolicy) specificity() int {
+	switch {
+	case p.ResourceID != 0:
+		return 2
+	case p.ResourceType != "":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// PolicyViolation is one failed rule
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Scope   string `json:"scope"`
+	Message string `json:"message"`
+}
+
+// PolicyViolationError is returned when a booking breaks one or more policy rules
+type PolicyViolationError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PolicyViolationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return "booking violates policy: " + strings.Join(messages, "; ")
+}
+
+// policyRule is the effective value of one rule and the scope it comes from
+type policyRule[T any] struct {
+	value T
+	scope string
+	set   bool
+}
+
+func (r *policyRule[T]) apply(value T, scope string) {
+	r.value, r.scope, r.set = value, scope, true
+}
+
+// PolicyEngine evaluates bookings against the configured policies. For every rule
+// the most specific policy that sets it wins: resource, then resource type, then global.
+type PolicyEngine struct {
+	policies []BookingPolicy
+}
+
+func NewPolicyEngine(policies []BookingPolicy) *PolicyEngine {
+	sorted := slices.Clone(policies)
+	slices.SortStableFunc(sorted, func(a, b BookingPolicy) int {
+		return a.specificity() - b.specificity()
+	})
+
+	return &PolicyEngine{policies: sorted}
+}
+
+// Policies returns the configured policies, global ones first
+func (e *PolicyEngine) Policies() []BookingPolicy {
+	return e.policies
+}
+
+// HasTypePolicies reports whether any policy is scoped to a resource type
+func (e *PolicyEngine) HasTypePolicies() bool {
+	for _, policy := range e.policies {
+		if policy.ResourceID == 0 && policy.ResourceType != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRolePolicies reports whether any policy depends on the role of the booking owner
+func (e *PolicyEngine) HasRolePolicies() bool {
+	for _, policy := range e.policies {
+		if len(policy.AllowedRoles) > 0 || len(policy.RoleMaxDuration) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate checks a booking of the resource from start to end by a user with the
+// given role and returns every rule it breaks
+func (e *PolicyEngine) Evaluate(resourceID int, resourceType, role string, start, end, now time.Time) []PolicyViolation {
+	var (
+		minDuration, maxDuration, minLeadTime, granularity, roleMaxDuration policyRule[time.Duration]
+		maxAdvanceDays                                                      policyRule[int]
+		allowedRoles                                                        policyRule[[]string]
+	)
+
+	for _, policy := range e.policies {
+		if (policy.ResourceID != 0 && policy.ResourceID != resourceID) ||
+			(policy.ResourceID == 0 && policy.ResourceType != "" && policy.ResourceType != resourceType) {
+			continue
+		}
+
+		scope := policy.Scope()
+		if policy.MinDuration != nil {
+			minDuration.apply(time.Duration(*policy.MinDuration), scope)
+		}
+		if policy.MaxDuration != nil {
+			maxDuration.apply(time.Duration(*policy.MaxDuration), scope)
+		}
+		if policy.MinLeadTime != nil {
+			minLeadTime.apply(time.Duration(*policy.MinLeadTime), scope)
+		}
+		if policy.MaxAdvanceDays != nil {
+			maxAdvanceDays.apply(*policy.MaxAdvanceDays, scope)
+		}
+		if policy.Granularity != nil {
+			granularity.apply(time.Duration(*policy.Granularity), scope)
+		}
+		if len(policy.AllowedRoles) > 0 {
+			allowedRoles.apply(policy.AllowedRoles, scope)
+		}
+		if limit, ok := policy.RoleMaxDuration[role]; ok {
+			roleMaxDuration.apply(time.Duration(limit), scope)
+		}
+	}
+
+	var violations []PolicyViolation
+	violate := func(rule, scope, format string, args ...interface{}) {
+		violations = append(violations, PolicyViolation{
+			Rule:    rule,
+			Scope:   scope,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	duration := end.Sub(start)
+	if minDuration.set && duration < minDuration.value {
+		violate(PolicyRuleMinDuration, minDuration.scope,
+			"booking must last at least %s", minDuration.value)
+	}
+	if maxDuration.set && duration > maxDuration.value {
+		violate(PolicyRuleMaxDuration, maxDuration.scope,
+			"booking cannot last more than %s", maxDuration.value)
+	}
+	if minLeadTime.set && start.Sub(now) < minLeadTime.value {
+		violate(PolicyRuleMinLeadTime, minLeadTime.scope,
+			"booking must be made at least %s before it starts", minLeadTime.value)
+	}
+	if maxAdvanceDays.set && start.After(now.AddDate(0, 0, maxAdvanceDays.value)) {
+		violate(PolicyRuleMaxAdvance, maxAdvanceDays.scope,
+			"booking cannot start more than %d days in advance", maxAdvanceDays.value)
+	}
+	if granularity.set && granularity.value > 0 &&
+		(!start.Truncate(granularity.value).Equal(start) || !end.Truncate(granularity.value).Equal(end)) {
+		violate(PolicyRuleGranularity, granularity.scope,
+			"start and end times must be on %s boundaries", granularity.value)
+	}
+	if allowedRoles.set && !slices.Contains(allowedRoles.value, role) {
+		violate(PolicyRuleRoleNotAllowed, allowedRoles.scope,
+			"role %q cannot book this resource", role)
+	}
+	if roleMaxDuration.set && duration > roleMaxDuration.value {
+		violate(PolicyRuleRoleMaxDuration, roleMaxDuration.scope,
+			"role %q cannot book for more than %s", role, roleMaxDuration.value)
+	}
+
+	return violations
+}
+
+// loadPolicies builds the global policy from the MAX_BOOKING_DURATION_HOURS,
+// MIN_BOOKING_ADVANCE_HOURS and MAX_BOOKING_ADVANCE_DAYS settings and adds the
+// policies listed in the BOOKING_POLICIES_FILE JSON file
+func loadPolicies(config Config) *PolicyEngine {
+	var policies []BookingPolicy
+
+	global := BookingPolicy{}
+	if config.MaxBookingDuration > 0 {
+		limit := PolicyDuration(config.MaxBookingDuration)
+		global.MaxDuration = &limit
+	}
+	if config.MinBookingLeadTime > 0 {
+		limit := PolicyDuration(config.MinBookingLeadTime)
+		global.MinLeadTime = &limit
+	}
+	if config.MaxBookingAdvanceDays > 0 {
+		limit := config.MaxBookingAdvanceDays
+		global.MaxAdvanceDays = &limit
+	}
+	policies = append(policies, global)
+
+	if config.PoliciesFile != "" {
+		data, err := os.ReadFile(config.PoliciesFile)
+		if err != nil {
+			log.Fatalf("Failed to read booking policies: %v", err)
+		}
+
+		var filePolicies []BookingPolicy
+		if err := json.Unmarshal(data, &filePolicies); err != nil {
+			log.Fatalf("Invalid booking policies in %s: %v", config.PoliciesFile, err)
+		}
+		policies = append(policies, filePolicies...)
+	}
+
+	return NewPolicyEngine(policies)
+}
+
+// checkPolicy evaluates a booking for the caller against the policy engine. The
+// resource type is only looked up when type-scoped policies exist.
+func (s *BookingService) checkPolicy(caller Identity, resourceID int, start, end time.Time) error {
+	var resourceType string
+	if s.policies.HasTypePolicies() && s.config.ResourceValidation {
+		resource, err := s.cachedResource(resourceID)
+		if err != nil {
+			return err
+		}
+		resourceType = resource.Type
+	}
+
+	violations := s.policies.Evaluate(resourceID, resourceType, caller.Role, start, end, time.Now())
+	if len(violations) > 0 {
+		return &PolicyViolationError{Violations: violations}
+	}
+
+	return nil
+}
+
+// bookingOwner returns the identity whose role the policies of a booking owned by
+// userID are evaluated for. Admins and managers changing another user's booking
+// are held to the owner's rules, so the owner's role is looked up in user-service
+// when a policy depends on it.
+func (s *BookingService) bookingOwner(caller Identity, userID int) (Identity, error) {
+	if caller.UserID == userID || !s.policies.HasRolePolicies() {
+		return Identity{UserID: userID, Role: caller.Role}, nil
+	}
+
+	owner, err := s.users.GetUser(userID)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{UserID: userID, Role: owner.Role}, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPolicyService returns a service on an in-memory repository with the
+// given policies and a fake user-service that knows user 1 as a regular user
+func newTestPolicyService(t *testing.T, policies string) *BookingService {
+	t.Helper()
+
+	cfg := newTestConfig()
+	cfg.UserServiceURL = newTestUserService(t, User{ID: 1, Role: RoleUser, IsActive: true})
+	cfg.PoliciesFile = filepath.Join(t.TempDir(), "policies.json")
+	if err := os.WriteFile(cfg.PoliciesFile, []byte(policies), 0o600); err != nil {
+		t.Fatalf("failed to write policies: %v", err)
+	}
+	return NewBookingService(NewInMemoryBookingRepository(), cfg)
+}
+
+func TestPoliciesApplyToBookingOwner(t *testing.T) {
+	// Users may book two hours at a time; admins have no such limit
+	service := newTestPolicyService(t, `[{"role_max_duration": {"user": "2h"}}]`)
+	owner := Identity{UserID: 1, Role: RoleUser}
+	admin := Identity{UserID: 2, Role: RoleAdmin}
+	start := nextMonday()
+	end := start.Add(3 * time.Hour)
+
+	checkViolation := func(t *testing.T, err error) {
+		t.Helper()
+		var violation *PolicyViolationError
+		if !errors.As(err, &violation) || !strings.Contains(violation.Error(), "user") {
+			t.Errorf("an admin lengthened a booking of a user to 3h: %v", err)
+		}
+	}
+
+	t.Run("update", func(t *testing.T) {
+		booking, err := service.Create(owner, CreateBookingRequest{ResourceID: 1, StartTime: start, EndTime: start.Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		_, err = service.Update(admin, booking.ID, UpdateBookingRequest{EndTime: &end})
+		checkViolation(t, err)
+
+		// The admin's own bookings are not limited
+		booking, err = service.Create(admin, CreateBookingRequest{ResourceID: 2, StartTime: start, EndTime: start.Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if _, err := service.Update(admin, booking.ID, UpdateBookingRequest{EndTime: &end}); err != nil {
+			t.Errorf("an admin could not lengthen their own booking: %v", err)
+		}
+	})
+
+	t.Run("following", func(t *testing.T) {
+		created, err := service.CreateSeries(owner, CreateBookingRequest{
+			ResourceID: 3,
+			StartTime:  start,
+			EndTime:    start.Add(time.Hour),
+			Recurrence: "FREQ=WEEKLY;BYDAY=MO;COUNT=3",
+		})
+		if err != nil {
+			t.Fatalf("CreateSeries: %v", err)
+		}
+		result, err := service.UpdateFollowing(admin, created.Bookings[0].ID, UpdateBookingRequest{EndTime: &end})
+		if err != nil {
+			t.Fatalf("UpdateFollowing: %v", err)
+		}
+		if len(result.Failed) != 3 {
+			t.Fatalf("UpdateFollowing failed %d occurrences, want 3", len(result.Failed))
+		}
+		if !strings.Contains(result.Failed[0].Reason, "user") {
+			t.Errorf("occurrence failed with %q, want a policy violation", result.Failed[0].Reason)
+		}
+	})
+
+	t.Run("group", func(t *testing.T) {
+		group, err := service.CreateGroup(owner, CreateBookingGroupRequest{ResourceIDs: []int{4, 5}, StartTime: start, EndTime: start.Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("CreateGroup: %v", err)
+		}
+		_, err = service.RescheduleGroup(admin, group.ID, UpdateBookingRequest{EndTime: &end})
+		checkViolation(t, err)
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQPPublisher publishes events to a durable topic exchange on RabbitMQ, using
+// the event type as routing key. Each publish waits for the broker confirm. The
+// connection is opened on first use and reopened after a failure.
+type AMQPPublisher struct {
+	url      string
+	exchange string
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	mutex    sync.Mutex
+}
+
+func NewAMQPPublisher(url, exchange string) *AMQPPublisher {
+	return &AMQPPublisher{
+		url:      url,
+		exchange: exchange,
+	}
+}
+
+func (p *AMQPPublisher) Publish(ctx context.Context, event *OutboxEvent) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	channel, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx, p.exchange, event.Type, false, false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    event.EventID,
+			Type:         event.Type,
+			Timestamp:    event.CreatedAt,
+			Headers:      amqp.Table{"booking_id": strconv.Itoa(event.BookingID)},
+			Body:         event.Payload,
+		})
+	if err != nil {
+		p.reset()
+		return fmt.Errorf("failed to publish to %s: %w", p.exchange, err)
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		p.reset()
+		return fmt.Errorf("no publish confirm from broker: %w", err)
+	}
+	if !acked {
+		return errors.New("broker rejected the event")
+	}
+
+	return nil
+}
+
+func (p *AMQPPublisher) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn, p.channel = nil, nil
+	return err
+}
+
+// connect returns an open channel in confirm mode, dialing if needed.
+// The caller must hold the mutex.
+func (p *AMQPPublisher) connect() (*amqp.Channel, error) {
+	if p.channel != nil && !p.channel.IsClosed() {
+		return p.channel, nil
+	}
+	p.reset()
+
+	conn, err := amqp.Dial(p.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err == nil {
+		err = channel.ExchangeDeclare(p.exchange, amqp.ExchangeTopic, true, false, false, false, nil)
+	}
+	if err == nil {
+		err = channel.Confirm(false)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to set up RabbitMQ channel: %w", err)
+	}
+
+	p.conn, p.channel = conn, channel
+	return channel, nil
+}
+
+// reset drops a broken connection. The caller must hold the mutex.
+func (p *AMQPPublisher) reset() {
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+	p.conn, p.channel = nil, nil
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rolling periods for hour budgets
+const (
+	QuotaWeek  = 7 * 24 * time.Hour
+	QuotaMonth = 30 * 24 * time.Hour
+
+	// quotaHorizon is how far ahead GET /users/{id}/quota looks for booked hours
+	quotaHorizon = 365 * 24 * time.Hour
+)
+
+// Limits reported in QuotaUsage
+const (
+	QuotaLimitActiveBookings = "active_bookings"
+	QuotaLimitHoursPerWeek   = "hours_per_week"
+	QuotaLimitHoursPerMonth  = "hours_per_month"
+)
+
+// QuotaLimits caps what a user may hold. A nil field inherits the limit from the
+// less specific level; zero means unlimited.
+type QuotaLimits struct {
+	MaxActiveBookings *int     `json:"max_active_bookings,omitempty"`
+	MaxHoursPerWeek   *float64 `json:"max_hours_per_week,omitempty"`
+	MaxHoursPerMonth  *float64 `json:"max_hours_per_month,omitempty"`
+}
+
+// QuotaGroup gives its members their own limits
+type QuotaGroup struct {
+	Members []int `json:"members"`
+	QuotaLimits
+}
+
+// QuotaConfig is the content of BOOKING_QUOTAS_FILE. Role limits override the
+// defaults; group limits override role limits, and a user in several groups gets
+// the highest limit of each.
+type QuotaConfig struct {
+	Default QuotaLimits            `json:"default"`
+	Roles   map[string]QuotaLimits `json:"roles"`
+	Groups  map[string]QuotaGroup  `json:"groups"`
+}
+
+// QuotaGrant temporarily raises the limits of one user
+type QuotaGrant struct {
+	ID                  int       `json:"id" db:"id"`
+	UserID              int       `json:"user_id" db:"user_id"`
+	ExtraActiveBookings int       `json:"extra_active_bookings" db:"extra_active_bookings"`
+	ExtraHoursPerWeek   float64   `json:"extra_hours_per_week" db:"extra_hours_per_week"`
+	ExtraHoursPerMonth  float64   `json:"extra_hours_per_month" db:"extra_hours_per_month"`
+	Reason              string    `json:"reason" db:"reason"`
+	GrantedBy           int       `json:"granted_by" db:"granted_by"`
+	ExpiresAt           time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt           time.Time `json:"created_at" db:"created_at"`
+}
+
+// GrantQuotaRequest represents the request to raise a user's limits until ExpiresAt
+type GrantQuotaRequest struct {
+	ExtraActiveBookings int       `json:"extra_active_bookings" validate:"min=0"`
+	ExtraHoursPerWeek   float64   `json:"extra_hours_per_week" validate:"min=0"`
+	ExtraHoursPerMonth  float64   `json:"extra_hours_per_month" validate:"min=0"`
+	Reason              string    `json:"reason" validate:"max=500"`
+	ExpiresAt           time.Time `json:"expires_at" validate:"required"`
+}
+
+// QuotaUsage is the usage of one limit. Hours are counted in the busiest rolling
+// period; Max and Remaining are omitted for unlimited quotas.
+type QuotaUsage struct {
+	Limit     string   `json:"limit"`
+	Used      float64  `json:"used"`
+	Max       *float64 `json:"max,omitempty"`
+	Remaining *float64 `json:"remaining,omitempty"`
+}
+
+// UserQuota is the response of GET /api/v1/users/{user_id}/quota
+type UserQuota struct {
+	UserID int           `json:"user_id"`
+	Role   string        `json:"role"`
+	Groups []string      `json:"groups,omitempty"`
+	Usage  []QuotaUsage  `json:"usage"`
+	Grants []*QuotaGrant `json:"grants"`
+}
+
+// QuotaExceededError is returned when a booking would take a user over a limit
+type QuotaExceededError struct {
+	Exceeded []QuotaUsage
+}
+
+func (e *QuotaExceededError) Error() string {
+	limits := make([]string, len(e.Exceeded))
+	for i, usage := range e.Exceeded {
+		limits[i] = fmt.Sprintf("%s (%g of %g)", usage.Limit, usage.Used, *usage.Max)
+	}
+	return "booking exceeds quota: " + strings.Join(limits, ", ")
+}
+
+// effectiveLimits are the resolved limits of a user; zero means unlimited
+type effectiveLimits struct {
+	activeBookings int
+	hoursPerWeek   float64
+	hoursPerMonth  float64
+	groups         []string
+}
+
+// loadQuotas reads BOOKING_QUOTAS_FILE. MAX_CONCURRENT_BOOKINGS_PER_USER,
+// MAX_BOOKING_HOURS_PER_WEEK and MAX_BOOKING_HOURS_PER_MONTH provide defaults
+// that the file can override.
+func loadQuotas(config Config) QuotaConfig {
+	quotas := QuotaConfig{}
+	if config.QuotasFile != "" {
+		data, err := os.ReadFile(config.QuotasFile)
+		if err != nil {
+			log.Fatalf("Failed to read booking quotas: %v", err)
+		}
+		if err := json.Unmarshal(data, &quotas); err != nil {
+			log.Fatalf("Invalid booking quotas in %s: %v", config.QuotasFile, err)
+		}
+	}
+
+	if quotas.Default.MaxActiveBookings == nil && config.MaxActiveBookings > 0 {
+		limit := config.MaxActiveBookings
+		quotas.Default.MaxActiveBookings = &limit
+	}
+	if quotas.Default.MaxHoursPerWeek == nil && config.MaxHoursPerWeek > 0 {
+		limit := config.MaxHoursPerWeek
+		quotas.Default.MaxHoursPerWeek = &limit
+	}
+	if quotas.Default.MaxHoursPerMonth == nil && config.MaxHoursPerMonth > 0 {
+		limit := config.MaxHoursPerMonth
+		quotas.Default.MaxHoursPerMonth = &limit
+	}
+
+	return quotas
+}
+
+// limitsFor resolves the limits of a user with the given role, without grants
+func (c QuotaConfig) limitsFor(userID int, role string) effectiveLimits {
+	limits := QuotaLimits{
+		MaxActiveBookings: c.Default.MaxActiveBookings,
+		MaxHoursPerWeek:   c.Default.MaxHoursPerWeek,
+		MaxHoursPerMonth:  c.Default.MaxHoursPerMonth,
+	}
+	if roleLimits, ok := c.Roles[role]; ok {
+		limits.MaxActiveBookings = inherit(roleLimits.MaxActiveBookings, limits.MaxActiveBookings)
+		limits.MaxHoursPerWeek = inherit(roleLimits.MaxHoursPerWeek, limits.MaxHoursPerWeek)
+		limits.MaxHoursPerMonth = inherit(roleLimits.MaxHoursPerMonth, limits.MaxHoursPerMonth)
+	}
+
+	result := effectiveLimits{}
+	var groupLimits []QuotaLimits
+	for name, group := range c.Groups {
+		for _, member := range group.Members {
+			if member == userID {
+				result.groups = append(result.groups, name)
+				groupLimits = append(groupLimits, group.QuotaLimits)
+				break
+			}
+		}
+	}
+	sort.Strings(result.groups)
+
+	result.activeBookings = highestLimit(limits.MaxActiveBookings, groupLimits,
+		func(l QuotaLimits) *int { return l.MaxActiveBookings })
+	result.hoursPerWeek = highestLimit(limits.MaxHoursPerWeek, groupLimits,
+		func(l QuotaLimits) *float64 { return l.MaxHoursPerWeek })
+	result.hoursPerMonth = highestLimit(limits.MaxHoursPerMonth, groupLimits,
+		func(l QuotaLimits) *float64 { return l.MaxHoursPerMonth })
+
+	return result
+}
+
+func inherit[T any](value, fallback *T) *T {
+	if value != nil {
+		return value
+	}
+	return fallback
+}
+
+// highestLimit returns the highest limit set by the user's groups, or base when
+// no group sets one. Zero (unlimited) beats any number.
+func highestLimit[T int | float64](base *T, groups []QuotaLimits, field func(QuotaLimits) *T) T {
+	var result *T
+	for _, limits := range groups {
+		value := field(limits)
+		if value == nil {
+			continue
+		}
+		if result == nil || *value == 0 || (*result != 0 && *value > *result) {
+			result = value
+		}
+	}
+	if result == nil {
+		result = base
+	}
+	if result == nil {
+		return 0
+	}
+	return *result
+}
+
+// quotaLimits resolves the limits of a user including unexpired grants
+func (s *BookingService) quotaLimits(userID int, role string, now time.Time) (effectiveLimits, []*QuotaGrant, error) {
+	limits := s.quotas.limitsFor(userID, role)
+
+	grants, err := s.repository.GetQuotaGrants(userID, now)
+	if err != nil {
+		return limits, nil, fmt.Errorf("failed to get quota grants: %w", err)
+	}
+
+	// Grants only raise finite limits; unlimited stays unlimited
+	for _, grant := range grants {
+		if limits.activeBookings > 0 {
+			limits.activeBookings += grant.ExtraActiveBookings
+		}
+		if limits.hoursPerWeek > 0 {
+			limits.hoursPerWeek += grant.ExtraHoursPerWeek
+		}
+		if limits.hoursPerMonth > 0 {
+			limits.hoursPerMonth += grant.ExtraHoursPerMonth
+		}
+	}
+
+	return limits, grants, nil
+}
+
+// checkQuota verifies that storing the bookings keeps the caller within their
+// limits. Bookings with an ID are being moved and replace their stored version;
+// the others are new. Admins and managers changing another user's bookings are
+// not limited. The check runs before the write, so concurrent requests of one
+// user may overshoot a limit by one booking.
+func (s *BookingService) checkQuota(caller Identity, bookings ...*Booking) error {
+	if len(bookings) == 0 || bookings[0].UserID != caller.UserID {
+		return nil
+	}
+
+	now := time.Now()
+	limits, _, err := s.quotaLimits(caller.UserID, caller.Role, now)
+	if err != nil {
+		return err
+	}
+
+	added := 0
+	moving := make(map[int]bool)
+	from, to := bookings[0].StartTime, bookings[0].EndTime
+	var requested float64
+	for _, booking := range bookings {
+		if booking.ID == 0 {
+			added++
+		} else {
+			moving[booking.ID] = true
+		}
+		if booking.StartTime.Before(from) {
+			from = booking.StartTime
+		}
+		if booking.EndTime.After(to) {
+			to = booking.EndTime
+		}
+		requested += booking.Duration().Hours()
+	}
+
+	var exceeded []QuotaUsage
+	if limits.activeBookings > 0 && added > 0 {
+		active, err := s.repository.CountActiveBookings(caller.UserID, now)
+		if err != nil {
+			return fmt.Errorf("failed to count active bookings: %w", err)
+		}
+		if active+added > limits.activeBookings {
+			exceeded = append(exceeded, newQuotaUsage(QuotaLimitActiveBookings, float64(active), float64(limits.activeBookings)))
+		}
+	}
+
+	for _, budget := range []struct {
+		limit  string
+		period time.Duration
+		max    float64
+	}{
+		{QuotaLimitHoursPerWeek, QuotaWeek, limits.hoursPerWeek},
+		{QuotaLimitHoursPerMonth, QuotaMonth, limits.hoursPerMonth},
+	} {
+		if budget.max <= 0 {
+			continue
+		}
+
+		existing, err := s.repository.GetUserBookingsBetween(caller.UserID, from.Add(-budget.period), to.Add(budget.period))
+		if err != nil {
+			return fmt.Errorf("failed to get booked hours: %w", err)
+		}
+
+		candidates := append([]*Booking{}, bookings...)
+		for _, other := range existing {
+			if !moving[other.ID] {
+				candidates = append(candidates, other)
+			}
+		}
+
+		peak := peakHours(candidates, budget.period, from, to)
+		if peak > budget.max {
+			used := max(peak-requested, 0)
+			exceeded = append(exceeded, newQuotaUsage(budget.limit, used, budget.max))
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return &QuotaExceededError{Exceeded: exceeded}
+	}
+
+	return nil
+}
+
+// GetQuota reports a user's limits, current usage and unexpired grants. Hour
+// usage is the busiest rolling period that overlaps the coming year. The role of
+// a user other than the caller is looked up in user-service.
+func (s *BookingService) GetQuota(caller Identity, userID int) (*UserQuota, error) {
+	role := caller.Role
+	if userID != caller.UserID {
+		user, err := s.users.GetUser(userID)
+		if err != nil {
+			return nil, err
+		}
+		role = user.Role
+	}
+
+	now := time.Now()
+	limits, grants, err := s.quotaLimits(userID, role, now)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := s.repository.CountActiveBookings(userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count active bookings: %w", err)
+	}
+
+	quota := &UserQuota{
+		UserID: userID,
+		Role:   role,
+		Groups: limits.groups,
+		Usage:  []QuotaUsage{newQuotaUsage(QuotaLimitActiveBookings, float64(active), float64(limits.activeBookings))},
+		Grants: grants,
+	}
+	if quota.Grants == nil {
+		quota.Grants = []*QuotaGrant{}
+	}
+
+	for _, budget := range []struct {
+		limit  string
+		period time.Duration
+		max    float64
+	}{
+		{QuotaLimitHoursPerWeek, QuotaWeek, limits.hoursPerWeek},
+		{QuotaLimitHoursPerMonth, QuotaMonth, limits.hoursPerMonth},
+	} {
+		bookings, err := s.repository.GetUserBookingsBetween(userID, now.Add(-budget.period), now.Add(quotaHorizon))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get booked hours: %w", err)
+		}
+
+		peak := peakHours(bookings, budget.period, now, now.Add(quotaHorizon))
+		quota.Usage = append(quota.Usage, newQuotaUsage(budget.limit, peak, budget.max))
+	}
+
+	return quota, nil
+}
+
+// GrantQuota raises a user's limits until the grant expires
+func (s *BookingService) GrantQuota(grantedBy, userID int, req GrantQuotaRequest) (*QuotaGrant, error) {
+	if _, err := s.users.GetUser(userID); err != nil {
+		return nil, err
+	}
+
+	grant := &QuotaGrant{
+		UserID:              userID,
+		ExtraActiveBookings: req.ExtraActiveBookings,
+		ExtraHoursPerWeek:   req.ExtraHoursPerWeek,
+		ExtraHoursPerMonth:  req.ExtraHoursPerMonth,
+		Reason:              req.Reason,
+		GrantedBy:           grantedBy,
+		ExpiresAt:           req.ExpiresAt,
+		CreatedAt:           time.Now(),
+	}
+
+	if err := s.repository.CreateQuotaGrant(grant); err != nil {
+		return nil, fmt.Errorf("failed to grant quota: %w", err)
+	}
+
+	return grant, nil
+}
+
+// newQuotaUsage describes one limit; max 0 means unlimited
+func newQuotaUsage(limit string, used, max float64) QuotaUsage {
+	usage := QuotaUsage{Limit: limit, Used: roundHours(used)}
+	if max > 0 {
+		remaining := roundHours(max - used)
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.Max = &max
+		usage.Remaining = &remaining
+	}
+	return usage
+}
+
+// roundHours keeps two decimals
+func roundHours(hours float64) float64 {
+	return float64(int64(hours*100+0.5)) / 100
+}
+
+// peakHours returns the most hours booked in any window of the given length that
+// overlaps [from, to). The busiest window always starts at a booking start or ends
+// at a booking end, so only those windows are checked.
+func peakHours(bookings []*Booking, period time.Duration, from, to time.Time) float64 {
+	var starts []time.Time
+	for _, booking := range bookings {
+		starts = append(starts, booking.StartTime, booking.EndTime.Add(-period))
+	}
+
+	var peak time.Duration
+	for _, start := range starts {
+		end := start.Add(period)
+		if !start.Before(to) || !end.After(from) {
+			continue
+		}
+
+		var total time.Duration
+		for _, booking := range bookings {
+			overlapStart, overlapEnd := booking.StartTime, booking.EndTime
+			if overlapStart.Before(start) {
+				overlapStart = start
+			}
+			if overlapEnd.After(end) {
+				overlapEnd = end
+			}
+			if overlapEnd.After(overlapStart) {
+				total += overlapEnd.Sub(overlapStart)
+			}
+		}
+		if total > peak {
+			peak = total
+		}
+	}
+
+	return peak.Hours()
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxSeriesOccurrences caps how many bookings a single recurrence rule may expand to
+const MaxSeriesOccurrences = 365
+
+// RecurrenceFrequency is the FREQ part of a recurrence rule
+type RecurrenceFrequency string
+
+const (
+	FrequencyDaily   RecurrenceFrequency = "DAILY"
+	FrequencyWeekly  RecurrenceFrequency = "WEEKLY"
+	FrequencyMonthly RecurrenceFrequency = "MONTHLY"
+)
+
+// RecurrenceDay is one BYDAY entry. Ordinal is only used with MONTHLY rules
+// (1MO = first Monday, -1FR = last Friday); zero means every such weekday.
+type RecurrenceDay struct {
+	Weekday time.Weekday
+	Ordinal int
+}
+
+// RecurrenceRule is the supported subset of an RFC 5545 RRULE:
+// FREQ=DAILY|WEEKLY|MONTHLY with INTERVAL, COUNT or UNTIL, and BYDAY.
+type RecurrenceRule struct {
+	Frequency RecurrenceFrequency
+	Interval  int
+	Count     int
+	Until     time.Time
+	ByDay     []RecurrenceDay
+}
+
+// TimeRange is a single occurrence produced by a recurrence rule
+type TimeRange struct {
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ParseRecurrenceRule parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO;COUNT=10".
+// A leading "RRULE:" prefix is accepted.
+func ParseRecurrenceRule(value string) (*RecurrenceRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	rule := &RecurrenceRule{Interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		name, val, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid recurrence rule part: %s", part)
+		}
+
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Frequency = RecurrenceFrequency(strings.ToUpper(val))
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL: %s", val)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid COUNT: %s", val)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseRecurrenceUntil(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				parsed, err := parseRecurrenceDay(day)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, parsed)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part: %s", name)
+		}
+	}
+
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (r *RecurrenceRule) validate() error {
+	switch r.Frequency {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+	case "":
+		return fmt.Errorf("recurrence rule requires FREQ")
+	default:
+		return fmt.Errorf("unsupported FREQ: %s", r.Frequency)
+	}
+
+	if r.Count > 0 && !r.Until.IsZero() {
+		return fmt.Errorf("COUNT and UNTIL cannot be combined")
+	}
+	if r.Count == 0 && r.Until.IsZero() {
+		return fmt.Errorf("recurrence rule requires COUNT or UNTIL")
+	}
+	if r.Count > MaxSeriesOccurrences {
+		return fmt.Errorf("COUNT cannot exceed %d", MaxSeriesOccurrences)
+	}
+
+	for _, day := range r.ByDay {
+		if day.Ordinal != 0 && r.Frequency != FrequencyMonthly {
+			return fmt.Errorf("BYDAY ordinals are only supported with FREQ=MONTHLY")
+		}
+	}
+
+	return nil
+}
+
+// String formats the rule back into RRULE syntax
+func (r *RecurrenceRule) String() string {
+	parts := []string{"FREQ=" + string(r.Frequency)}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, day := range r.ByDay {
+			code := strings.ToUpper(day.Weekday.String()[:2])
+			if day.Ordinal != 0 {
+				code = strconv.Itoa(day.Ordinal) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences expands the rule starting at the first booking's time range.
+// Every occurrence keeps the wall-clock start time and the duration of the first
+// one; candidate dates before start or that do not exist (e.g. February 30) are skipped.
+func (r *RecurrenceRule) Occurrences(start, end time.Time) []TimeRange {
+	duration := end.Sub(start)
+	var occurrences []TimeRange
+
+	// emit reports whether expansion should continue
+	emit := func(t time.Time) bool {
+		if t.Before(start) {
+			return true
+		}
+		if !r.Until.IsZero() && t.After(r.Until) {
+			return false
+		}
+		occurrences = append(occurrences, TimeRange{StartTime: t, EndTime: t.Add(duration)})
+		if r.Count > 0 && len(occurrences) >= r.Count {
+			return false
+		}
+		return len(occurrences) < MaxSeriesOccurrences
+	}
+
+	// Each period yields at most a handful of candidates, so this bound is only a
+	// safety net for rules whose BYDAY never matches
+	for period := 0; period < MaxSeriesOccurrences*5; period++ {
+		if !r.expandPeriod(start, period, emit) {
+			break
+		}
+	}
+
+	return occurrences
+}
+
+// expandPeriod emits the candidates of one FREQ period in chronological order
+func (r *RecurrenceRule) expandPeriod(start time.Time, period int, emit func(time.Time) bool) bool {
+	step := period * r.Interval
+
+	switch r.Frequency {
+	case FrequencyDaily:
+		t := start.AddDate(0, 0, step)
+		if len(r.ByDay) > 0 && !r.matchesWeekday(t.Weekday()) {
+			return true
+		}
+		return emit(t)
+
+	case FrequencyWeekly:
+		// Weeks start on Monday (RFC 5545 default WKST)
+		weekStart := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*step)
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []RecurrenceDay{{Weekday: start.Weekday()}}
+		}
+		var offsets []int
+		for _, day := range days {
+			offsets = append(offsets, (int(day.Weekday)+6)%7)
+		}
+		sort.Ints(offsets)
+		for _, offset := range offsets {
+			if !emit(weekStart.AddDate(0, 0, offset)) {
+				return false
+			}
+		}
+		return true
+
+	case FrequencyMonthly:
+		monthStart := time.Date(start.Year(), start.Month()+time.Month(step), 1,
+			start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		for _, t := range r.monthlyCandidates(start, monthStart) {
+			if !emit(t) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// monthlyCandidates returns the sorted occurrence dates within one month
+func (r *RecurrenceRule) monthlyCandidates(start, monthStart time.Time) []time.Time {
+	daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+	atDay := func(day int) time.Time {
+		return monthStart.AddDate(0, 0, day-1)
+	}
+
+	if len(r.ByDay) == 0 {
+		if start.Day() > daysInMonth {
+			return nil
+		}
+		return []time.Time{atDay(start.Day())}
+	}
+
+	seen := make(map[int]bool)
+	var days []int
+	for _, byDay := range r.ByDay {
+		var matching []int
+		for day := 1; day <= daysInMonth; day++ {
+			if atDay(day).Weekday() == byDay.Weekday {
+				matching = append(matching, day)
+			}
+		}
+
+		switch {
+		case byDay.Ordinal == 0:
+			for _, day := range matching {
+				if !seen[day] {
+					seen[day] = true
+					days = append(days, day)
+				}
+			}
+		case byDay.Ordinal > 0 && byDay.Ordinal <= len(matching):
+			if day := matching[byDay.Ordinal-1]; !seen[day] {
+				seen[day] = true
+				days = append(days, day)
+			}
+		case byDay.Ordinal < 0 && -byDay.Ordinal <= len(matching):
+			if day := matching[len(matching)+byDay.Ordinal]; !seen[day] {
+				seen[day] = true
+				days = append(days, day)
+			}
+		}
+	}
+
+	sort.Ints(days)
+	var candidates []time.Time
+	for _, day := range days {
+		candidates = append(candidates, atDay(day))
+	}
+	return candidates
+}
+
+// shiftWeekdays moves BYDAY weekdays by as many days as there are between the
+// weekdays of from and to, keeping their ordinals
+func shiftWeekdays(days []RecurrenceDay, from, to time.Time) []RecurrenceDay {
+	if len(days) == 0 {
+		return nil
+	}
+
+	delta := (to.Weekday() - from.Weekday() + 7) % 7
+	shifted := make([]RecurrenceDay, len(days))
+	for i, day := range days {
+		shifted[i] = RecurrenceDay{Weekday: (day.Weekday + delta) % 7, Ordinal: day.Ordinal}
+	}
+	return shifted
+}
+
+func (r *RecurrenceRule) matchesWeekday(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRecurrenceDay parses a BYDAY entry such as "MO", "2TU" or "-1FR"
+func parseRecurrenceDay(value string) (RecurrenceDay, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) < 2 {
+		return RecurrenceDay{}, fmt.Errorf("invalid BYDAY: %s", value)
+	}
+
+	weekday, ok := weekdayCodes[value[len(value)-2:]]
+	if !ok {
+		return RecurrenceDay{}, fmt.Errorf("invalid BYDAY: %s", value)
+	}
+
+	day := RecurrenceDay{Weekday: weekday}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		ordinal, err := strconv.Atoi(prefix)
+		if err != nil || ordinal == 0 || ordinal < -5 || ordinal > 5 {
+			return RecurrenceDay{}, fmt.Errorf("invalid BYDAY: %s", value)
+		}
+		day.Ordinal = ordinal
+	}
+
+	return day, nil
+}
+
+// parseRecurrenceUntil parses UNTIL as a UTC date-time or a date (inclusive end of day)
+func parseRecurrenceUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL: %s", value)
+}
@@ -1,47 +1,210 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
-// BookingRepository defines the interface for booking data access
+var (
+	// ErrNotFound is matched by the errors returned for unknown bookings, series,
+	// groups and waitlist entries
+	ErrNotFound = errors.New("not found")
+	// ErrBookingConflict is returned when a booking overlaps an existing active booking
+	ErrBookingConflict = errors.New("resource is not available for the selected time slot")
+	// ErrBookingChanged is returned when a booking left the expected status before
+	// a status change could be applied (e.g. it was confirmed while expiring)
+	ErrBookingChanged = errors.New("booking was modified concurrently")
+	// ErrWaitlistEntryChanged is returned when a waitlist entry left the expected status
+	// before an update could be applied (e.g. another replica processed it)
+	ErrWaitlistEntryChanged = errors.New("waitlist entry was modified concurrently")
+)
+
+// ResourceConflictError is returned by group writes and names the member resource
+// that is not available. It matches ErrBookingConflict with errors.Is.
+type ResourceConflictError struct {
+	ResourceID int
+}
+
+func (e *ResourceConflictError) Error() string {
+	return fmt.Sprintf("resource %d: %v", e.ResourceID, ErrBookingConflict)
+}
+
+func (e *ResourceConflictError) Unwrap() error {
+	return ErrBookingConflict
+}
+
+// BookingRepository defines the interface for booking data access.
+// Methods that write a booking take the types of the events the change produces;
+// the events are stored in the outbox atomically with the booking.
 type BookingRepository interface {
-	Create(booking *Booking) error
+	Create(booking *Booking, events ...BookingEventType) error
 	GetByID(id int) (*Booking, error)
-	Update(booking *Booking) error
+	// Update stores the booking only if its stored status is still the one the
+	// booking carries, so a cancellation or expiry committed after the booking was
+	// read is not overwritten. It returns ErrBookingChanged otherwise. Status
+	// changes go through UpdateIfStatus.
+	Update(booking *Booking, events ...BookingEventType) error
 	Delete(id int) error
 	List(query ListBookingsQuery, limit, offset int) ([]*Booking, error)
 	GetConflictingBookings(resourceID int, startTime, endTime time.Time) ([]*Booking, error)
+	// CreateIfAvailable inserts the booking only if it fits the resource's occupancy
+	// next to the active bookings within the buffer gap of it, checking and inserting
+	// atomically. It returns ErrBookingConflict otherwise.
+	CreateIfAvailable(booking *Booking, occupancy Occupancy, events ...BookingEventType) error
+	// UpdateIfAvailable stores the booking only if its new time range fits the
+	// resource's occupancy next to the other active bookings, checking and updating
+	// atomically. It returns ErrBookingConflict otherwise, and ErrBookingChanged
+	// like Update if the stored status changed.
+	UpdateIfAvailable(booking *Booking, occupancy Occupancy, events ...BookingEventType) error
+	// UpdateIfStatus stores the booking only if its stored status is still
+	// fromStatus. It returns ErrBookingChanged otherwise.
+	UpdateIfStatus(booking *Booking, fromStatus BookingStatus, events ...BookingEventType) error
+	// GetExpiredHolds returns PENDING bookings whose hold expired before now
+	GetExpiredHolds(now time.Time) ([]*Booking, error)
+	// GetFinishedBookings returns up to limit CONFIRMED bookings that ended before now
+	GetFinishedBookings(now time.Time, limit int) ([]*Booking, error)
+	// GetNoShows returns CONFIRMED bookings that started at or before startedBefore
+	// and have not ended by now. Bookings that ended are left for completion.
+	GetNoShows(startedBefore, now time.Time) ([]*Booking, error)
 	GetByUserID(userID int, limit, offset int) ([]*Booking, error)
+	// GetUserBookingsBetween returns the user's non-canceled bookings overlapping the period
+	GetUserBookingsBetween(userID int, startTime, endTime time.Time) ([]*Booking, error)
+	// CountActiveBookings counts the user's PENDING, CONFIRMED and CHECKED_IN bookings ending after now
+	CountActiveBookings(userID int, now time.Time) (int, error)
 	GetByResourceID(resourceID int, limit, offset int) ([]*Booking, error)
+	GetBySeriesID(seriesID int) ([]*Booking, error)
+	CreateSeries(series *BookingSeries) error
+	GetSeries(id int) (*BookingSeries, error)
+	UpdateSeries(series *BookingSeries) error
+	GetByGroupID(groupID int) ([]*Booking, error)
+	// CreateGroupIfAvailable stores the group and its bookings only if bookings[i]
+	// fits occupancies[i] for every member, checking and inserting atomically.
+	// It returns a *ResourceConflictError for the first blocked resource otherwise.
+	CreateGroupIfAvailable(group *BookingGroup, bookings []*Booking, occupancies []Occupancy, events ...BookingEventType) error
+	// UpdateGroupIfAvailable stores the group and moves its bookings under the same
+	// rules as CreateGroupIfAvailable: either every booking is stored or none is.
+	// It returns ErrBookingChanged like Update if a member's stored status changed.
+	UpdateGroupIfAvailable(group *BookingGroup, bookings []*Booking, occupancies []Occupancy, events ...BookingEventType) error
+	// UpdateGroupIfStatus stores the group and its bookings in one transaction,
+	// each booking only if its stored status is still fromStatuses[i]. Either
+	// every booking is stored or none is; it returns ErrBookingChanged otherwise.
+	UpdateGroupIfStatus(group *BookingGroup, bookings []*Booking, fromStatuses []BookingStatus, events ...BookingEventType) error
+	GetGroup(id int) (*BookingGroup, error)
+	CreateWaitlistEntry(entry *WaitlistEntry) error
+	GetWaitlistEntry(id int) (*WaitlistEntry, error)
+	// UpdateWaitlistEntry stores the entry only if its stored status is still
+	// fromStatus. It returns ErrWaitlistEntryChanged otherwise.
+	UpdateWaitlistEntry(entry *WaitlistEntry, fromStatus WaitlistStatus) error
+	ListWaitlistEntries(query ListWaitlistQuery, limit, offset int) ([]*WaitlistEntry, error)
+	// GetWaitingEntries returns WAITING entries overlapping the period, oldest first
+	GetWaitingEntries(resourceID int, startTime, endTime time.Time) ([]*WaitlistEntry, error)
+	// GetExpiredOffers returns OFFERED entries whose offer expired before now
+	GetExpiredOffers(now time.Time) ([]*WaitlistEntry, error)
+	// AddAttendee stores a new attendee of the booking. It returns ErrAttendeeExists
+	// if the same user or email is already invited.
+	AddAttendee(booking *Booking, attendee *Attendee, events ...BookingEventType) error
+	// GetAttendees returns the attendees of a booking in the order they were invited
+	GetAttendees(bookingID int) ([]*Attendee, error)
+	UpdateAttendee(booking *Booking, attendee *Attendee, events ...BookingEventType) error
+	RemoveAttendee(booking *Booking, attendee *Attendee, events ...BookingEventType) error
+	// CreateCalendarFeed stores a feed and revokes the owner's previous feeds
+	CreateCalendarFeed(feed *CalendarFeed) error
+	// GetCalendarFeed returns the unrevoked feed with the token hash or ErrCalendarFeedNotFound
+	GetCalendarFeed(tokenHash string) (*CalendarFeed, error)
+	RevokeCalendarFeeds(ownerType CalendarFeedOwner, ownerID int, now time.Time) error
+	CreateQuotaGrant(grant *QuotaGrant) error
+	// GetQuotaGrants returns the user's grants that expire after now, oldest first
+	GetQuotaGrants(userID int, now time.Time) ([]*QuotaGrant, error)
+	// DeliverOutboxEvents passes up to limit undelivered events, oldest first, to
+	// deliver and marks the first n it reports as delivered. Only one caller at a
+	// time receives events, so concurrent relays cannot reorder them.
+	DeliverOutboxEvents(limit int, deliver func(events []*OutboxEvent) (n int)) (int, error)
+}
+
+// NewBookingRepository creates the repository selected by the storage driver configuration
+func NewBookingRepository(cfg Config) (BookingRepository, error) {
+	switch cfg.StorageDriver {
+	case StorageDriverMemory:
+		return NewInMemoryBookingRepository(), nil
+	case StorageDriverPostgres:
+		db, err := sql.Open("postgres", cfg.DatabaseDSN())
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		db.SetMaxOpenConns(cfg.DBMaxConnections)
+		db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+		if err := db.Ping(); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		return NewPostgreSQLBookingRepository(db), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.StorageDriver)
+	}
 }
 
-// InMemoryBookingRepository is a simple in-memory implementation
-// TODO: Replace with actual database implementation (PostgreSQL)
+// InMemoryBookingRepository is a simple in-memory implementation for development.
+// Bookings are indexed per resource and per user in interval trees ordered by
+// start time, so conflict checks and per-resource queries do not scan every booking.
 type InMemoryBookingRepository struct {
-	bookings map[int]*Booking
-	nextID   int
-	mutex    sync.RWMutex
+	bookings     map[int]*Booking
+	byResource   map[int]*intervalTree
+	byUser       map[int]*intervalTree
+	bySeries     map[int]*intervalTree
+	byGroup      map[int]*intervalTree
+	series       map[int]*BookingSeries
+	groups       map[int]*BookingGroup
+	waitlist     map[int]*WaitlistEntry
+	attendees    map[int][]*Attendee // By booking ID, in invitation order
+	feeds        map[int]*CalendarFeed
+	quotaGrants  map[int]*QuotaGrant
+	outbox       []*OutboxEvent // Undelivered events, oldest first
+	nextID       int
+	nextSeriesID int
+	nextGroupID  int
+	nextEntryID  int
+	nextAttendee int
+	nextFeedID   int
+	nextGrantID  int
+	nextEventID  int64
+	mutex        sync.RWMutex
+	// relayMutex lets only one relay deliver events at a time
+	relayMutex sync.Mutex
 }
 
-func NewBookingRepository() BookingRepository {
+func NewInMemoryBookingRepository() *InMemoryBookingRepository {
 	return &InMemoryBookingRepository{
-		bookings: make(map[int]*Booking),
-		nextID:   1,
+		bookings:     make(map[int]*Booking),
+		byResource:   make(map[int]*intervalTree),
+		byUser:       make(map[int]*intervalTree),
+		bySeries:     make(map[int]*intervalTree),
+		byGroup:      make(map[int]*intervalTree),
+		series:       make(map[int]*BookingSeries),
+		groups:       make(map[int]*BookingGroup),
+		waitlist:     make(map[int]*WaitlistEntry),
+		attendees:    make(map[int][]*Attendee),
+		feeds:        make(map[int]*CalendarFeed),
+		quotaGrants:  make(map[int]*QuotaGrant),
+		nextID:       1,
+		nextSeriesID: 1,
+		nextGroupID:  1,
+		nextEntryID:  1,
+		nextAttendee: 1,
+		nextFeedID:   1,
+		nextGrantID:  1,
+		nextEventID:  1,
 	}
 }
 
-func (r *InMemoryBookingRepository) Create(booking *Booking) error {
+func (r *InMemoryBookingRepository) Create(booking *Booking, events ...BookingEventType) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	booking.ID = r.nextID
-	r.nextID++
-
-	r.bookings[booking.ID] = booking
-	return nil
+	return r.insert(booking, events)
 }
 
 func (r *InMemoryBookingRepository) GetByID(id int) (*Booking, error) {
@@ -50,80 +213,205 @@ func (r *InMemoryBookingRepository) GetByID(id int) (*Booking, error) {
 
 	booking, exists := r.bookings[id]
 	if !exists {
-		return nil, fmt.Errorf("booking with ID %d not found", id)
+		return nil, fmt.Errorf("booking with ID %d %w", id, ErrNotFound)
 	}
 
-	return booking, nil
+	return cloneBooking(booking), nil
 }
 
-func (r *InMemoryBookingRepository) Update(booking *Booking) error {
+func (r *InMemoryBookingRepository) Update(booking *Booking, events ...BookingEventType) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if _, exists := r.bookings[booking.ID]; !exists {
-		return fmt.Errorf("booking with ID %d not found", booking.ID)
+	existing, exists := r.bookings[booking.ID]
+	if !exists {
+		return fmt.Errorf("booking with ID %d %w", booking.ID, ErrNotFound)
+	}
+	if existing.Status != booking.Status {
+		return ErrBookingChanged
 	}
 
-	r.bookings[booking.ID] = booking
-	return nil
+	return r.replace(existing, booking, events)
 }
 
-func (r *InMemoryBookingRepository) Delete(id int) error {
+func (r *InMemoryBookingRepository) UpdateIfStatus(booking *Booking, fromStatus BookingStatus, events ...BookingEventType) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if _, exists := r.bookings[id]; !exists {
-		return fmt.Errorf("booking with ID %d not found", id)
+	existing, exists := r.bookings[booking.ID]
+	if !exists {
+		return fmt.Errorf("booking with ID %d %w", booking.ID, ErrNotFound)
+	}
+	if existing.Status != fromStatus {
+		return ErrBookingChanged
 	}
 
-	delete(r.bookings, id)
-	return nil
+	return r.replace(existing, booking, events)
 }
 
-func (r *InMemoryBookingRepository) List(query ListBookingsQuery, limit, offset int) ([]*Booking, error) {
+func (r *InMemoryBookingRepository) GetExpiredHolds(now time.Time) ([]*Booking, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	var filtered []*Booking
+	var expired []*Booking
+	for _, booking := range r.bookings {
+		if booking.Status == BookingStatusPending && booking.ExpiresAt != nil && !booking.ExpiresAt.After(now) {
+			expired = append(expired, cloneBooking(booking))
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].ID < expired[j].ID
+	})
+
+	return expired, nil
+}
+
+func (r *InMemoryBookingRepository) GetFinishedBookings(now time.Time, limit int) ([]*Booking, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
 
-	// Filter bookings
+	var finished []*Booking
 	for _, booking := range r.bookings {
-		// Apply filters
-		if query.UserID > 0 && booking.UserID != query.UserID {
-			continue
+		if (booking.Status == BookingStatusConfirmed || booking.Status == BookingStatusCheckedIn) &&
+			!booking.EndTime.After(now) {
+			finished = append(finished, cloneBooking(booking))
 		}
+	}
 
-		if query.ResourceID > 0 && booking.ResourceID != query.ResourceID {
-			continue
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].ID < finished[j].ID
+	})
+	if len(finished) > limit {
+		finished = finished[:limit]
+	}
+
+	return finished, nil
+}
+
+func (r *InMemoryBookingRepository) GetNoShows(startedBefore, now time.Time) ([]*Booking, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var noShows []*Booking
+	for _, booking := range r.bookings {
+		if booking.Status == BookingStatusConfirmed && !booking.StartTime.After(startedBefore) && booking.EndTime.After(now) {
+			noShows = append(noShows, cloneBooking(booking))
 		}
+	}
+
+	sort.Slice(noShows, func(i, j int) bool {
+		return noShows[i].ID < noShows[j].ID
+	})
+
+	return noShows, nil
+}
 
+func (r *InMemoryBookingRepository) Delete(id int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.bookings[id]
+	if !exists {
+		return fmt.Errorf("booking with ID %d %w", id, ErrNotFound)
+	}
+
+	r.unindex(existing)
+	delete(r.attendees, id)
+	return nil
+}
+
+func (r *InMemoryBookingRepository) List(query ListBookingsQuery, limit, offset int) ([]*Booking, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	matches := func(booking *Booking) bool {
+		if query.UserID > 0 && booking.UserID != query.UserID &&
+			!(query.IncludeAttending && r.attends(booking.ID, query.UserID)) {
+			return false
+		}
+		if query.ResourceID > 0 && booking.ResourceID != query.ResourceID {
+			return false
+		}
 		if query.Status != "" && booking.Status != query.Status {
-			continue
+			return false
+		}
+		if !query.EndDate.IsZero() && booking.EndTime.After(query.EndDate.AddDate(0, 0, 1)) {
+			return false
+		}
+		if query.AfterID > 0 && (booking.StartTime.Before(query.AfterStart) ||
+			booking.StartTime.Equal(query.AfterStart) && booking.ID <= query.AfterID) {
+			return false
 		}
+		return true
+	}
 
-		if !query.StartDate.IsZero() && booking.StartTime.Before(query.StartDate) {
-			continue
+	from := query.StartDate
+	if query.AfterID > 0 && query.AfterStart.After(from) {
+		from = query.AfterStart
+	}
+
+	// Walk the narrowest start-ordered index available
+	var tree *intervalTree
+	switch {
+	case query.ResourceID > 0:
+		tree = r.byResource[query.ResourceID]
+	case query.UserID > 0 && !query.IncludeAttending:
+		tree = r.byUser[query.UserID]
+	default:
+		return r.listAll(from, matches, limit, offset), nil
+	}
+
+	result := []*Booking{}
+	if tree == nil {
+		return result, nil
+	}
+
+	skipped := 0
+	tree.AscendFrom(from, func(booking *Booking) bool {
+		// Bookings that start after the end date cannot end before it
+		if !query.EndDate.IsZero() && !booking.StartTime.Before(query.EndDate.AddDate(0, 0, 1)) {
+			return false
+		}
+		if !matches(booking) {
+			return true
+		}
+		if skipped < offset {
+			skipped++
+			return true
 		}
+		result = append(result, cloneBooking(booking))
+		return len(result) < limit
+	})
 
-		if !query.EndDate.IsZero() && booking.EndTime.After(query.EndDate.AddDate(0, 0, 1)) {
+	return result, nil
+}
+
+// listAll filters every booking and orders the matches by start time. It is only
+// used when the query names neither a resource nor a user.
+func (r *InMemoryBookingRepository) listAll(startDate time.Time, matches func(*Booking) bool, limit, offset int) []*Booking {
+	var filtered []*Booking
+	for _, booking := range r.bookings {
+		if booking.StartTime.Before(startDate) || !matches(booking) {
 			continue
 		}
-
 		filtered = append(filtered, booking)
 	}
 
-	// Apply pagination
-	start := offset
-	if start >= len(filtered) {
-		return []*Booking{}, nil
-	}
+	sort.Slice(filtered, func(i, j int) bool {
+		if filtered[i].StartTime.Equal(filtered[j].StartTime) {
+			return filtered[i].ID < filtered[j].ID
+		}
+		return filtered[i].StartTime.Before(filtered[j].StartTime)
+	})
 
-	end := start + limit
-	if end > len(filtered) {
-		end = len(filtered)
+	// Apply pagination
+	result := []*Booking{}
+	for i := offset; i < len(filtered) && len(result) < limit; i++ {
+		result = append(result, cloneBooking(filtered[i]))
 	}
 
-	return filtered[start:end], nil
+	return result
 }
 
 func (r *InMemoryBookingRepository) GetConflictingBookings(resourceID int, startTime, endTime time.Time) ([]*Booking, error) {
@@ -131,146 +419,753 @@ func (r *InMemoryBookingRepository) GetConflictingBookings(resourceID int, start
 	defer r.mutex.RUnlock()
 
 	var conflicts []*Booking
+	for _, booking := range r.findConflicts(resourceID, startTime, endTime, 0) {
+		conflicts = append(conflicts, cloneBooking(booking))
+	}
 
-	for _, booking := range r.bookings {
-		// Skip canceled bookings
-		if booking.Status == BookingStatusCanceled {
-			continue
-		}
+	return conflicts, nil
+}
 
-		// Check if booking is for the same resource
-		if booking.ResourceID != resourceID {
-			continue
-		}
+func (r *InMemoryBookingRepository) CreateIfAvailable(booking *Booking, occupancy Occupancy, events ...BookingEventType) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.fits(booking, occupancy) {
+		return ErrBookingConflict
+	}
+	booking.Shared = occupancy.Shared
+
+	return r.insert(booking, events)
+}
+
+func (r *InMemoryBookingRepository) UpdateIfAvailable(booking *Booking, occupancy Occupancy, events ...BookingEventType) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.bookings[booking.ID]
+	if !exists {
+		return fmt.Errorf("booking with ID %d %w", booking.ID, ErrNotFound)
+	}
+	if existing.Status != booking.Status {
+		return ErrBookingChanged
+	}
+
+	if !r.fits(booking, occupancy) {
+		return ErrBookingConflict
+	}
+	booking.Shared = occupancy.Shared
+
+	return r.replace(existing, booking, events)
+}
+
+// insert assigns the booking an ID and stores it with its events.
+// The caller must hold the mutex.
+func (r *InMemoryBookingRepository) insert(booking *Booking, eventTypes []BookingEventType) error {
+	booking.ID = r.nextID
+	events, err := newOutboxEvents(booking, eventTypes)
+	if err != nil {
+		return err
+	}
+	r.nextID++
+
+	r.index(cloneBooking(booking))
+	r.appendOutbox(events)
+	return nil
+}
+
+// replace stores a new version of an existing booking with its events.
+// The caller must hold the mutex.
+func (r *InMemoryBookingRepository) replace(existing, booking *Booking, eventTypes []BookingEventType) error {
+	events, err := newOutboxEvents(booking, eventTypes)
+	if err != nil {
+		return err
+	}
 
-		// Check for time overlap
-		if r.timeOverlaps(booking.StartTime, booking.EndTime, startTime, endTime) {
+	r.unindex(existing)
+	r.index(cloneBooking(booking))
+	r.appendOutbox(events)
+	return nil
+}
+
+// appendOutbox numbers and queues events. The caller must hold the mutex.
+func (r *InMemoryBookingRepository) appendOutbox(events []*OutboxEvent) {
+	for _, event := range events {
+		event.ID = r.nextEventID
+		r.nextEventID++
+		r.outbox = append(r.outbox, event)
+	}
+}
+
+func (r *InMemoryBookingRepository) DeliverOutboxEvents(limit int, deliver func(events []*OutboxEvent) int) (int, error) {
+	r.relayMutex.Lock()
+	defer r.relayMutex.Unlock()
+
+	r.mutex.RLock()
+	pending := make([]*OutboxEvent, min(limit, len(r.outbox)))
+	copy(pending, r.outbox)
+	r.mutex.RUnlock()
+
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	delivered := min(max(deliver(pending), 0), len(pending))
+
+	// Only this relay removes events and writers only append, so the delivered
+	// events are still the head of the queue
+	r.mutex.Lock()
+	r.outbox = r.outbox[delivered:]
+	r.mutex.Unlock()
+
+	return delivered, nil
+}
+
+// findConflicts returns the active bookings of a resource that overlap the given
+// period, ignoring excludeID. The caller must hold the mutex.
+func (r *InMemoryBookingRepository) findConflicts(resourceID int, startTime, endTime time.Time, excludeID int) []*Booking {
+	var conflicts []*Booking
+
+	tree := r.byResource[resourceID]
+	if tree == nil {
+		return conflicts
+	}
+
+	tree.Overlapping(startTime, endTime, func(booking *Booking) {
+		if booking.ID != excludeID {
 			conflicts = append(conflicts, booking)
 		}
-	}
+	})
 
-	return conflicts, nil
+	return conflicts
+}
+
+// fits reports whether the booking fits the occupancy next to the other active
+// bookings of its resource. The caller must hold the mutex.
+func (r *InMemoryBookingRepository) fits(booking *Booking, occupancy Occupancy) bool {
+	gap := occupancy.Gap()
+	existing := r.findConflicts(booking.ResourceID, booking.StartTime.Add(-gap), booking.EndTime.Add(gap), booking.ID)
+	return occupancy.Fits(booking, existing)
 }
 
 func (r *InMemoryBookingRepository) GetByUserID(userID, limit, offset int) ([]*Booking, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	var userBookings []*Booking
+	return pageOf(r.byUser[userID], limit, offset), nil
+}
+
+func (r *InMemoryBookingRepository) GetUserBookingsBetween(userID int, startTime, endTime time.Time) ([]*Booking, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	bookings := []*Booking{}
+	if tree := r.byUser[userID]; tree != nil {
+		tree.Overlapping(startTime, endTime, func(booking *Booking) {
+			bookings = append(bookings, cloneBooking(booking))
+		})
+	}
+
+	return bookings, nil
+}
+
+func (r *InMemoryBookingRepository) CountActiveBookings(userID int, now time.Time) (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tree := r.byUser[userID]
+	if tree == nil {
+		return 0, nil
+	}
+
 	count := 0
-	skipped := 0
+	tree.AscendFrom(time.Time{}, func(booking *Booking) bool {
+		switch booking.Status {
+		case BookingStatusPending, BookingStatusConfirmed, BookingStatusCheckedIn:
+			if booking.EndTime.After(now) {
+				count++
+			}
+		}
+		return true
+	})
 
-	for _, booking := range r.bookings {
-		if booking.UserID != userID {
-			continue
+	return count, nil
+}
+
+func (r *InMemoryBookingRepository) GetByResourceID(resourceID, limit, offset int) ([]*Booking, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return pageOf(r.byResource[resourceID], limit, offset), nil
+}
+
+func (r *InMemoryBookingRepository) GetBySeriesID(seriesID int) ([]*Booking, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tree := r.bySeries[seriesID]
+	if tree == nil {
+		return []*Booking{}, nil
+	}
+
+	var bookings []*Booking
+	tree.AscendFrom(time.Time{}, func(booking *Booking) bool {
+		bookings = append(bookings, cloneBooking(booking))
+		return true
+	})
+
+	return bookings, nil
+}
+
+func (r *InMemoryBookingRepository) CreateSeries(series *BookingSeries) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	series.ID = r.nextSeriesID
+	r.nextSeriesID++
+
+	stored := *series
+	r.series[series.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryBookingRepository) GetSeries(id int) (*BookingSeries, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	series, exists := r.series[id]
+	if !exists {
+		return nil, fmt.Errorf("booking series with ID %d %w", id, ErrNotFound)
+	}
+
+	result := *series
+	return &result, nil
+}
+
+func (r *InMemoryBookingRepository) UpdateSeries(series *BookingSeries) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.series[series.ID]; !exists {
+		return fmt.Errorf("booking series with ID %d %w", series.ID, ErrNotFound)
+	}
+
+	stored := *series
+	r.series[series.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryBookingRepository) GetByGroupID(groupID int) ([]*Booking, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	bookings := []*Booking{}
+	if tree := r.byGroup[groupID]; tree != nil {
+		tree.AscendFrom(time.Time{}, func(booking *Booking) bool {
+			bookings = append(bookings, cloneBooking(booking))
+			return true
+		})
+	}
+
+	sort.Slice(bookings, func(i, j int) bool {
+		return bookings[i].ID < bookings[j].ID
+	})
+
+	return bookings, nil
+}
+
+func (r *InMemoryBookingRepository) CreateGroupIfAvailable(group *BookingGroup, bookings []*Booking, occupancies []Occupancy, events ...BookingEventType) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, booking := range bookings {
+		if !r.fits(booking, occupancies[i]) {
+			return &ResourceConflictError{ResourceID: booking.ResourceID}
 		}
+		booking.Shared = occupancies[i].Shared
+	}
 
-		if skipped < offset {
-			skipped++
-			continue
+	group.ID = r.nextGroupID
+	r.nextGroupID++
+	stored := *group
+	r.groups[group.ID] = &stored
+
+	for _, booking := range bookings {
+		groupID := group.ID
+		booking.GroupID = &groupID
+		if err := r.insert(booking, events); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *InMemoryBookingRepository) UpdateGroupIfAvailable(group *BookingGroup, bookings []*Booking, occupancies []Occupancy, events ...BookingEventType) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.groups[group.ID]; !exists {
+		return fmt.Errorf("booking group with ID %d %w", group.ID, ErrNotFound)
+	}
+
+	existing := make([]*Booking, len(bookings))
+	for i, booking := range bookings {
+		stored, exists := r.bookings[booking.ID]
+		if !exists {
+			return fmt.Errorf("booking with ID %d %w", booking.ID, ErrNotFound)
+		}
+		if stored.Status != booking.Status {
+			return ErrBookingChanged
 		}
+		existing[i] = stored
 
-		if count >= limit {
-			break
+		if !r.fits(booking, occupancies[i]) {
+			return &ResourceConflictError{ResourceID: booking.ResourceID}
 		}
+		booking.Shared = occupancies[i].Shared
+	}
 
-		userBookings = append(userBookings, booking)
-		count++
+	for i, booking := range bookings {
+		if err := r.replace(existing[i], booking, events); err != nil {
+			return err
+		}
 	}
+	stored := *group
+	r.groups[group.ID] = &stored
 
-	return userBookings, nil
+	return nil
 }
 
-func (r *InMemoryBookingRepository) GetByResourceID(resourceID, limit, offset int) ([]*Booking, error) {
+func (r *InMemoryBookingRepository) UpdateGroupIfStatus(group *BookingGroup, bookings []*Booking, fromStatuses []BookingStatus, events ...BookingEventType) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.groups[group.ID]; !exists {
+		return fmt.Errorf("booking group with ID %d %w", group.ID, ErrNotFound)
+	}
+
+	existing := make([]*Booking, len(bookings))
+	for i, booking := range bookings {
+		stored, exists := r.bookings[booking.ID]
+		if !exists {
+			return fmt.Errorf("booking with ID %d %w", booking.ID, ErrNotFound)
+		}
+		if stored.Status != fromStatuses[i] {
+			return ErrBookingChanged
+		}
+		existing[i] = stored
+	}
+
+	for i, booking := range bookings {
+		if err := r.replace(existing[i], booking, events); err != nil {
+			return err
+		}
+	}
+	stored := *group
+	r.groups[group.ID] = &stored
+
+	return nil
+}
+
+func (r *InMemoryBookingRepository) GetGroup(id int) (*BookingGroup, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	var resourceBookings []*Booking
-	count := 0
-	skipped := 0
+	group, exists := r.groups[id]
+	if !exists {
+		return nil, fmt.Errorf("booking group with ID %d %w", id, ErrNotFound)
+	}
 
-	for _, booking := range r.bookings {
-		if booking.ResourceID != resourceID {
-			continue
+	result := *group
+	return &result, nil
+}
+
+func (r *InMemoryBookingRepository) CreateWaitlistEntry(entry *WaitlistEntry) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	entry.ID = r.nextEntryID
+	r.nextEntryID++
+
+	r.waitlist[entry.ID] = cloneWaitlistEntry(entry)
+	return nil
+}
+
+func (r *InMemoryBookingRepository) GetWaitlistEntry(id int) (*WaitlistEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	entry, exists := r.waitlist[id]
+	if !exists {
+		return nil, fmt.Errorf("waitlist entry with ID %d %w", id, ErrNotFound)
+	}
+
+	return cloneWaitlistEntry(entry), nil
+}
+
+func (r *InMemoryBookingRepository) UpdateWaitlistEntry(entry *WaitlistEntry, fromStatus WaitlistStatus) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.waitlist[entry.ID]
+	if !exists {
+		return fmt.Errorf("waitlist entry with ID %d %w", entry.ID, ErrNotFound)
+	}
+	if existing.Status != fromStatus {
+		return ErrWaitlistEntryChanged
+	}
+
+	r.waitlist[entry.ID] = cloneWaitlistEntry(entry)
+	return nil
+}
+
+func (r *InMemoryBookingRepository) ListWaitlistEntries(query ListWaitlistQuery, limit, offset int) ([]*WaitlistEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	entries := r.filterWaitlist(func(entry *WaitlistEntry) bool {
+		if query.UserID > 0 && entry.UserID != query.UserID {
+			return false
 		}
+		if query.ResourceID > 0 && entry.ResourceID != query.ResourceID {
+			return false
+		}
+		if query.BookingID > 0 && (entry.BookingID == nil || *entry.BookingID != query.BookingID) {
+			return false
+		}
+		return query.Status == "" || entry.Status == query.Status
+	})
 
-		if skipped < offset {
-			skipped++
-			continue
+	// Apply pagination
+	result := []*WaitlistEntry{}
+	for i := offset; i < len(entries) && len(result) < limit; i++ {
+		result = append(result, entries[i])
+	}
+
+	return result, nil
+}
+
+func (r *InMemoryBookingRepository) GetWaitingEntries(resourceID int, startTime, endTime time.Time) ([]*WaitlistEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.filterWaitlist(func(entry *WaitlistEntry) bool {
+		return entry.ResourceID == resourceID &&
+			entry.Status == WaitlistStatusWaiting &&
+			entry.StartTime.Before(endTime) &&
+			startTime.Before(entry.EndTime)
+	}), nil
+}
+
+func (r *InMemoryBookingRepository) GetExpiredOffers(now time.Time) ([]*WaitlistEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.filterWaitlist(func(entry *WaitlistEntry) bool {
+		return entry.Status == WaitlistStatusOffered &&
+			entry.OfferExpiresAt != nil &&
+			entry.OfferExpiresAt.Before(now)
+	}), nil
+}
+
+// attends reports whether the user is invited to the booking. The caller must hold the mutex.
+func (r *InMemoryBookingRepository) attends(bookingID, userID int) bool {
+	for _, attendee := range r.attendees[bookingID] {
+		if attendee.IsUser(userID) {
+			return true
 		}
+	}
+	return false
+}
 
-		if count >= limit {
-			break
+func (r *InMemoryBookingRepository) AddAttendee(booking *Booking, attendee *Attendee, events ...BookingEventType) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.attendees[booking.ID] {
+		if (attendee.UserID != nil && existing.IsUser(*attendee.UserID)) ||
+			(attendee.Email != "" && existing.Email == attendee.Email) {
+			return ErrAttendeeExists
 		}
+	}
 
-		resourceBookings = append(resourceBookings, booking)
-		count++
+	attendee.ID = r.nextAttendee
+	outboxEvents, err := newAttendeeEvents(booking, attendee, events)
+	if err != nil {
+		return err
 	}
+	r.nextAttendee++
 
-	return resourceBookings, nil
+	r.attendees[booking.ID] = append(r.attendees[booking.ID], cloneAttendee(attendee))
+	r.appendOutbox(outboxEvents)
+	return nil
 }
 
-// timeOverlaps checks if two time periods overlap
-func (r *InMemoryBookingRepository) timeOverlaps(start1, end1, start2, end2 time.Time) bool {
-	return start1.Before(end2) && start2.Before(end1)
+func (r *InMemoryBookingRepository) GetAttendees(bookingID int) ([]*Attendee, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	attendees := []*Attendee{}
+	for _, attendee := range r.attendees[bookingID] {
+		attendees = append(attendees, cloneAttendee(attendee))
+	}
+
+	return attendees, nil
 }
 
-// TODO: PostgreSQL implementation
-/*
-type PostgreSQLBookingRepository struct {
-	db *sql.DB
+func (r *InMemoryBookingRepository) UpdateAttendee(booking *Booking, attendee *Attendee, events ...BookingEventType) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, existing := range r.attendees[booking.ID] {
+		if existing.ID == attendee.ID {
+			outboxEvents, err := newAttendeeEvents(booking, attendee, events)
+			if err != nil {
+				return err
+			}
+			r.attendees[booking.ID][i] = cloneAttendee(attendee)
+			r.appendOutbox(outboxEvents)
+			return nil
+		}
+	}
+
+	return ErrAttendeeNotFound
 }
 
-func NewPostgreSQLBookingRepository(db *sql.DB) BookingRepository {
-	return &PostgreSQLBookingRepository{db: db}
+func (r *InMemoryBookingRepository) RemoveAttendee(booking *Booking, attendee *Attendee, events ...BookingEventType) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	attendees := r.attendees[booking.ID]
+	for i, existing := range attendees {
+		if existing.ID == attendee.ID {
+			outboxEvents, err := newAttendeeEvents(booking, attendee, events)
+			if err != nil {
+				return err
+			}
+			r.attendees[booking.ID] = append(attendees[:i:i], attendees[i+1:]...)
+			r.appendOutbox(outboxEvents)
+			return nil
+		}
+	}
+
+	return ErrAttendeeNotFound
 }
 
-func (r *PostgreSQLBookingRepository) Create(booking *Booking) error {
-	query := `
-		INSERT INTO bookings (user_id, resource_id, start_time, end_time, status, notes, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id`
+func (r *InMemoryBookingRepository) CreateCalendarFeed(feed *CalendarFeed) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.revokeFeeds(feed.OwnerType, feed.OwnerID, feed.CreatedAt)
 
-	err := r.db.QueryRow(
-		query,
-		booking.UserID, booking.ResourceID, booking.StartTime, booking.EndTime,
-		booking.Status, booking.Notes, booking.CreatedAt, booking.UpdatedAt,
-	).Scan(&booking.ID)
+	feed.ID = r.nextFeedID
+	r.nextFeedID++
 
-	return err
+	stored := *feed
+	r.feeds[feed.ID] = &stored
+	return nil
 }
 
-func (r *PostgreSQLBookingRepository) GetConflictingBookings(resourceID int, startTime, endTime time.Time) ([]*Booking, error) {
-	query := `
-		SELECT id, user_id, resource_id, start_time, end_time, status, notes, created_at, updated_at
-		FROM bookings
-		WHERE resource_id = $1
-		AND status != 'CANCELLED'
-		AND start_time < $3
-		AND end_time > $2`
+func (r *InMemoryBookingRepository) GetCalendarFeed(tokenHash string) (*CalendarFeed, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
 
-	rows, err := r.db.Query(query, resourceID, startTime, endTime)
-	if err != nil {
-		return nil, err
+	for _, feed := range r.feeds {
+		if feed.TokenHash == tokenHash && feed.RevokedAt == nil {
+			clone := *feed
+			return &clone, nil
+		}
 	}
-	defer rows.Close()
 
-	var bookings []*Booking
-	for rows.Next() {
-		booking := &Booking{}
-		err := rows.Scan(
-			&booking.ID, &booking.UserID, &booking.ResourceID,
-			&booking.StartTime, &booking.EndTime, &booking.Status,
-			&booking.Notes, &booking.CreatedAt, &booking.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
+	return nil, ErrCalendarFeedNotFound
+}
+
+func (r *InMemoryBookingRepository) RevokeCalendarFeeds(ownerType CalendarFeedOwner, ownerID int, now time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.revokeFeeds(ownerType, ownerID, now)
+	return nil
+}
+
+// revokeFeeds revokes the owner's active feeds. The caller must hold the mutex.
+func (r *InMemoryBookingRepository) revokeFeeds(ownerType CalendarFeedOwner, ownerID int, now time.Time) {
+	for _, feed := range r.feeds {
+		if feed.OwnerType == ownerType && feed.OwnerID == ownerID && feed.RevokedAt == nil {
+			revokedAt := now
+			feed.RevokedAt = &revokedAt
 		}
-		bookings = append(bookings, booking)
 	}
+}
 
-	return bookings, nil
+func (r *InMemoryBookingRepository) CreateQuotaGrant(grant *QuotaGrant) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	grant.ID = r.nextGrantID
+	r.nextGrantID++
+
+	stored := *grant
+	r.quotaGrants[grant.ID] = &stored
+	return nil
+}
+
+func (r *InMemoryBookingRepository) GetQuotaGrants(userID int, now time.Time) ([]*QuotaGrant, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	grants := []*QuotaGrant{}
+	for _, grant := range r.quotaGrants {
+		if grant.UserID == userID && grant.ExpiresAt.After(now) {
+			clone := *grant
+			grants = append(grants, &clone)
+		}
+	}
+
+	sort.Slice(grants, func(i, j int) bool {
+		return grants[i].ID < grants[j].ID
+	})
+
+	return grants, nil
+}
+
+// filterWaitlist returns copies of the matching entries in queue order (oldest first).
+// The caller must hold the mutex.
+func (r *InMemoryBookingRepository) filterWaitlist(matches func(*WaitlistEntry) bool) []*WaitlistEntry {
+	entries := []*WaitlistEntry{}
+	for _, entry := range r.waitlist {
+		if matches(entry) {
+			entries = append(entries, cloneWaitlistEntry(entry))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].ID < entries[j].ID
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+
+	return entries
+}
+
+// index stores a booking and adds it to the resource and user indexes.
+// The caller must hold the mutex and must not mutate the booking afterwards.
+func (r *InMemoryBookingRepository) index(booking *Booking) {
+	r.bookings[booking.ID] = booking
+
+	if r.byResource[booking.ResourceID] == nil {
+		r.byResource[booking.ResourceID] = newIntervalTree()
+	}
+	r.byResource[booking.ResourceID].Insert(booking)
+
+	if r.byUser[booking.UserID] == nil {
+		r.byUser[booking.UserID] = newIntervalTree()
+	}
+	r.byUser[booking.UserID].Insert(booking)
+
+	if booking.SeriesID != nil {
+		if r.bySeries[*booking.SeriesID] == nil {
+			r.bySeries[*booking.SeriesID] = newIntervalTree()
+		}
+		r.bySeries[*booking.SeriesID].Insert(booking)
+	}
+
+	if booking.GroupID != nil {
+		if r.byGroup[*booking.GroupID] == nil {
+			r.byGroup[*booking.GroupID] = newIntervalTree()
+		}
+		r.byGroup[*booking.GroupID].Insert(booking)
+	}
+}
+
+// unindex removes a stored booking from the map and every index.
+// The caller must hold the mutex.
+func (r *InMemoryBookingRepository) unindex(booking *Booking) {
+	delete(r.bookings, booking.ID)
+
+	if tree := r.byResource[booking.ResourceID]; tree != nil {
+		tree.Remove(booking.StartTime, booking.ID)
+	}
+	if tree := r.byUser[booking.UserID]; tree != nil {
+		tree.Remove(booking.StartTime, booking.ID)
+	}
+	if booking.SeriesID != nil {
+		if tree := r.bySeries[*booking.SeriesID]; tree != nil {
+			tree.Remove(booking.StartTime, booking.ID)
+		}
+	}
+	if booking.GroupID != nil {
+		if tree := r.byGroup[*booking.GroupID]; tree != nil {
+			tree.Remove(booking.StartTime, booking.ID)
+		}
+	}
 }
 
-// ... implement other methods
-*/
+// pageOf returns copies of one page of an index in start order
+func pageOf(tree *intervalTree, limit, offset int) []*Booking {
+	var bookings []*Booking
+	if tree == nil {
+		return bookings
+	}
+
+	tree.Page(offset, limit, func(booking *Booking) {
+		bookings = append(bookings, cloneBooking(booking))
+	})
+
+	return bookings
+}
+
+// cloneAttendee copies an attendee so callers never share memory with the store
+func cloneAttendee(attendee *Attendee) *Attendee {
+	clone := *attendee
+	if attendee.UserID != nil {
+		userID := *attendee.UserID
+		clone.UserID = &userID
+	}
+	if attendee.RespondedAt != nil {
+		respondedAt := *attendee.RespondedAt
+		clone.RespondedAt = &respondedAt
+	}
+	return &clone
+}
+
+// cloneBooking copies a booking so callers never share memory with the store
+func cloneBooking(booking *Booking) *Booking {
+	clone := *booking
+	if booking.CanceledAt != nil {
+		canceledAt := *booking.CanceledAt
+		clone.CanceledAt = &canceledAt
+	}
+	if booking.SeriesID != nil {
+		seriesID := *booking.SeriesID
+		clone.SeriesID = &seriesID
+	}
+	if booking.GroupID != nil {
+		groupID := *booking.GroupID
+		clone.GroupID = &groupID
+	}
+	if booking.ExpiresAt != nil {
+		expiresAt := *booking.ExpiresAt
+		clone.ExpiresAt = &expiresAt
+	}
+	if booking.CheckedInAt != nil {
+		checkedInAt := *booking.CheckedInAt
+		clone.CheckedInAt = &checkedInAt
+	}
+	return &clone
+}
+
+// cloneWaitlistEntry copies a waitlist entry so callers never share memory with the store
+func cloneWaitlistEntry(entry *WaitlistEntry) *WaitlistEntry {
+	clone := *entry
+	if entry.BookingID != nil {
+		bookingID := *entry.BookingID
+		clone.BookingID = &bookingID
+	}
+	if entry.OfferExpiresAt != nil {
+		expiresAt := *entry.OfferExpiresAt
+		clone.OfferExpiresAt = &expiresAt
+	}
+	return &clone
+}
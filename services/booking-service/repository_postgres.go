@@ -0,0 +1,1191 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// pgExclusionViolation is the PostgreSQL error code raised by the bookings_no_overlap constraint
+const pgExclusionViolation = "23P01"
+
+// pgUniqueViolation is the PostgreSQL error code raised by unique constraints
+const pgUniqueViolation = "23505"
+
+// outboxLockClass and outboxLockID form the advisory lock key held by the relay
+// delivering the outbox. The two-key form does not collide with the resource locks.
+const (
+	outboxLockClass = 1 << 20
+	outboxLockID    = 1
+)
+
+const insertOutboxSQL = `
+	INSERT INTO booking_outbox (event_id, booking_id, event_type, payload, created_at)
+	VALUES ($1, $2, $3, $4, $5)`
+
+// bookingColumns is the column list shared by every booking SELECT
+const bookingColumns = `id, user_id, resource_id, start_time, end_time, status,
+	COALESCE(notes, ''), created_at, updated_at, canceled_at, series_id,
+	expires_at, COALESCE(cancellation_reason, ''), checked_in_at, group_id, seats, shared`
+
+const insertBookingSQL = `
+	INSERT INTO bookings (user_id, resource_id, start_time, end_time, status, notes, created_at, updated_at,
+		canceled_at, series_id, expires_at, cancellation_reason, checked_in_at, group_id, seats, shared)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14, $15, $16)
+	RETURNING id`
+
+const updateBookingSQL = `
+	UPDATE bookings
+	SET user_id = $2, resource_id = $3, start_time = $4, end_time = $5,
+		status = $6, notes = $7, updated_at = $8, canceled_at = $9, series_id = $10,
+		expires_at = $11, cancellation_reason = NULLIF($12, ''), checked_in_at = $13, group_id = $14,
+		seats = $15, shared = $16
+	WHERE id = $1`
+
+// updateIfStatusSQL writes a booking only while its stored status is the extra parameter $17
+const updateIfStatusSQL = updateBookingSQL + ` AND status = $17`
+
+// PostgreSQLBookingRepository stores bookings in the PostgreSQL bookings table.
+// Overlapping active bookings are rejected by the bookings_no_overlap exclusion
+// constraint, so concurrent replicas cannot both accept the same slot.
+type PostgreSQLBookingRepository struct {
+	db *sql.DB
+}
+
+func NewPostgreSQLBookingRepository(db *sql.DB) *PostgreSQLBookingRepository {
+	return &PostgreSQLBookingRepository{db: db}
+}
+
+func (r *PostgreSQLBookingRepository) Create(booking *Booking, events ...BookingEventType) error {
+	return r.withEvents(booking, events, func(tx *sql.Tx) error {
+		return tx.QueryRow(insertBookingSQL, insertBookingArgs(booking)...).Scan(&booking.ID)
+	})
+}
+
+func (r *PostgreSQLBookingRepository) GetByID(id int) (*Booking, error) {
+	query := `SELECT ` + bookingColumns + ` FROM bookings WHERE id = $1`
+
+	booking, err := scanBooking(r.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("booking with ID %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return booking, nil
+}
+
+func (r *PostgreSQLBookingRepository) Update(booking *Booking, events ...BookingEventType) error {
+	return r.withEvents(booking, events, func(tx *sql.Tx) error {
+		return updateUnchanged(tx, booking)
+	})
+}
+
+func (r *PostgreSQLBookingRepository) UpdateIfStatus(booking *Booking, fromStatus BookingStatus, events ...BookingEventType) error {
+	err := r.withEvents(booking, events, func(tx *sql.Tx) error {
+		result, err := tx.Exec(updateIfStatusSQL, append(updateBookingArgs(booking), fromStatus)...)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrBookingChanged
+		}
+		return nil
+	})
+	if errors.Is(err, ErrBookingChanged) {
+		if _, err := r.GetByID(booking.ID); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (r *PostgreSQLBookingRepository) GetExpiredHolds(now time.Time) ([]*Booking, error) {
+	query := `SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE status = $1
+		AND expires_at <= $2
+		ORDER BY id`
+
+	return r.queryBookings(query, BookingStatusPending, now)
+}
+
+func (r *PostgreSQLBookingRepository) GetFinishedBookings(now time.Time, limit int) ([]*Booking, error) {
+	query := `SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE status IN ($1, $2)
+		AND end_time <= $3
+		ORDER BY id
+		LIMIT $4`
+
+	return r.queryBookings(query, BookingStatusConfirmed, BookingStatusCheckedIn, now, limit)
+}
+
+func (r *PostgreSQLBookingRepository) GetNoShows(startedBefore, now time.Time) ([]*Booking, error) {
+	query := `SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE status = $1
+		AND start_time <= $2
+		AND end_time > $3
+		ORDER BY id`
+
+	return r.queryBookings(query, BookingStatusConfirmed, startedBefore, now)
+}
+
+func (r *PostgreSQLBookingRepository) Delete(id int) error {
+	result, err := r.db.Exec(`DELETE FROM bookings WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result, id)
+}
+
+func (r *PostgreSQLBookingRepository) List(query ListBookingsQuery, limit, offset int) ([]*Booking, error) {
+	var conditions []string
+	var args []interface{}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if query.UserID > 0 && query.IncludeAttending {
+		addCondition("(user_id = $%[1]d OR id IN (SELECT booking_id FROM booking_attendees WHERE user_id = $%[1]d))", query.UserID)
+	} else if query.UserID > 0 {
+		addCondition("user_id = $%d", query.UserID)
+	}
+	if query.ResourceID > 0 {
+		addCondition("resource_id = $%d", query.ResourceID)
+	}
+	if query.Status != "" {
+		addCondition("status = $%d", query.Status)
+	}
+	if !query.StartDate.IsZero() {
+		addCondition("start_time >= $%d", query.StartDate)
+	}
+	if !query.EndDate.IsZero() {
+		addCondition("end_time <= $%d", query.EndDate.AddDate(0, 0, 1))
+	}
+	if query.AfterID > 0 {
+		args = append(args, query.AfterStart, query.AfterID)
+		conditions = append(conditions, fmt.Sprintf("(start_time, id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	sqlQuery := `SELECT ` + bookingColumns + ` FROM bookings`
+	if len(conditions) > 0 {
+		sqlQuery += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit, offset)
+	sqlQuery += fmt.Sprintf(` ORDER BY start_time, id LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	return r.queryBookings(sqlQuery, args...)
+}
+
+func (r *PostgreSQLBookingRepository) GetConflictingBookings(resourceID int, startTime, endTime time.Time) ([]*Booking, error) {
+	query := `SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE resource_id = $1
+		AND status <> $4
+		AND start_time < $3
+		AND end_time > $2
+		ORDER BY start_time, id`
+
+	return r.queryBookings(query, resourceID, startTime, endTime, BookingStatusCanceled)
+}
+
+func (r *PostgreSQLBookingRepository) CreateIfAvailable(booking *Booking, occupancy Occupancy, events ...BookingEventType) error {
+	return r.withResourceLock(booking, occupancy, events, func(tx *sql.Tx) error {
+		return tx.QueryRow(insertBookingSQL, insertBookingArgs(booking)...).Scan(&booking.ID)
+	})
+}
+
+func (r *PostgreSQLBookingRepository) UpdateIfAvailable(booking *Booking, occupancy Occupancy, events ...BookingEventType) error {
+	return r.withResourceLock(booking, occupancy, events, func(tx *sql.Tx) error {
+		return updateUnchanged(tx, booking)
+	})
+}
+
+// updateUnchanged writes the booking if its stored status is still the one the
+// booking carries. It returns ErrBookingChanged otherwise.
+func updateUnchanged(tx *sql.Tx, booking *Booking) error {
+	return updateIfStatus(tx, booking, booking.Status)
+}
+
+// updateIfStatus writes the booking if its stored status is still fromStatus.
+// It returns ErrBookingChanged otherwise.
+func updateIfStatus(tx *sql.Tx, booking *Booking, fromStatus BookingStatus) error {
+	result, err := tx.Exec(updateIfStatusSQL, append(updateBookingArgs(booking), fromStatus)...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM bookings WHERE id = $1)`, booking.ID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("booking with ID %d %w", booking.ID, ErrNotFound)
+	}
+	return ErrBookingChanged
+}
+
+// withEvents runs write and stores the booking's events in the outbox in one
+// transaction. The events are inserted after the booking row is written and
+// locked, so events of the same booking get increasing outbox IDs.
+func (r *PostgreSQLBookingRepository) withEvents(booking *Booking, events []BookingEventType, write func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := write(tx); err != nil {
+		return translatePostgresError(err)
+	}
+	if err := insertOutboxEvents(tx, booking, events); err != nil {
+		return err
+	}
+
+	return translatePostgresError(tx.Commit())
+}
+
+// withResourceLock runs write inside a transaction that holds an advisory lock on
+// the booking's resource and has verified that the booking fits the occupancy. The
+// bookings_no_overlap constraint remains the final guard against direct overlaps
+// on exclusive resources.
+func (r *PostgreSQLBookingRepository) withResourceLock(booking *Booking, occupancy Occupancy, events []BookingEventType, write func(tx *sql.Tx) error) error {
+	return r.withEvents(booking, events, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, booking.ResourceID); err != nil {
+			return err
+		}
+
+		ok, err := fits(tx, booking, occupancy)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrBookingConflict
+		}
+
+		booking.Shared = occupancy.Shared
+		return write(tx)
+	})
+}
+
+// fits reports whether the booking fits the occupancy next to the other active
+// bookings of its resource within the buffer gap of it
+func fits(tx *sql.Tx, booking *Booking, occupancy Occupancy) (bool, error) {
+	gap := occupancy.Gap()
+	rows, err := tx.Query(`
+		SELECT start_time, end_time, seats
+		FROM bookings
+		WHERE resource_id = $1
+		AND status <> $4
+		AND start_time < $3
+		AND end_time > $2
+		AND id <> $5`,
+		booking.ResourceID, booking.StartTime.Add(-gap), booking.EndTime.Add(gap), BookingStatusCanceled, booking.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var existing []*Booking
+	for rows.Next() {
+		other := &Booking{}
+		if err := rows.Scan(&other.StartTime, &other.EndTime, &other.Seats); err != nil {
+			return false, err
+		}
+		existing = append(existing, other)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	return occupancy.Fits(booking, existing), nil
+}
+
+// withGroupLock runs write in a transaction that holds the advisory locks of every
+// member resource, taken in resource order so concurrent groups cannot deadlock,
+// and has verified that every member fits its occupancy. write is called for each
+// booking, followed by its events.
+func (r *PostgreSQLBookingRepository) withGroupLock(bookings []*Booking, occupancies []Occupancy, events []BookingEventType, writeGroup func(tx *sql.Tx) error, write func(tx *sql.Tx, booking *Booking) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	resourceIDs := make([]int, len(bookings))
+	for i, booking := range bookings {
+		resourceIDs[i] = booking.ResourceID
+	}
+	sort.Ints(resourceIDs)
+	for _, resourceID := range resourceIDs {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, resourceID); err != nil {
+			return err
+		}
+	}
+
+	for i, booking := range bookings {
+		ok, err := fits(tx, booking, occupancies[i])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &ResourceConflictError{ResourceID: booking.ResourceID}
+		}
+		booking.Shared = occupancies[i].Shared
+	}
+
+	if err := writeGroup(tx); err != nil {
+		return err
+	}
+	for _, booking := range bookings {
+		if err := write(tx, booking); err != nil {
+			if err := translatePostgresError(err); errors.Is(err, ErrBookingConflict) {
+				return &ResourceConflictError{ResourceID: booking.ResourceID}
+			}
+			return err
+		}
+		if err := insertOutboxEvents(tx, booking, events); err != nil {
+			return err
+		}
+	}
+
+	return translatePostgresError(tx.Commit())
+}
+
+// insertOutboxEvents stores the events of a booking change in the outbox
+func insertOutboxEvents(tx *sql.Tx, booking *Booking, eventTypes []BookingEventType) error {
+	events, err := newOutboxEvents(booking, eventTypes)
+	if err != nil {
+		return err
+	}
+
+	return storeOutboxEvents(tx, events)
+}
+
+// storeOutboxEvents inserts built outbox records
+func storeOutboxEvents(tx *sql.Tx, events []*OutboxEvent) error {
+	for _, event := range events {
+		if _, err := tx.Exec(insertOutboxSQL,
+			event.EventID, event.BookingID, event.Type, event.Payload, event.CreatedAt); err != nil {
+			return fmt.Errorf("failed to store %s event: %w", event.Type, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *PostgreSQLBookingRepository) DeliverOutboxEvents(limit int, deliver func(events []*OutboxEvent) int) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// Another replica is relaying; it will deliver these events in order
+	var locked bool
+	if err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1, $2)`, outboxLockClass, outboxLockID).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, event_id, booking_id, event_type, payload, created_at
+		FROM booking_outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []*OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		if err := rows.Scan(&event.ID, &event.EventID, &event.BookingID, &event.Type,
+			&event.Payload, &event.CreatedAt); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	// A crash after publishing but before the commit delivers these events again
+	delivered := min(max(deliver(events), 0), len(events))
+	if delivered == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, delivered)
+	for i, event := range events[:delivered] {
+		ids[i] = event.ID
+	}
+	if _, err := tx.Exec(`UPDATE booking_outbox SET published_at = $2 WHERE id = ANY($1)`,
+		pq.Array(ids), time.Now()); err != nil {
+		return 0, err
+	}
+
+	return delivered, tx.Commit()
+}
+
+func (r *PostgreSQLBookingRepository) GetBySeriesID(seriesID int) ([]*Booking, error) {
+	query := `SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE series_id = $1
+		ORDER BY start_time, id`
+
+	return r.queryBookings(query, seriesID)
+}
+
+func (r *PostgreSQLBookingRepository) GetByGroupID(groupID int) ([]*Booking, error) {
+	query := `SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE group_id = $1
+		ORDER BY id`
+
+	return r.queryBookings(query, groupID)
+}
+
+func (r *PostgreSQLBookingRepository) CreateGroupIfAvailable(group *BookingGroup, bookings []*Booking, occupancies []Occupancy, events ...BookingEventType) error {
+	insertGroup := func(tx *sql.Tx) error {
+		return tx.QueryRow(`
+			INSERT INTO booking_groups (user_id, start_time, end_time, notes, created_at, updated_at, canceled_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id`,
+			group.UserID, group.StartTime, group.EndTime, group.Notes, group.CreatedAt, group.UpdatedAt, group.CanceledAt,
+		).Scan(&group.ID)
+	}
+
+	return r.withGroupLock(bookings, occupancies, events, insertGroup, func(tx *sql.Tx, booking *Booking) error {
+		groupID := group.ID
+		booking.GroupID = &groupID
+		return tx.QueryRow(insertBookingSQL, insertBookingArgs(booking)...).Scan(&booking.ID)
+	})
+}
+
+func (r *PostgreSQLBookingRepository) UpdateGroupIfAvailable(group *BookingGroup, bookings []*Booking, occupancies []Occupancy, events ...BookingEventType) error {
+	updateGroup := func(tx *sql.Tx) error {
+		result, err := tx.Exec(updateGroupSQL, updateGroupArgs(group)...)
+		if err != nil {
+			return err
+		}
+		return expectAffected(result, group.ID)
+	}
+
+	return r.withGroupLock(bookings, occupancies, events, updateGroup, updateUnchanged)
+}
+
+func (r *PostgreSQLBookingRepository) UpdateGroupIfStatus(group *BookingGroup, bookings []*Booking, fromStatuses []BookingStatus, events ...BookingEventType) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := tx.Exec(updateGroupSQL, updateGroupArgs(group)...)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(result, group.ID); err != nil {
+		return err
+	}
+
+	for i, booking := range bookings {
+		if err := updateIfStatus(tx, booking, fromStatuses[i]); err != nil {
+			return translatePostgresError(err)
+		}
+		if err := insertOutboxEvents(tx, booking, events); err != nil {
+			return err
+		}
+	}
+
+	return translatePostgresError(tx.Commit())
+}
+
+const updateGroupSQL = `
+	UPDATE booking_groups
+	SET start_time = $2, end_time = $3, notes = $4, updated_at = $5, canceled_at = $6
+	WHERE id = $1`
+
+// updateGroupArgs returns the parameters of updateGroupSQL
+func updateGroupArgs(group *BookingGroup) []interface{} {
+	return []interface{}{
+		group.ID, group.StartTime, group.EndTime, group.Notes, group.UpdatedAt, group.CanceledAt,
+	}
+}
+
+func (r *PostgreSQLBookingRepository) GetGroup(id int) (*BookingGroup, error) {
+	query := `
+		SELECT id, user_id, start_time, end_time, COALESCE(notes, ''), created_at, updated_at, canceled_at
+		FROM booking_groups
+		WHERE id = $1`
+
+	group := &BookingGroup{}
+	var canceledAt sql.NullTime
+	err := r.db.QueryRow(query, id).Scan(
+		&group.ID, &group.UserID, &group.StartTime, &group.EndTime, &group.Notes,
+		&group.CreatedAt, &group.UpdatedAt, &canceledAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("booking group with ID %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if canceledAt.Valid {
+		group.CanceledAt = &canceledAt.Time
+	}
+
+	return group, nil
+}
+
+func (r *PostgreSQLBookingRepository) CreateSeries(series *BookingSeries) error {
+	query := `
+		INSERT INTO booking_series (user_id, resource_id, rule, start_time, end_time, notes, created_at, updated_at, canceled_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`
+
+	return r.db.QueryRow(
+		query,
+		series.UserID, series.ResourceID, series.Rule, series.StartTime, series.EndTime,
+		series.Notes, series.CreatedAt, series.UpdatedAt, series.CanceledAt,
+	).Scan(&series.ID)
+}
+
+func (r *PostgreSQLBookingRepository) GetSeries(id int) (*BookingSeries, error) {
+	query := `
+		SELECT id, user_id, resource_id, rule, start_time, end_time, COALESCE(notes, ''),
+			created_at, updated_at, canceled_at
+		FROM booking_series
+		WHERE id = $1`
+
+	series := &BookingSeries{}
+	var canceledAt sql.NullTime
+	err := r.db.QueryRow(query, id).Scan(
+		&series.ID, &series.UserID, &series.ResourceID, &series.Rule,
+		&series.StartTime, &series.EndTime, &series.Notes,
+		&series.CreatedAt, &series.UpdatedAt, &canceledAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("booking series with ID %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if canceledAt.Valid {
+		series.CanceledAt = &canceledAt.Time
+	}
+
+	return series, nil
+}
+
+func (r *PostgreSQLBookingRepository) UpdateSeries(series *BookingSeries) error {
+	query := `
+		UPDATE booking_series
+		SET rule = $2, start_time = $3, end_time = $4, notes = $5, updated_at = $6, canceled_at = $7
+		WHERE id = $1`
+
+	result, err := r.db.Exec(
+		query,
+		series.ID, series.Rule, series.StartTime, series.EndTime,
+		series.Notes, series.UpdatedAt, series.CanceledAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("booking series with ID %d %w", series.ID, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *PostgreSQLBookingRepository) GetByUserID(userID, limit, offset int) ([]*Booking, error) {
+	query := `SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE user_id = $1
+		ORDER BY start_time, id
+		LIMIT $2 OFFSET $3`
+
+	return r.queryBookings(query, userID, limit, offset)
+}
+
+func (r *PostgreSQLBookingRepository) GetUserBookingsBetween(userID int, startTime, endTime time.Time) ([]*Booking, error) {
+	query := `SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE user_id = $1
+		AND status <> $4
+		AND start_time < $3
+		AND end_time > $2
+		ORDER BY start_time, id`
+
+	return r.queryBookings(query, userID, startTime, endTime, BookingStatusCanceled)
+}
+
+func (r *PostgreSQLBookingRepository) CountActiveBookings(userID int, now time.Time) (int, error) {
+	query := `SELECT COUNT(*)
+		FROM bookings
+		WHERE user_id = $1
+		AND status IN ($2, $3, $4)
+		AND end_time > $5`
+
+	var count int
+	err := r.db.QueryRow(query, userID,
+		BookingStatusPending, BookingStatusConfirmed, BookingStatusCheckedIn, now).Scan(&count)
+	return count, err
+}
+
+func (r *PostgreSQLBookingRepository) GetByResourceID(resourceID, limit, offset int) ([]*Booking, error) {
+	query := `SELECT ` + bookingColumns + `
+		FROM bookings
+		WHERE resource_id = $1
+		ORDER BY start_time, id
+		LIMIT $2 OFFSET $3`
+
+	return r.queryBookings(query, resourceID, limit, offset)
+}
+
+// waitlistColumns is the column list shared by every waitlist SELECT
+const waitlistColumns = `id, user_id, resource_id, start_time, end_time, status,
+	COALESCE(notes, ''), seats, booking_id, offer_expires_at, created_at, updated_at`
+
+func (r *PostgreSQLBookingRepository) CreateWaitlistEntry(entry *WaitlistEntry) error {
+	query := `
+		INSERT INTO waitlist_entries (user_id, resource_id, start_time, end_time, status, notes, seats,
+			booking_id, offer_expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`
+
+	return r.db.QueryRow(
+		query,
+		entry.UserID, entry.ResourceID, entry.StartTime, entry.EndTime, entry.Status, entry.Notes, entry.Seats,
+		entry.BookingID, entry.OfferExpiresAt, entry.CreatedAt, entry.UpdatedAt,
+	).Scan(&entry.ID)
+}
+
+func (r *PostgreSQLBookingRepository) GetWaitlistEntry(id int) (*WaitlistEntry, error) {
+	query := `SELECT ` + waitlistColumns + ` FROM waitlist_entries WHERE id = $1`
+
+	entry, err := scanWaitlistEntry(r.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("waitlist entry with ID %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (r *PostgreSQLBookingRepository) UpdateWaitlistEntry(entry *WaitlistEntry, fromStatus WaitlistStatus) error {
+	query := `
+		UPDATE waitlist_entries
+		SET status = $3, notes = $4, booking_id = $5, offer_expires_at = $6, updated_at = $7
+		WHERE id = $1 AND status = $2`
+
+	result, err := r.db.Exec(
+		query,
+		entry.ID, fromStatus, entry.Status, entry.Notes,
+		entry.BookingID, entry.OfferExpiresAt, entry.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		if _, err := r.GetWaitlistEntry(entry.ID); err != nil {
+			return err
+		}
+		return ErrWaitlistEntryChanged
+	}
+	return nil
+}
+
+func (r *PostgreSQLBookingRepository) ListWaitlistEntries(query ListWaitlistQuery, limit, offset int) ([]*WaitlistEntry, error) {
+	var conditions []string
+	var args []interface{}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if query.UserID > 0 {
+		addCondition("user_id = $%d", query.UserID)
+	}
+	if query.ResourceID > 0 {
+		addCondition("resource_id = $%d", query.ResourceID)
+	}
+	if query.BookingID > 0 {
+		addCondition("booking_id = $%d", query.BookingID)
+	}
+	if query.Status != "" {
+		addCondition("status = $%d", query.Status)
+	}
+
+	sqlQuery := `SELECT ` + waitlistColumns + ` FROM waitlist_entries`
+	if len(conditions) > 0 {
+		sqlQuery += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit, offset)
+	sqlQuery += fmt.Sprintf(` ORDER BY created_at, id LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	return r.queryWaitlist(sqlQuery, args...)
+}
+
+func (r *PostgreSQLBookingRepository) GetWaitingEntries(resourceID int, startTime, endTime time.Time) ([]*WaitlistEntry, error) {
+	query := `SELECT ` + waitlistColumns + `
+		FROM waitlist_entries
+		WHERE resource_id = $1
+		AND status = $4
+		AND start_time < $3
+		AND end_time > $2
+		ORDER BY created_at, id`
+
+	return r.queryWaitlist(query, resourceID, startTime, endTime, WaitlistStatusWaiting)
+}
+
+func (r *PostgreSQLBookingRepository) GetExpiredOffers(now time.Time) ([]*WaitlistEntry, error) {
+	query := `SELECT ` + waitlistColumns + `
+		FROM waitlist_entries
+		WHERE status = $1
+		AND offer_expires_at < $2
+		ORDER BY offer_expires_at, id`
+
+	return r.queryWaitlist(query, WaitlistStatusOffered, now)
+}
+
+// queryWaitlist runs a SELECT over waitlistColumns and scans every row
+func (r *PostgreSQLBookingRepository) queryWaitlist(query string, args ...interface{}) ([]*WaitlistEntry, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*WaitlistEntry{}
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// scanWaitlistEntry reads a row selected with waitlistColumns
+func scanWaitlistEntry(row rowScanner) (*WaitlistEntry, error) {
+	entry := &WaitlistEntry{}
+	var bookingID sql.NullInt64
+	var offerExpiresAt sql.NullTime
+
+	err := row.Scan(
+		&entry.ID, &entry.UserID, &entry.ResourceID, &entry.StartTime, &entry.EndTime,
+		&entry.Status, &entry.Notes, &entry.Seats, &bookingID, &offerExpiresAt, &entry.CreatedAt, &entry.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if bookingID.Valid {
+		id := int(bookingID.Int64)
+		entry.BookingID = &id
+	}
+	if offerExpiresAt.Valid {
+		entry.OfferExpiresAt = &offerExpiresAt.Time
+	}
+
+	return entry, nil
+}
+
+const attendeeColumns = `id, booking_id, user_id, COALESCE(email, ''), status, created_at, updated_at, responded_at`
+
+// withAttendeeEvents runs write and stores the attendee's events in the outbox in one transaction
+func (r *PostgreSQLBookingRepository) withAttendeeEvents(booking *Booking, attendee *Attendee, eventTypes []BookingEventType, write func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := write(tx); err != nil {
+		return err
+	}
+
+	events, err := newAttendeeEvents(booking, attendee, eventTypes)
+	if err != nil {
+		return err
+	}
+	if err := storeOutboxEvents(tx, events); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgreSQLBookingRepository) AddAttendee(booking *Booking, attendee *Attendee, events ...BookingEventType) error {
+	return r.withAttendeeEvents(booking, attendee, events, func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+			INSERT INTO booking_attendees (booking_id, user_id, email, status, created_at, updated_at, responded_at)
+			VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7)
+			RETURNING id`,
+			attendee.BookingID, attendee.UserID, attendee.Email, attendee.Status,
+			attendee.CreatedAt, attendee.UpdatedAt, attendee.RespondedAt,
+		).Scan(&attendee.ID)
+
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+			return ErrAttendeeExists
+		}
+		return err
+	})
+}
+
+func (r *PostgreSQLBookingRepository) GetAttendees(bookingID int) ([]*Attendee, error) {
+	rows, err := r.db.Query(`SELECT `+attendeeColumns+`
+		FROM booking_attendees
+		WHERE booking_id = $1
+		ORDER BY id`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attendees := []*Attendee{}
+	for rows.Next() {
+		attendee := &Attendee{}
+		var userID sql.NullInt64
+		var respondedAt sql.NullTime
+		if err := rows.Scan(
+			&attendee.ID, &attendee.BookingID, &userID, &attendee.Email, &attendee.Status,
+			&attendee.CreatedAt, &attendee.UpdatedAt, &respondedAt,
+		); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			attendee.UserID = &id
+		}
+		if respondedAt.Valid {
+			attendee.RespondedAt = &respondedAt.Time
+		}
+		attendees = append(attendees, attendee)
+	}
+
+	return attendees, rows.Err()
+}
+
+func (r *PostgreSQLBookingRepository) UpdateAttendee(booking *Booking, attendee *Attendee, events ...BookingEventType) error {
+	return r.withAttendeeEvents(booking, attendee, events, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			UPDATE booking_attendees
+			SET status = $3, updated_at = $4, responded_at = $5
+			WHERE id = $1 AND booking_id = $2`,
+			attendee.ID, booking.ID, attendee.Status, attendee.UpdatedAt, attendee.RespondedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return expectAttendee(result)
+	})
+}
+
+func (r *PostgreSQLBookingRepository) RemoveAttendee(booking *Booking, attendee *Attendee, events ...BookingEventType) error {
+	return r.withAttendeeEvents(booking, attendee, events, func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM booking_attendees WHERE id = $1 AND booking_id = $2`, attendee.ID, booking.ID)
+		if err != nil {
+			return err
+		}
+		return expectAttendee(result)
+	})
+}
+
+// expectAttendee returns ErrAttendeeNotFound when a statement touched no attendee
+func expectAttendee(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAttendeeNotFound
+	}
+	return nil
+}
+
+func (r *PostgreSQLBookingRepository) CreateCalendarFeed(feed *CalendarFeed) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := revokeCalendarFeeds(tx, feed.OwnerType, feed.OwnerID, feed.CreatedAt); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO booking_calendar_feeds (owner_type, owner_id, token_hash, created_by, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5)
+		RETURNING id`,
+		feed.OwnerType, feed.OwnerID, feed.TokenHash, feed.CreatedBy, feed.CreatedAt,
+	).Scan(&feed.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PostgreSQLBookingRepository) GetCalendarFeed(tokenHash string) (*CalendarFeed, error) {
+	feed := &CalendarFeed{}
+	err := r.db.QueryRow(`
+		SELECT id, owner_type, owner_id, token_hash, COALESCE(created_by, 0), created_at
+		FROM booking_calendar_feeds
+		WHERE token_hash = $1 AND revoked_at IS NULL`, tokenHash,
+	).Scan(&feed.ID, &feed.OwnerType, &feed.OwnerID, &feed.TokenHash, &feed.CreatedBy, &feed.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCalendarFeedNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+func (r *PostgreSQLBookingRepository) RevokeCalendarFeeds(ownerType CalendarFeedOwner, ownerID int, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := revokeCalendarFeeds(tx, ownerType, ownerID, now); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// revokeCalendarFeeds revokes the owner's active feeds
+func revokeCalendarFeeds(tx *sql.Tx, ownerType CalendarFeedOwner, ownerID int, now time.Time) error {
+	_, err := tx.Exec(`
+		UPDATE booking_calendar_feeds
+		SET revoked_at = $3
+		WHERE owner_type = $1 AND owner_id = $2 AND revoked_at IS NULL`,
+		ownerType, ownerID, now,
+	)
+	return err
+}
+
+func (r *PostgreSQLBookingRepository) CreateQuotaGrant(grant *QuotaGrant) error {
+	query := `
+		INSERT INTO booking_quota_grants (user_id, extra_active_bookings, extra_hours_per_week,
+			extra_hours_per_month, reason, granted_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+
+	return r.db.QueryRow(
+		query,
+		grant.UserID, grant.ExtraActiveBookings, grant.ExtraHoursPerWeek, grant.ExtraHoursPerMonth,
+		grant.Reason, grant.GrantedBy, grant.ExpiresAt, grant.CreatedAt,
+	).Scan(&grant.ID)
+}
+
+func (r *PostgreSQLBookingRepository) GetQuotaGrants(userID int, now time.Time) ([]*QuotaGrant, error) {
+	query := `SELECT id, user_id, extra_active_bookings, extra_hours_per_week, extra_hours_per_month,
+			COALESCE(reason, ''), COALESCE(granted_by, 0), expires_at, created_at
+		FROM booking_quota_grants
+		WHERE user_id = $1
+		AND expires_at > $2
+		ORDER BY id`
+
+	rows, err := r.db.Query(query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []*QuotaGrant{}
+	for rows.Next() {
+		grant := &QuotaGrant{}
+		err := rows.Scan(
+			&grant.ID, &grant.UserID, &grant.ExtraActiveBookings, &grant.ExtraHoursPerWeek,
+			&grant.ExtraHoursPerMonth, &grant.Reason, &grant.GrantedBy, &grant.ExpiresAt, &grant.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+
+	return grants, rows.Err()
+}
+
+// queryBookings runs a SELECT over bookingColumns and scans every row
+func (r *PostgreSQLBookingRepository) queryBookings(query string, args ...interface{}) ([]*Booking, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := []*Booking{}
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+
+	return bookings, rows.Err()
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBooking reads a row selected with bookingColumns
+func scanBooking(row rowScanner) (*Booking, error) {
+	booking := &Booking{}
+	var canceledAt sql.NullTime
+	var seriesID sql.NullInt64
+	var expiresAt sql.NullTime
+	var checkedInAt sql.NullTime
+	var groupID sql.NullInt64
+
+	err := row.Scan(
+		&booking.ID, &booking.UserID, &booking.ResourceID,
+		&booking.StartTime, &booking.EndTime, &booking.Status,
+		&booking.Notes, &booking.CreatedAt, &booking.UpdatedAt, &canceledAt, &seriesID,
+		&expiresAt, &booking.CancellationReason, &checkedInAt, &groupID, &booking.Seats, &booking.Shared,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if canceledAt.Valid {
+		booking.CanceledAt = &canceledAt.Time
+	}
+	if seriesID.Valid {
+		id := int(seriesID.Int64)
+		booking.SeriesID = &id
+	}
+	if expiresAt.Valid {
+		booking.ExpiresAt = &expiresAt.Time
+	}
+	if checkedInAt.Valid {
+		booking.CheckedInAt = &checkedInAt.Time
+	}
+	if groupID.Valid {
+		id := int(groupID.Int64)
+		booking.GroupID = &id
+	}
+
+	return booking, nil
+}
+
+// insertBookingArgs returns the parameters of insertBookingSQL
+func insertBookingArgs(booking *Booking) []interface{} {
+	return []interface{}{
+		booking.UserID, booking.ResourceID, booking.StartTime, booking.EndTime,
+		booking.Status, booking.Notes, booking.CreatedAt, booking.UpdatedAt,
+		booking.CanceledAt, booking.SeriesID, booking.ExpiresAt, booking.CancellationReason,
+		booking.CheckedInAt, booking.GroupID, booking.Seats, booking.Shared,
+	}
+}
+
+// updateBookingArgs returns the parameters of updateBookingSQL
+func updateBookingArgs(booking *Booking) []interface{} {
+	return []interface{}{
+		booking.ID, booking.UserID, booking.ResourceID, booking.StartTime, booking.EndTime,
+		booking.Status, booking.Notes, booking.UpdatedAt, booking.CanceledAt, booking.SeriesID,
+		booking.ExpiresAt, booking.CancellationReason, booking.CheckedInAt, booking.GroupID,
+		booking.Seats, booking.Shared,
+	}
+}
+
+// expectAffected returns a not found error when a statement touched no rows
+func expectAffected(result sql.Result, id int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("booking with ID %d %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// translatePostgresError maps overlap constraint violations to ErrBookingConflict
+func translatePostgresError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pgExclusionViolation {
+		return ErrBookingConflict
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// testDatabaseDSNEnv names the PostgreSQL database the repository tests run
+// against. It must be initialized with infrastructure/database/init.sql; the
+// PostgreSQL tests are skipped when it is not set.
+const testDatabaseDSNEnv = "BOOKING_TEST_DATABASE_DSN"
+
+// testRepository is a repository with a user and a resource to book
+type testRepository struct {
+	BookingRepository
+	UserID     int
+	ResourceID int
+}
+
+// forEachRepository runs test against the in-memory repository and, when
+// testDatabaseDSNEnv is set, against the PostgreSQL repository
+func forEachRepository(t *testing.T, test func(t *testing.T, repo testRepository)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, testRepository{BookingRepository: NewInMemoryBookingRepository(), UserID: 1, ResourceID: 1})
+	})
+	t.Run("postgres", func(t *testing.T) {
+		test(t, newTestPostgresRepository(t))
+	})
+}
+
+// newTestPostgresRepository connects to the test database and creates a user and
+// a resource that are removed, with their bookings, when the test ends
+func newTestPostgresRepository(t *testing.T) testRepository {
+	t.Helper()
+
+	dsn := os.Getenv(testDatabaseDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseDSNEnv)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+
+	repo := testRepository{BookingRepository: NewPostgreSQLBookingRepository(db)}
+	suffix := time.Now().UnixNano()
+	err = db.QueryRow(`
+		INSERT INTO users (email, password_hash, first_name, last_name)
+		VALUES ($1, 'x', 'Booking', 'Test') RETURNING id`,
+		fmt.Sprintf("booking-test-%d@example.com", suffix)).Scan(&repo.UserID)
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+	err = db.QueryRow(`INSERT INTO resources (name, type) VALUES ($1, 'room') RETURNING id`,
+		fmt.Sprintf("Booking test room %d", suffix)).Scan(&repo.ResourceID)
+	if err != nil {
+		t.Fatalf("failed to create test resource: %v", err)
+	}
+
+	t.Cleanup(func() {
+		// Outbox rows do not reference their booking, so they are removed first
+		for _, statement := range []string{
+			`DELETE FROM booking_outbox WHERE booking_id IN (SELECT id FROM bookings WHERE resource_id = $1)`,
+			`DELETE FROM resources WHERE id = $1`,
+		} {
+			if _, err := db.Exec(statement, repo.ResourceID); err != nil {
+				t.Errorf("failed to clean up test resource: %v", err)
+			}
+		}
+		if _, err := db.Exec(`DELETE FROM users WHERE id = $1`, repo.UserID); err != nil {
+			t.Errorf("failed to clean up test user: %v", err)
+		}
+	})
+
+	return repo
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestBooking returns a PENDING booking of the repository's user and resource
+func newTestBooking(repo testRepository, start time.Time, duration time.Duration) *Booking {
+	now := time.Now()
+	return &Booking{
+		UserID:     repo.UserID,
+		ResourceID: repo.ResourceID,
+		StartTime:  start,
+		EndTime:    start.Add(duration),
+		Status:     BookingStatusPending,
+		Seats:      1,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+func TestUpdateRejectsStaleStatus(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo testRepository) {
+		start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+		booking := newTestBooking(repo, start, time.Hour)
+		if err := repo.CreateIfAvailable(booking, Occupancy{}, BookingEventCreated); err != nil {
+			t.Fatalf("CreateIfAvailable: %v", err)
+		}
+
+		// An edit reads the booking, then a cancellation commits before it writes
+		stale, err := repo.GetByID(booking.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		canceled := *stale
+		canceledAt := time.Now()
+		canceled.Status = BookingStatusCanceled
+		canceled.CanceledAt = &canceledAt
+		if err := repo.UpdateIfStatus(&canceled, BookingStatusPending, BookingEventCanceled); err != nil {
+			t.Fatalf("UpdateIfStatus: %v", err)
+		}
+
+		stale.Notes = "moved"
+		stale.StartTime = stale.StartTime.Add(time.Hour)
+		stale.EndTime = stale.EndTime.Add(time.Hour)
+		if err := repo.Update(stale, BookingEventUpdated); !errors.Is(err, ErrBookingChanged) {
+			t.Errorf("Update after cancellation: got %v, want ErrBookingChanged", err)
+		}
+		if err := repo.UpdateIfAvailable(stale, Occupancy{}, BookingEventUpdated); !errors.Is(err, ErrBookingChanged) {
+			t.Errorf("UpdateIfAvailable after cancellation: got %v, want ErrBookingChanged", err)
+		}
+
+		stored, err := repo.GetByID(booking.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if stored.Status != BookingStatusCanceled || stored.Notes != "" || !stored.StartTime.Equal(start) {
+			t.Errorf("stored booking was overwritten: status %s, notes %q, start %s", stored.Status, stored.Notes, stored.StartTime)
+		}
+	})
+}
+
+func TestUpdateGroupIfStatusIsAtomic(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo testRepository) {
+		start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+		group := &BookingGroup{UserID: repo.UserID, StartTime: start, EndTime: start.Add(time.Hour)}
+		bookings := []*Booking{newTestBooking(repo, start, time.Hour), newTestBooking(repo, start.Add(2*time.Hour), time.Hour)}
+		if err := repo.CreateGroupIfAvailable(group, bookings, []Occupancy{{}, {}}, BookingEventCreated); err != nil {
+			t.Fatalf("CreateGroupIfAvailable: %v", err)
+		}
+
+		// The second member is confirmed after the group cancellation read it
+		confirmed := *bookings[1]
+		confirmed.Status = BookingStatusConfirmed
+		if err := repo.UpdateIfStatus(&confirmed, BookingStatusPending, BookingEventConfirmed); err != nil {
+			t.Fatalf("UpdateIfStatus: %v", err)
+		}
+
+		canceledAt := time.Now()
+		group.CanceledAt = &canceledAt
+		for _, booking := range bookings {
+			booking.Status = BookingStatusCanceled
+			booking.CanceledAt = &canceledAt
+		}
+		fromStatuses := []BookingStatus{BookingStatusPending, BookingStatusPending}
+		if err := repo.UpdateGroupIfStatus(group, bookings, fromStatuses, BookingEventCanceled); !errors.Is(err, ErrBookingChanged) {
+			t.Fatalf("UpdateGroupIfStatus: got %v, want ErrBookingChanged", err)
+		}
+
+		stored, err := repo.GetGroup(group.ID)
+		if err != nil {
+			t.Fatalf("GetGroup: %v", err)
+		}
+		if stored.CanceledAt != nil {
+			t.Errorf("group was canceled although a member changed")
+		}
+		first, err := repo.GetByID(bookings[0].ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if first.Status != BookingStatusPending {
+			t.Errorf("first member status %s, want %s", first.Status, BookingStatusPending)
+		}
+	})
+}
+
+func TestListResumesAfterBooking(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo testRepository) {
+		start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+		// Two bookings share a start time, so the ID breaks the tie
+		var created []*Booking
+		for _, offset := range []time.Duration{0, 0, time.Hour, 2 * time.Hour} {
+			booking := newTestBooking(repo, start.Add(offset), time.Hour)
+			booking.Shared = true
+			if err := repo.Create(booking, BookingEventCreated); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			created = append(created, booking)
+		}
+
+		for _, query := range []ListBookingsQuery{{}, {UserID: repo.UserID}, {ResourceID: repo.ResourceID}} {
+			query.StartDate = start
+			var listed []int
+			for {
+				page, err := repo.List(query, 2, 0)
+				if err != nil {
+					t.Fatalf("List: %v", err)
+				}
+				for _, booking := range page {
+					listed = append(listed, booking.ID)
+				}
+				if len(page) < 2 {
+					break
+				}
+				last := page[len(page)-1]
+				query.AfterStart, query.AfterID = last.StartTime, last.ID
+			}
+
+			if len(listed) != len(created) {
+				t.Fatalf("listed %v, want the %d created bookings", listed, len(created))
+			}
+			for i, booking := range created {
+				if listed[i] != booking.ID {
+					t.Errorf("listed %v, want bookings in creation order", listed)
+					break
+				}
+			}
+		}
+	})
+}
+
+func TestGetNoShowsLeavesEndedBookings(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo testRepository) {
+		now := time.Now().Truncate(time.Second)
+		bookings := map[string]*Booking{
+			"missed":      newTestBooking(repo, now.Add(-time.Hour), 2*time.Hour),
+			"ended":       newTestBooking(repo, now.Add(-3*time.Hour), 2*time.Hour),
+			"in grace":    newTestBooking(repo, now.Add(-5*time.Minute), time.Hour),
+			"not started": newTestBooking(repo, now.Add(time.Hour), time.Hour),
+		}
+		// Shared bookings may overlap on the one test resource
+		for name, booking := range bookings {
+			booking.Status = BookingStatusConfirmed
+			booking.Shared = true
+			if err := repo.Create(booking, BookingEventCreated); err != nil {
+				t.Fatalf("Create %s: %v", name, err)
+			}
+		}
+
+		noShows, err := repo.GetNoShows(now.Add(-15*time.Minute), now)
+		if err != nil {
+			t.Fatalf("GetNoShows: %v", err)
+		}
+		if len(noShows) != 1 || noShows[0].ID != bookings["missed"].ID {
+			t.Errorf("GetNoShows returned %v, want only booking %d", bookingIDs(noShows), bookings["missed"].ID)
+		}
+	})
+}
+
+func TestAvailabilityChecksRecordSharedResources(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, repo testRepository) {
+		shared := Occupancy{Shared: true, Capacity: 4}
+		start := time.Now().Add(96 * time.Hour).Truncate(time.Hour)
+
+		// Overlapping bookings of a shared resource
+		var bookings []*Booking
+		for i := range 2 {
+			booking := newTestBooking(repo, start.Add(time.Duration(i)*30*time.Minute), time.Hour)
+			if err := repo.CreateIfAvailable(booking, shared, BookingEventCreated); err != nil {
+				t.Fatalf("CreateIfAvailable: %v", err)
+			}
+			bookings = append(bookings, booking)
+		}
+		moved := bookings[1]
+		moved.StartTime = moved.StartTime.Add(15 * time.Minute)
+		moved.EndTime = moved.EndTime.Add(15 * time.Minute)
+		if err := repo.UpdateIfAvailable(moved, shared, BookingEventUpdated); err != nil {
+			t.Fatalf("UpdateIfAvailable: %v", err)
+		}
+
+		for _, booking := range bookings {
+			stored, err := repo.GetByID(booking.ID)
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if !booking.Shared || !stored.Shared {
+				t.Errorf("booking %d of a shared resource is not marked shared", booking.ID)
+			}
+		}
+	})
+}
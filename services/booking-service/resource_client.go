@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrResourceNotFound is returned when resource-service does not know the resource
+	ErrResourceNotFound = errors.New("resource does not exist")
+	// ErrResourceInactive is returned when booking a resource that was deactivated
+	ErrResourceInactive = errors.New("resource is not active")
+	// ErrOutsideOpeningHours is returned when the time is not covered by the resource's availability slots
+	ErrOutsideOpeningHours = errors.New("requested time is outside the resource's opening hours")
+	// ErrResourceServiceUnavailable is returned when resource-service cannot be reached or fails
+	ErrResourceServiceUnavailable = errors.New("resource service is unavailable")
+)
+
+// Resource is the part of a resource-service resource the booking service relies on
+type Resource struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Capacity int    `json:"capacity"`
+	Location string `json:"location"`
+	IsActive bool   `json:"is_active"`
+	Shared   bool   `json:"shared"`
+
+	SetupBufferMinutes    int `json:"setup_buffer_minutes"`
+	TeardownBufferMinutes int `json:"teardown_buffer_minutes"`
+}
+
+// Buffers returns the resource's setup and teardown time
+func (r *Resource) Buffers() Buffers {
+	return Buffers{
+		Before: time.Duration(r.SetupBufferMinutes) * time.Minute,
+		After:  time.Duration(r.TeardownBufferMinutes) * time.Minute,
+	}
+}
+
+// Occupancy returns how the resource's bookings may overlap
+func (r *Resource) Occupancy() Occupancy {
+	return Occupancy{Buffers: r.Buffers(), Shared: r.Shared, Capacity: r.Capacity}
+}
+
+// OpeningWindow is one concrete opening period of a resource, as generated by
+// resource-service from its weekly availability slots
+type OpeningWindow struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// ResourceClient calls the resource-service REST API
+type ResourceClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewResourceClient(baseURL string, timeout time.Duration) *ResourceClient {
+	return &ResourceClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// GetResource fetches a resource by ID. It returns ErrResourceNotFound for unknown
+// resources and ErrResourceServiceUnavailable when the service cannot answer.
+func (c *ResourceClient) GetResource(id int) (*Resource, error) {
+	var resource Resource
+	if err := c.get(fmt.Sprintf("/api/v1/resources/%d", id), &resource); err != nil {
+		return nil, err
+	}
+
+	return &resource, nil
+}
+
+// ResourceQuery filters the resources returned by ListResources
+type ResourceQuery struct {
+	Type        string
+	Location    string
+	MinCapacity int
+}
+
+// resourcePageSize is how many resources ListResources requests at a time
+const resourcePageSize = 100
+
+// ListResources returns the active resources of resource-service matching the
+// query, reading every page
+func (c *ResourceClient) ListResources(query ResourceQuery) ([]*Resource, error) {
+	params := url.Values{}
+	if query.Type != "" {
+		params.Set("type", query.Type)
+	}
+	if query.Location != "" {
+		params.Set("location", query.Location)
+	}
+	if query.MinCapacity > 0 {
+		params.Set("min_capacity", strconv.Itoa(query.MinCapacity))
+	}
+	params.Set("size", strconv.Itoa(resourcePageSize))
+
+	var resources []*Resource
+	for page := 1; ; page++ {
+		params.Set("page", strconv.Itoa(page))
+		var batch []*Resource
+		if err := c.get("/api/v1/resources?"+params.Encode(), &batch); err != nil {
+			return nil, err
+		}
+		resources = append(resources, batch...)
+		if len(batch) < resourcePageSize {
+			return resources, nil
+		}
+	}
+}
+
+// GetOpeningWindows returns the opening periods of a resource on every day from
+// startDate to endDate (inclusive). Slot hours are interpreted in UTC, as
+// resource-service does.
+func (c *ResourceClient) GetOpeningWindows(id int, startDate, endDate time.Time) ([]OpeningWindow, error) {
+	path := fmt.Sprintf("/api/v1/resources/%d/availability?start_date=%s&end_date=%s",
+		id, startDate.UTC().Format("2006-01-02"), endDate.UTC().Format("2006-01-02"))
+
+	var windows []OpeningWindow
+	if err := c.get(path, &windows); err != nil {
+		return nil, err
+	}
+
+	return windows, nil
+}
+
+// get performs a GET request and decodes the JSON response into out
+func (c *ResourceClient) get(path string, out interface{}) error {
+	resp, err := c.httpClient.Get(c.baseURL + path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrResourceServiceUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrResourceNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: GET %s returned %d", ErrResourceServiceUnavailable, path, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: invalid response for GET %s: %v", ErrResourceServiceUnavailable, path, err)
+	}
+
+	return nil
+}
+
+// coversPeriod reports whether the opening windows cover [start, end) without gaps.
+// Adjacent or overlapping windows are merged, so 09:00-12:00 and 12:00-18:00
+// accept a booking from 11:00 to 13:00.
+func coversPeriod(windows []OpeningWindow, start, end time.Time) bool {
+	for _, window := range mergeOpeningWindows(windows) {
+		if !window.StartTime.After(start) && !window.EndTime.Before(end) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mergeOpeningWindows sorts the windows and joins adjacent or overlapping ones
+func mergeOpeningWindows(windows []OpeningWindow) []OpeningWindow {
+	sorted := make([]OpeningWindow, len(windows))
+	copy(sorted, windows)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+
+	var merged []OpeningWindow
+	for _, window := range sorted {
+		if last := len(merged) - 1; last >= 0 && !window.StartTime.After(merged[last].EndTime) {
+			if window.EndTime.After(merged[last].EndTime) {
+				merged[last].EndTime = window.EndTime
+			}
+			continue
+		}
+		merged = append(merged, window)
+	}
+
+	return merged
+}
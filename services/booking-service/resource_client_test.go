@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeResourceService stands in for resource-service. Every resource is open
+// daily from Opens to Closes o'clock UTC.
+type fakeResourceService struct {
+	Resources     []Resource
+	Opens, Closes int
+	// Delays holds the responses about a resource ID for a while
+	Delays map[int]time.Duration
+	// Failures answers requests about a resource ID with this status
+	Failures map[int]int
+}
+
+// start serves the fake on a test server that is closed when the test ends
+func (f *fakeResourceService) start(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/resources", func(w http.ResponseWriter, r *http.Request) {
+		var matching []Resource
+		for _, resource := range f.Resources {
+			if kind := r.URL.Query().Get("type"); kind == "" || kind == resource.Type {
+				matching = append(matching, resource)
+			}
+		}
+		writeFakeJSON(w, matching)
+	})
+	router.HandleFunc("/api/v1/resources/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if resource, ok := f.resource(w, r); ok {
+			writeFakeJSON(w, resource)
+		}
+	})
+	router.HandleFunc("/api/v1/resources/{id}/availability", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := f.resource(w, r); !ok {
+			return
+		}
+		from, err := time.Parse("2006-01-02", r.URL.Query().Get("start_date"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := time.Parse("2006-01-02", r.URL.Query().Get("end_date"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		windows := []OpeningWindow{}
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			windows = append(windows, OpeningWindow{
+				StartTime: day.Add(time.Duration(f.Opens) * time.Hour),
+				EndTime:   day.Add(time.Duration(f.Closes) * time.Hour),
+			})
+		}
+		writeFakeJSON(w, windows)
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// resource finds the resource of a request, applying its delay and failure. It
+// writes the response and returns false when there is nothing more to serve.
+func (f *fakeResourceService) resource(w http.ResponseWriter, r *http.Request) (Resource, bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	select {
+	case <-time.After(f.Delays[id]):
+	case <-r.Context().Done():
+		return Resource{}, false
+	}
+	if status := f.Failures[id]; status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return Resource{}, false
+	}
+
+	for _, resource := range f.Resources {
+		if resource.ID == id {
+			return resource, true
+		}
+	}
+	http.Error(w, "Resource not found", http.StatusNotFound)
+	return Resource{}, false
+}
+
+func writeFakeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// testResourceTimeout bounds calls to the fake resource-service
+const testResourceTimeout = 200 * time.Millisecond
+
+// newTestResourceService starts a fake resource-service with an active room, an
+// inactive room, a room that times out and a room that fails, open from 08:00
+// to 18:00, and returns the test configuration pointing at it
+func newTestResourceService(t *testing.T) Config {
+	fake := &fakeResourceService{
+		Resources: []Resource{
+			{ID: 1, Name: "Room 1", Type: "room", Capacity: 8, IsActive: true},
+			{ID: 2, Name: "Room 2", Type: "room", Capacity: 8, IsActive: false},
+			{ID: 3, Name: "Room 3", Type: "room", Capacity: 8, IsActive: true},
+			{ID: 4, Name: "Room 4", Type: "room", Capacity: 8, IsActive: true},
+		},
+		Opens:    8,
+		Closes:   18,
+		Delays:   map[int]time.Duration{3: 10 * testResourceTimeout},
+		Failures: map[int]int{4: http.StatusInternalServerError},
+	}
+
+	cfg := newTestConfig()
+	cfg.ResourceValidation = true
+	cfg.ResourceServiceURL = fake.start(t).URL
+	cfg.ResourceServiceTimeout = testResourceTimeout
+	return cfg
+}
+
+// tomorrowAt returns the given hour of tomorrow in UTC
+func tomorrowAt(hour int) time.Time {
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	return time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), hour, 0, 0, 0, time.UTC)
+}
+
+func TestResourceClientErrors(t *testing.T) {
+	cfg := newTestResourceService(t)
+	client := NewResourceClient(cfg.ResourceServiceURL, cfg.ResourceServiceTimeout)
+
+	tests := []struct {
+		name    string
+		id      int
+		wantErr error
+	}{
+		{name: "active", id: 1},
+		{name: "inactive is still returned", id: 2},
+		{name: "unknown", id: 99, wantErr: ErrResourceNotFound},
+		{name: "timeout", id: 3, wantErr: ErrResourceServiceUnavailable},
+		{name: "server error", id: 4, wantErr: ErrResourceServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, err := client.GetResource(tt.id)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetResource(%d) returned %v, want %v", tt.id, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || resource.ID != tt.id {
+				t.Errorf("GetResource(%d) returned %+v, %v", tt.id, resource, err)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		if _, err := NewResourceClient(server.URL, testResourceTimeout).GetResource(1); !errors.Is(err, ErrResourceServiceUnavailable) {
+			t.Errorf("GetResource on a closed server returned %v, want %v", err, ErrResourceServiceUnavailable)
+		}
+	})
+}
+
+func TestCreateBookingResourceErrorCodes(t *testing.T) {
+	router := newTestRouter(t, NewInMemoryBookingRepository(), newTestResourceService(t))
+	token := testToken(t, 1, RoleUser)
+
+	tests := []struct {
+		name       string
+		resourceID int
+		start, end time.Time
+		wantStatus int
+		wantCode   string
+	}{
+		{"open resource", 1, tomorrowAt(10), tomorrowAt(11), http.StatusCreated, ""},
+		{"unknown resource", 99, tomorrowAt(10), tomorrowAt(11), http.StatusUnprocessableEntity, ErrorCodeResourceNotFound},
+		{"inactive resource", 2, tomorrowAt(10), tomorrowAt(11), http.StatusConflict, ErrorCodeResourceInactive},
+		{"before opening", 1, tomorrowAt(7), tomorrowAt(9), http.StatusConflict, ErrorCodeOutsideOpeningHours},
+		{"after closing", 1, tomorrowAt(17), tomorrowAt(19), http.StatusConflict, ErrorCodeOutsideOpeningHours},
+		{"resource service timeout", 3, tomorrowAt(10), tomorrowAt(11), http.StatusServiceUnavailable, ErrorCodeResourceServiceUnavailable},
+		{"resource service error", 4, tomorrowAt(10), tomorrowAt(11), http.StatusServiceUnavailable, ErrorCodeResourceServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := CreateBookingRequest{ResourceID: tt.resourceID, StartTime: tt.start, EndTime: tt.end}
+			recorder := serveJSON(t, router, http.MethodPost, "/api/v1/bookings", token, body)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+
+			var response ErrorResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if response.Code != tt.wantCode {
+				t.Errorf("error code %q, want %q", response.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestConflictAlternatives(t *testing.T) {
+	fake := &fakeResourceService{
+		Resources: []Resource{
+			{ID: 1, Name: "Room 1", Type: "room", Capacity: 8, IsActive: true},
+			{ID: 5, Name: "Room 5", Type: "room", Capacity: 8, IsActive: true},
+		},
+		Opens:  8,
+		Closes: 18,
+	}
+	cfg := newTestConfig()
+	cfg.ResourceValidation = true
+	cfg.ResourceServiceURL = fake.start(t).URL
+	router := newTestRouter(t, NewInMemoryBookingRepository(), cfg)
+	token := testToken(t, 1, RoleUser)
+
+	createTestBooking(t, router, token, tomorrowAt(10))
+	moved := createTestBooking(t, router, token, tomorrowAt(14))
+
+	// alternativeKinds sends a conflicting request and returns the kinds suggested
+	alternativeKinds := func(method, path string, body any) map[string]bool {
+		t.Helper()
+		recorder := serveJSON(t, router, method, path, token, body)
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("%s %s: status %d, want %d: %s", method, path, recorder.Code, http.StatusConflict, recorder.Body)
+		}
+		var response ErrorResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode error response: %v", err)
+		}
+		kinds := make(map[string]bool)
+		for _, alternative := range response.Alternatives {
+			kinds[alternative.Kind] = true
+		}
+		return kinds
+	}
+
+	start, end := tomorrowAt(10), tomorrowAt(11)
+	kinds := alternativeKinds(http.MethodPost, "/api/v1/bookings",
+		CreateBookingRequest{ResourceID: 1, StartTime: start, EndTime: end})
+	if !kinds[AlternativeOtherTime] || !kinds[AlternativeOtherResource] {
+		t.Errorf("new booking: alternatives %v, want %s and %s", kinds, AlternativeOtherTime, AlternativeOtherResource)
+	}
+
+	kinds = alternativeKinds(http.MethodPut, "/api/v1/bookings/"+strconv.Itoa(moved.ID),
+		UpdateBookingRequest{StartTime: &start, EndTime: &end})
+	if !kinds[AlternativeOtherTime] || kinds[AlternativeOtherResource] {
+		t.Errorf("moved booking: alternatives %v, want only %s", kinds, AlternativeOtherTime)
+	}
+}
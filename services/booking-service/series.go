@@ -0,0 +1,409 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrNotInSeries is returned for series operations on a standalone booking
+	ErrNotInSeries = errors.New("booking is not part of a series")
+	// ErrInvalidRecurrence is returned when a recurrence rule cannot be used
+	ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+)
+
+// CreateSeries expands a recurrence rule and books every occurrence independently.
+// Occurrences that conflict are reported in the result instead of failing the
+// whole series.
+func (s *BookingService) CreateSeries(caller Identity, req CreateBookingRequest) (*SeriesResult, error) {
+	rule, err := ParseRecurrenceRule(req.Recurrence)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+
+	occurrences := rule.Occurrences(req.StartTime, req.EndTime)
+	if len(occurrences) == 0 {
+		return nil, fmt.Errorf("%w: no occurrences", ErrInvalidRecurrence)
+	}
+
+	isOpen, err := s.openingHours(req.ResourceID, occurrences[0].StartTime, occurrences[len(occurrences)-1].EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	occupancy, err := s.occupancy(req.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	seats, err := seats(req.Seats, occupancy)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rules that fail for the first occurrence reject the whole series; later
+	// occurrences may still break date-dependent rules such as the advance window
+	if err := s.checkPolicy(caller, req.ResourceID, occurrences[0].StartTime, occurrences[0].EndTime); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	series := &BookingSeries{
+		UserID:     caller.UserID,
+		ResourceID: req.ResourceID,
+		Rule:       rule.String(),
+		StartTime:  occurrences[0].StartTime,
+		EndTime:    occurrences[0].EndTime,
+		Notes:      req.Notes,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := s.repository.CreateSeries(series); err != nil {
+		return nil, fmt.Errorf("failed to create booking series: %w", err)
+	}
+
+	expiresAt := s.holdExpiry(req.ResourceID, now)
+	result := &SeriesResult{Series: series, Bookings: []*Booking{}}
+	for _, occurrence := range occurrences {
+		if !isOpen(occurrence.StartTime, occurrence.EndTime) {
+			result.Failed = append(result.Failed, OccurrenceFailure{
+				StartTime: occurrence.StartTime,
+				EndTime:   occurrence.EndTime,
+				Reason:    ErrOutsideOpeningHours.Error(),
+			})
+			continue
+		}
+
+		if err := s.checkPolicy(caller, req.ResourceID, occurrence.StartTime, occurrence.EndTime); err != nil {
+			result.Failed = append(result.Failed, OccurrenceFailure{
+				StartTime: occurrence.StartTime,
+				EndTime:   occurrence.EndTime,
+				Reason:    err.Error(),
+			})
+			continue
+		}
+
+		booking := Booking{
+			UserID:     caller.UserID,
+			ResourceID: req.ResourceID,
+			StartTime:  occurrence.StartTime,
+			EndTime:    occurrence.EndTime,
+			Status:     BookingStatusPending,
+			Notes:      req.Notes,
+			Seats:      seats,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			SeriesID:   &series.ID,
+			ExpiresAt:  expiresAt,
+		}
+
+		// Occurrences booked so far count towards the quota of the next ones
+		if err := s.checkQuota(caller, &booking); err != nil {
+			result.Failed = append(result.Failed, OccurrenceFailure{
+				StartTime: occurrence.StartTime,
+				EndTime:   occurrence.EndTime,
+				Reason:    err.Error(),
+			})
+			continue
+		}
+
+		if err := s.repository.CreateIfAvailable(&booking, occupancy, BookingEventCreated); err != nil {
+			result.Failed = append(result.Failed, OccurrenceFailure{
+				StartTime: occurrence.StartTime,
+				EndTime:   occurrence.EndTime,
+				Reason:    err.Error(),
+			})
+			continue
+		}
+
+		result.Bookings = append(result.Bookings, &booking)
+	}
+
+	// A series without any booked occurrence is closed immediately
+	if len(result.Bookings) == 0 {
+		series.CanceledAt = &now
+		if err := s.repository.UpdateSeries(series); err != nil {
+			return nil, fmt.Errorf("failed to update booking series: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// GetSeries retrieves a series with all its occurrences
+func (s *BookingService) GetSeries(id int) (*BookingSeriesWithOccurrences, error) {
+	series, err := s.repository.GetSeries(id)
+	if err != nil {
+		return nil, fmt.Errorf("booking series not found: %w", err)
+	}
+
+	occurrences, err := s.repository.GetBySeriesID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get series occurrences: %w", err)
+	}
+
+	return &BookingSeriesWithOccurrences{
+		BookingSeries: *series,
+		Occurrences:   occurrences,
+	}, nil
+}
+
+// CancelSeries cancels every occurrence of a series that has not started yet
+func (s *BookingService) CancelSeries(id int) (*SeriesResult, error) {
+	series, err := s.repository.GetSeries(id)
+	if err != nil {
+		return nil, fmt.Errorf("booking series not found: %w", err)
+	}
+
+	occurrences, err := s.repository.GetBySeriesID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get series occurrences: %w", err)
+	}
+
+	now := time.Now()
+	result := &SeriesResult{Series: series, Bookings: []*Booking{}}
+	for _, booking := range occurrences {
+		if !booking.StartTime.After(now) || !booking.IsValidTransition(BookingStatusCanceled) {
+			continue
+		}
+
+		if err := s.cancel(booking, ""); err != nil {
+			result.Failed = append(result.Failed, occurrenceFailure(booking, err))
+			continue
+		}
+
+		result.Bookings = append(result.Bookings, booking)
+	}
+
+	series.CanceledAt = &now
+	series.UpdatedAt = now
+	if err := s.repository.UpdateSeries(series); err != nil {
+		return nil, fmt.Errorf("failed to cancel booking series: %w", err)
+	}
+
+	return result, nil
+}
+
+// UpdateFollowing applies a change to an occurrence and every later occurrence of
+// its series. Time changes are applied as the same shift of start and end time.
+func (s *BookingService) UpdateFollowing(caller Identity, id int, req UpdateBookingRequest) (*SeriesResult, error) {
+	booking, err := s.repository.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("booking not found: %w", err)
+	}
+
+	if booking.SeriesID == nil {
+		return nil, ErrNotInSeries
+	}
+
+	series, err := s.repository.GetSeries(*booking.SeriesID)
+	if err != nil {
+		return nil, fmt.Errorf("booking series not found: %w", err)
+	}
+
+	var startShift, endShift time.Duration
+	if req.StartTime != nil {
+		startShift = req.StartTime.Sub(booking.StartTime)
+	}
+	if req.EndTime != nil {
+		endShift = req.EndTime.Sub(booking.EndTime)
+	}
+	timeChanged := startShift != 0 || endShift != 0
+
+	occurrences, err := s.repository.GetBySeriesID(series.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get series occurrences: %w", err)
+	}
+
+	var following []*Booking
+	for _, occurrence := range occurrences {
+		if !occurrence.StartTime.Before(booking.StartTime) && occurrence.CanBeModified() {
+			following = append(following, occurrence)
+		}
+	}
+
+	// Move the occurrence furthest in the shift direction first, so an occurrence
+	// never collides with the old slot of a sibling that has not moved yet
+	if startShift > 0 {
+		sort.Slice(following, func(i, j int) bool {
+			return following[i].StartTime.After(following[j].StartTime)
+		})
+	}
+
+	isOpen := func(start, end time.Time) bool { return true }
+	var occupancy Occupancy
+	if timeChanged && len(following) > 0 {
+		from, to := following[0].StartTime, following[0].EndTime
+		for _, occurrence := range following {
+			if occurrence.StartTime.Before(from) {
+				from = occurrence.StartTime
+			}
+			if occurrence.EndTime.After(to) {
+				to = occurrence.EndTime
+			}
+		}
+		if isOpen, err = s.openingHours(series.ResourceID, from.Add(startShift), to.Add(endShift)); err != nil {
+			return nil, err
+		}
+	}
+	owner := caller
+	if timeChanged && len(following) > 0 {
+		if owner, err = s.bookingOwner(caller, series.UserID); err != nil {
+			return nil, err
+		}
+	}
+	if (timeChanged || req.Seats != nil) && len(following) > 0 {
+		if occupancy, err = s.occupancy(series.ResourceID); err != nil {
+			return nil, err
+		}
+	}
+	var seatCount int
+	if req.Seats != nil {
+		if seatCount, err = seats(*req.Seats, occupancy); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	result := &SeriesResult{Series: series, Bookings: []*Booking{}}
+	if len(following) > 0 {
+		if result.Series, err = s.splitSeries(series, booking, req, startShift, endShift, now); err != nil {
+			return nil, err
+		}
+	}
+	seriesID := result.Series.ID
+
+	// fail reports an occurrence that could not be changed. It is not stored, so it
+	// keeps its old time and notes in the series it was in, as an exception to
+	// that series' rule.
+	fail := func(occurrence *Booking, err error) {
+		result.Failed = append(result.Failed, occurrenceFailure(occurrence, err))
+	}
+
+	for _, occurrence := range following {
+		occurrence.SeriesID = &seriesID
+		occurrence.StartTime = occurrence.StartTime.Add(startShift)
+		occurrence.EndTime = occurrence.EndTime.Add(endShift)
+		if req.Notes != nil {
+			occurrence.Notes = *req.Notes
+		}
+		if req.Seats != nil {
+			occurrence.Seats = seatCount
+		}
+		occurrence.UpdatedAt = now
+
+		if !occurrence.StartTime.Before(occurrence.EndTime) {
+			fail(occurrence, fmt.Errorf("end time must be after start time"))
+			continue
+		}
+
+		if !isOpen(occurrence.StartTime, occurrence.EndTime) {
+			fail(occurrence, ErrOutsideOpeningHours)
+			continue
+		}
+
+		if timeChanged {
+			if err := s.checkPolicy(owner, occurrence.ResourceID, occurrence.StartTime, occurrence.EndTime); err != nil {
+				fail(occurrence, err)
+				continue
+			}
+			if err := s.checkQuota(caller, occurrence); err != nil {
+				fail(occurrence, err)
+				continue
+			}
+		}
+
+		if timeChanged || req.Seats != nil {
+			err = s.repository.UpdateIfAvailable(occurrence, occupancy, BookingEventUpdated)
+		} else {
+			err = s.repository.Update(occurrence, BookingEventUpdated)
+		}
+		if err != nil {
+			fail(occurrence, err)
+			continue
+		}
+
+		result.Bookings = append(result.Bookings, occurrence)
+	}
+
+	sort.Slice(result.Bookings, func(i, j int) bool {
+		return result.Bookings[i].StartTime.Before(result.Bookings[j].StartTime)
+	})
+
+	return result, nil
+}
+
+// splitSeries updates the series once booking and every later occurrence are
+// edited, so its rule, first occurrence and notes keep describing its bookings.
+// An edit from the first occurrence changes the whole series. A later edit ends
+// the series before booking and starts a new one from the edited occurrence,
+// which the edited occurrences move to. It returns the series they belong to.
+func (s *BookingService) splitSeries(series *BookingSeries, booking *Booking, req UpdateBookingRequest, startShift, endShift time.Duration, now time.Time) (*BookingSeries, error) {
+	rule, err := ParseRecurrenceRule(series.Rule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+
+	// Weekdays and the end of the rule follow the edited occurrence
+	following := *rule
+	following.ByDay = shiftWeekdays(rule.ByDay, booking.StartTime, booking.StartTime.Add(startShift))
+	if !rule.Until.IsZero() {
+		following.Until = rule.Until.Add(startShift)
+	}
+
+	edited := *series
+	edited.StartTime = booking.StartTime.Add(startShift)
+	edited.EndTime = booking.EndTime.Add(endShift)
+	if req.Notes != nil {
+		edited.Notes = *req.Notes
+	}
+	edited.UpdatedAt = now
+
+	if !booking.StartTime.After(series.StartTime) {
+		edited.Rule = following.String()
+		if err := s.repository.UpdateSeries(&edited); err != nil {
+			return nil, fmt.Errorf("failed to update booking series: %w", err)
+		}
+		return &edited, nil
+	}
+
+	// The new series repeats as many times as the original had left
+	if rule.Count > 0 {
+		earlier := 0
+		for _, occurrence := range rule.Occurrences(series.StartTime, series.EndTime) {
+			if occurrence.StartTime.Before(booking.StartTime) {
+				earlier++
+			}
+		}
+		following.Count = max(rule.Count-earlier, 1)
+	}
+	edited.Rule = following.String()
+	edited.CreatedAt = now
+	if err := s.repository.CreateSeries(&edited); err != nil {
+		return nil, fmt.Errorf("failed to create booking series: %w", err)
+	}
+
+	ended := *rule
+	ended.Count = 0
+	ended.Until = booking.StartTime.Add(-time.Second)
+	series.Rule = ended.String()
+	series.UpdatedAt = now
+	if err := s.repository.UpdateSeries(series); err != nil {
+		return nil, fmt.Errorf("failed to update booking series: %w", err)
+	}
+
+	return &edited, nil
+}
+
+// occurrenceFailure describes an occurrence that could not be changed
+func occurrenceFailure(booking *Booking, err error) OccurrenceFailure {
+	return OccurrenceFailure{
+		BookingID: booking.ID,
+		StartTime: booking.StartTime,
+		EndTime:   booking.EndTime,
+		Reason:    err.Error(),
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// nextMonday returns 10:00 UTC on the first Monday at least a week away
+func nextMonday() time.Time {
+	day := time.Now().UTC().AddDate(0, 0, 7)
+	for day.Weekday() != time.Monday {
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), 10, 0, 0, 0, time.UTC)
+}
+
+// checkSeriesRule verifies that the rule of a series expands to the times of its
+// active occurrences
+func checkSeriesRule(t *testing.T, service *BookingService, id int) *BookingSeriesWithOccurrences {
+	t.Helper()
+
+	series, err := service.GetSeries(id)
+	if err != nil {
+		t.Fatalf("GetSeries(%d): %v", id, err)
+	}
+	rule, err := ParseRecurrenceRule(series.Rule)
+	if err != nil {
+		t.Fatalf("series %d has an invalid rule %q: %v", id, series.Rule, err)
+	}
+
+	var want, got []time.Time
+	for _, occurrence := range rule.Occurrences(series.StartTime, series.EndTime) {
+		want = append(want, occurrence.StartTime)
+	}
+	for _, booking := range series.Occurrences {
+		if booking.Status != BookingStatusCanceled {
+			got = append(got, booking.StartTime)
+		}
+	}
+	if !slices.EqualFunc(got, want, time.Time.Equal) {
+		t.Errorf("series %d with rule %q holds bookings at %v, its rule expands to %v", id, series.Rule, got, want)
+	}
+	return series
+}
+
+func TestUpdateFollowingSplitsSeries(t *testing.T) {
+	service := NewBookingService(NewInMemoryBookingRepository(), newTestConfig())
+	caller := Identity{UserID: 1, Role: RoleUser}
+	start := nextMonday()
+
+	created, err := service.CreateSeries(caller, CreateBookingRequest{
+		ResourceID: 1,
+		StartTime:  start,
+		EndTime:    start.Add(time.Hour),
+		Notes:      "standup",
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO;COUNT=6",
+	})
+	if err != nil || len(created.Bookings) != 6 {
+		t.Fatalf("CreateSeries: %v, %d bookings", err, len(created.Bookings))
+	}
+
+	// The third Monday and every later one move to Tuesday afternoon
+	third := created.Bookings[2]
+	newStart := third.StartTime.Add(24*time.Hour + 4*time.Hour)
+	newEnd := newStart.Add(90 * time.Minute)
+	notes := "moved"
+	result, err := service.UpdateFollowing(caller, third.ID, UpdateBookingRequest{StartTime: &newStart, EndTime: &newEnd, Notes: &notes})
+	if err != nil {
+		t.Fatalf("UpdateFollowing: %v", err)
+	}
+	if len(result.Bookings) != 4 || len(result.Failed) != 0 {
+		t.Fatalf("UpdateFollowing moved %d occurrences and failed %d, want 4 and 0", len(result.Bookings), len(result.Failed))
+	}
+	if result.Series.ID == created.Series.ID {
+		t.Fatalf("UpdateFollowing kept the edited occurrences in series %d", created.Series.ID)
+	}
+
+	original := checkSeriesRule(t, service, created.Series.ID)
+	if len(original.Occurrences) != 2 || original.Notes != "standup" {
+		t.Errorf("original series holds %d occurrences with notes %q, want 2 with %q", len(original.Occurrences), original.Notes, "standup")
+	}
+
+	edited := checkSeriesRule(t, service, result.Series.ID)
+	if edited.Rule != "FREQ=WEEKLY;COUNT=4;BYDAY=TU" {
+		t.Errorf("edited series has rule %q", edited.Rule)
+	}
+	if !edited.StartTime.Equal(newStart) || !edited.EndTime.Equal(newEnd) || edited.Notes != notes {
+		t.Errorf("edited series starts %s to %s with notes %q", edited.StartTime, edited.EndTime, edited.Notes)
+	}
+	if len(edited.Occurrences) != 4 {
+		t.Errorf("edited series holds %d occurrences, want 4", len(edited.Occurrences))
+	}
+}
+
+func TestUpdateFollowingFromFirstOccurrenceUpdatesSeries(t *testing.T) {
+	service := NewBookingService(NewInMemoryBookingRepository(), newTestConfig())
+	caller := Identity{UserID: 1, Role: RoleUser}
+	start := nextMonday()
+	until := start.AddDate(0, 0, 21).Add(2 * time.Hour)
+
+	created, err := service.CreateSeries(caller, CreateBookingRequest{
+		ResourceID: 1,
+		StartTime:  start,
+		EndTime:    start.Add(time.Hour),
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO;UNTIL=" + until.Format("20060102T150405Z"),
+	})
+	if err != nil || len(created.Bookings) != 4 {
+		t.Fatalf("CreateSeries: %v, %d bookings", err, len(created.Bookings))
+	}
+
+	newStart := start.AddDate(0, 0, 2)
+	newEnd := newStart.Add(time.Hour)
+	result, err := service.UpdateFollowing(caller, created.Bookings[0].ID, UpdateBookingRequest{StartTime: &newStart, EndTime: &newEnd})
+	if err != nil || len(result.Bookings) != 4 {
+		t.Fatalf("UpdateFollowing: %v, %d bookings moved", err, len(result.Bookings))
+	}
+	if result.Series.ID != created.Series.ID {
+		t.Errorf("UpdateFollowing from the first occurrence created series %d", result.Series.ID)
+	}
+
+	series := checkSeriesRule(t, service, created.Series.ID)
+	if len(series.Occurrences) != 4 || !series.StartTime.Equal(newStart) {
+		t.Errorf("series holds %d occurrences from %s, want 4 from %s", len(series.Occurrences), series.StartTime, newStart)
+	}
+}
+
+func TestUpdateFollowingLeavesFailedOccurrences(t *testing.T) {
+	repo := NewInMemoryBookingRepository()
+	service := NewBookingService(repo, newTestConfig())
+	caller := Identity{UserID: 1, Role: RoleUser}
+	start := nextMonday()
+
+	created, err := service.CreateSeries(caller, CreateBookingRequest{
+		ResourceID: 1,
+		StartTime:  start,
+		EndTime:    start.Add(time.Hour),
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO;COUNT=4",
+	})
+	if err != nil || len(created.Bookings) != 4 {
+		t.Fatalf("CreateSeries: %v, %d bookings", err, len(created.Bookings))
+	}
+
+	// The last Monday cannot move: its new slot is taken
+	last := created.Bookings[3]
+	blockerStart := last.StartTime.Add(2 * time.Hour)
+	if _, err := service.Create(caller, CreateBookingRequest{ResourceID: 1, StartTime: blockerStart, EndTime: blockerStart.Add(time.Hour)}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	newStart := created.Bookings[1].StartTime.Add(2 * time.Hour)
+	newEnd := newStart.Add(time.Hour)
+	result, err := service.UpdateFollowing(caller, created.Bookings[1].ID, UpdateBookingRequest{StartTime: &newStart, EndTime: &newEnd})
+	if err != nil {
+		t.Fatalf("UpdateFollowing: %v", err)
+	}
+	if len(result.Bookings) != 2 || len(result.Failed) != 1 || result.Failed[0].BookingID != last.ID {
+		t.Fatalf("UpdateFollowing moved %d occurrences and failed %+v, want 2 moved and booking %d failed",
+			len(result.Bookings), result.Failed, last.ID)
+	}
+
+	stored, err := repo.GetByID(last.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if *stored.SeriesID != created.Series.ID || !stored.StartTime.Equal(last.StartTime) {
+		t.Errorf("failed occurrence is in series %d at %s, want series %d at %s",
+			*stored.SeriesID, stored.StartTime, created.Series.ID, last.StartTime)
+	}
+}
@@ -1,53 +1,102 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
 
 type BookingService struct {
 	repository BookingRepository
-	// TODO: Add HTTP clients for User and Resource services
+	config     Config
+	resources  *ResourceClient
+	users      *UserClient
+	// Short-lived caches for the details attached to BookingWithDetails
+	resourceCache *ttlCache[Resource]
+	userCache     *ttlCache[User]
+	// checkInSecret signs check-in tokens
+	checkInSecret []byte
+	// policies are evaluated before bookings are created or moved
+	policies *PolicyEngine
+	// quotas limit how much each user may book
+	quotas QuotaConfig
 }
 
-func NewBookingService() *BookingService {
+func NewBookingService(repository BookingRepository, config Config) *BookingService {
 	return &BookingService{
-		repository: NewBookingRepository(),
+		repository: repository,
+		config:     config,
+		resources:  NewResourceClient(config.ResourceServiceURL, config.ResourceServiceTimeout),
+		users:      NewUserClient(config.UserServiceURL, config.UserServiceTimeout),
+
+		resourceCache: newTTLCache[Resource](config.DetailsCacheTTL),
+		userCache:     newTTLCache[User](config.DetailsCacheTTL),
+		checkInSecret: checkInSecret(config),
+		policies:      loadPolicies(config),
+		quotas:        loadQuotas(config),
 	}
 }
 
 // Create creates a new booking after validating availability
-func (s *BookingService) Create(userID int, req CreateBookingRequest) (*Booking, error) {
-	// Check resource availability (TODO: Call Resource Service)
-	conflicts, err := s.repository.GetConflictingBookings(req.ResourceID, req.StartTime, req.EndTime)
+func (s *BookingService) Create(caller Identity, req CreateBookingRequest) (*Booking, error) {
+	booking, occupancy, err := s.newBooking(caller, caller, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check conflicts: %w", err)
+		return nil, err
 	}
 
-	if len(conflicts) > 0 {
-		return nil, fmt.Errorf("resource is not available for the selected time slot")
+	// Check resource availability and insert in a single atomic step
+	if err := s.repository.CreateIfAvailable(booking, occupancy, BookingEventCreated); err != nil {
+		if errors.Is(err, ErrBookingConflict) {
+			return nil, s.bookingConflict(caller, booking, occupancy)
+		}
+		return nil, fmt.Errorf("failed to create booking: %w", err)
+	}
+
+	return booking, nil
+}
+
+// newBooking validates a request the caller makes for owner's booking and builds
+// the PENDING booking with the occupancy of its resource. Policies are evaluated
+// for the owner; quotas only apply when callers book for themselves.
+func (s *BookingService) newBooking(caller, owner Identity, req CreateBookingRequest) (*Booking, Occupancy, error) {
+	if err := s.validateResource(req.ResourceID, req.StartTime, req.EndTime); err != nil {
+		return nil, Occupancy{}, err
+	}
+
+	if err := s.checkPolicy(owner, req.ResourceID, req.StartTime, req.EndTime); err != nil {
+		return nil, Occupancy{}, err
+	}
+
+	occupancy, err := s.occupancy(req.ResourceID)
+	if err != nil {
+		return nil, Occupancy{}, err
+	}
+
+	seats, err := seats(req.Seats, occupancy)
+	if err != nil {
+		return nil, Occupancy{}, err
 	}
 
 	// Create booking
-	booking := Booking{
-		UserID:     userID,
+	now := time.Now()
+	booking := &Booking{
+		UserID:     owner.UserID,
 		ResourceID: req.ResourceID,
 		StartTime:  req.StartTime,
 		EndTime:    req.EndTime,
 		Status:     BookingStatusPending,
 		Notes:      req.Notes,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		Seats:      seats,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		ExpiresAt:  s.holdExpiry(req.ResourceID, now),
 	}
 
-	if err := s.repository.Create(&booking); err != nil {
-		return nil, fmt.Errorf("failed to create booking: %w", err)
+	if err := s.checkQuota(caller, booking); err != nil {
+		return nil, Occupancy{}, err
 	}
 
-	// TODO: Publish booking created event
-	s.publishEvent(BookingEventCreated, &booking)
-
-	return &booking, nil
+	return booking, occupancy, nil
 }
 
 // GetByID retrieves a booking by ID
@@ -57,16 +106,7 @@ func (s *BookingService) GetByID(id int) (*BookingWithDetails, error) {
 		return nil, fmt.Errorf("booking not found: %w", err)
 	}
 
-	// TODO: Enrich with user and resource details from other services
-	bookingWithDetails := &BookingWithDetails{
-		Booking:      *booking,
-		UserName:     "User Name",        // TODO: Get from User Service
-		UserEmail:    "user@example.com", // TODO: Get from User Service
-		ResourceName: "Resource Name",    // TODO: Get from Resource Service
-		ResourceType: "room",             // TODO: Get from Resource Service
-	}
-
-	return bookingWithDetails, nil
+	return s.enrich([]*Booking{booking})[0], nil
 }
 
 // List retrieves bookings with filtering
@@ -86,23 +126,11 @@ func (s *BookingService) List(query ListBookingsQuery) ([]*BookingWithDetails, e
 		return nil, fmt.Errorf("failed to list bookings: %w", err)
 	}
 
-	// TODO: Enrich with user and resource details
-	var enrichedBookings []*BookingWithDetails
-	for _, booking := range bookings {
-		enrichedBookings = append(enrichedBookings, &BookingWithDetails{
-			Booking:      *booking,
-			UserName:     "User Name",
-			UserEmail:    "user@example.com",
-			ResourceName: "Resource Name",
-			ResourceType: "room",
-		})
-	}
-
-	return enrichedBookings, nil
+	return s.enrich(bookings), nil
 }
 
 // Update updates a booking
-func (s *BookingService) Update(id int, req UpdateBookingRequest) (*Booking, error) {
+func (s *BookingService) Update(caller Identity, id int, req UpdateBookingRequest) (*Booking, error) {
 	booking, err := s.repository.GetByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("booking not found: %w", err)
@@ -112,52 +140,66 @@ func (s *BookingService) Update(id int, req UpdateBookingRequest) (*Booking, err
 		return nil, fmt.Errorf("booking cannot be modified in its current state: %s", booking.Status)
 	}
 
-	// Check for conflicts if time is being changed
-	if req.StartTime != nil || req.EndTime != nil {
-		startTime := booking.StartTime
-		endTime := booking.EndTime
+	timeChanged := req.StartTime != nil || req.EndTime != nil
+	if timeChanged && booking.GroupID != nil {
+		return nil, ErrGroupMember
+	}
+	if req.StartTime != nil {
+		booking.StartTime = *req.StartTime
+	}
+	if req.EndTime != nil {
+		booking.EndTime = *req.EndTime
+	}
 
-		if req.StartTime != nil {
-			startTime = *req.StartTime
-		}
-		if req.EndTime != nil {
-			endTime = *req.EndTime
-		}
+	if req.Notes != nil {
+		booking.Notes = *req.Notes
+	}
 
-		conflicts, err := s.repository.GetConflictingBookings(booking.ResourceID, startTime, endTime)
-		if err != nil {
-			return nil, fmt.Errorf("failed to check conflicts: %w", err)
-		}
+	booking.UpdatedAt = time.Now()
 
-		// Filter out the current booking from conflicts
-		var actualConflicts []*Booking
-		for _, conflict := range conflicts {
-			if conflict.ID != booking.ID {
-				actualConflicts = append(actualConflicts, conflict)
+	// Check for conflicts and store atomically if time or seats are being changed
+	if timeChanged || req.Seats != nil {
+		if timeChanged {
+			if err := s.validateResource(booking.ResourceID, booking.StartTime, booking.EndTime); err != nil {
+				return nil, err
 			}
-		}
 
-		if len(actualConflicts) > 0 {
-			return nil, fmt.Errorf("resource is not available for the selected time slot")
-		}
+			owner, err := s.bookingOwner(caller, booking.UserID)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.checkPolicy(owner, booking.ResourceID, booking.StartTime, booking.EndTime); err != nil {
+				return nil, err
+			}
 
-		booking.StartTime = startTime
-		booking.EndTime = endTime
-	}
+			if err := s.checkQuota(caller, booking); err != nil {
+				return nil, err
+			}
+		}
 
-	if req.Notes != nil {
-		booking.Notes = *req.Notes
+		var occupancy Occupancy
+		if occupancy, err = s.occupancy(booking.ResourceID); err != nil {
+			return nil, err
+		}
+		if req.Seats != nil {
+			if booking.Seats, err = seats(*req.Seats, occupancy); err != nil {
+				return nil, err
+			}
+		}
+		if err = s.repository.UpdateIfAvailable(booking, occupancy, BookingEventUpdated); errors.Is(err, ErrBookingConflict) {
+			return nil, s.bookingConflict(caller, booking, occupancy)
+		}
+	} else {
+		err = s.repository.Update(booking, BookingEventUpdated)
 	}
 
-	booking.UpdatedAt = time.Now()
-
-	if err := s.repository.Update(booking); err != nil {
+	if err != nil {
+		if errors.Is(err, ErrBookingConflict) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to update booking: %w", err)
 	}
 
-	// TODO: Publish booking updated event
-	s.publishEvent(BookingEventUpdated, booking)
-
 	return booking, nil
 }
 
@@ -168,21 +210,36 @@ func (s *BookingService) Cancel(id int) error {
 		return fmt.Errorf("booking not found: %w", err)
 	}
 
+	return s.cancel(booking, "")
+}
+
+// cancel moves a booking to CANCELED with an optional reason, provided nobody
+// changed its status since it was read, and offers the freed slot to the waitlist
+func (s *BookingService) cancel(booking *Booking, reason string) error {
 	if !booking.IsValidTransition(BookingStatusCanceled) {
-		return fmt.Errorf("booking cannot be canceled in its current state: %s", booking.Status)
+		return fmt.Errorf("%w: booking cannot be canceled in its current state: %s", ErrInvalidTransition, booking.Status)
 	}
 
-	booking.Status = BookingStatusCanceled
-	booking.UpdatedAt = time.Now()
+	fromStatus := booking.Status
 	now := time.Now()
+	booking.Status = BookingStatusCanceled
+	booking.UpdatedAt = now
 	booking.CanceledAt = &now
+	booking.CancellationReason = reason
 
-	if err := s.repository.Update(booking); err != nil {
+	if err := s.repository.UpdateIfStatus(booking, fromStatus, BookingEventCanceled); err != nil {
+		if errors.Is(err, ErrBookingChanged) {
+			return err
+		}
 		return fmt.Errorf("failed to cancel booking: %w", err)
 	}
 
-	// TODO: Publish booking canceled event
-	s.publishEvent(BookingEventCanceled, booking)
+	offerStatus := WaitlistStatusCanceled
+	if reason == CancellationReasonHoldExpired {
+		offerStatus = WaitlistStatusExpired
+	}
+	s.closeWaitlistOffer(booking.ID, offerStatus)
+	s.promoteWaitlist(booking.ResourceID, booking.StartTime, booking.EndTime)
 
 	return nil
 }
@@ -195,45 +252,192 @@ func (s *BookingService) Confirm(id int) (*Booking, error) {
 	}
 
 	if !booking.IsValidTransition(BookingStatusConfirmed) {
-		return nil, fmt.Errorf("booking cannot be confirmed in its current state: %s", booking.Status)
+		return nil, fmt.Errorf("%w: booking cannot be confirmed in its current state: %s", ErrInvalidTransition, booking.Status)
+	}
+
+	now := time.Now()
+	if booking.ExpiresAt != nil && !booking.ExpiresAt.After(now) {
+		return nil, ErrHoldExpired
 	}
 
 	booking.Status = BookingStatusConfirmed
-	booking.UpdatedAt = time.Now()
+	booking.UpdatedAt = now
+	booking.ExpiresAt = nil
 
-	if err := s.repository.Update(booking); err != nil {
+	// Fails if the hold expiry worker canceled the booking in the meantime
+	if err := s.repository.UpdateIfStatus(booking, BookingStatusPending, BookingEventConfirmed); err != nil {
+		if errors.Is(err, ErrBookingChanged) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to confirm booking: %w", err)
 	}
 
-	// TODO: Publish booking confirmed event
-	s.publishEvent(BookingEventConfirmed, booking)
+	s.closeWaitlistOffer(booking.ID, WaitlistStatusFulfilled)
 
 	return booking, nil
 }
 
+// validateResource checks with resource-service that the resource exists, is
+// active and is open for the whole period
+func (s *BookingService) validateResource(resourceID int, startTime, endTime time.Time) error {
+	isOpen, err := s.openingHours(resourceID, startTime, endTime)
+	if err != nil {
+		return err
+	}
+
+	if !isOpen(startTime, endTime) {
+		return ErrOutsideOpeningHours
+	}
+
+	return nil
+}
+
+// openingHours verifies that the resource exists and is active, and returns a
+// check for periods between from and to. Opening windows are fetched once, so
+// series can validate every occurrence with a single call.
+func (s *BookingService) openingHours(resourceID int, from, to time.Time) (func(start, end time.Time) bool, error) {
+	if !s.config.ResourceValidation {
+		return func(start, end time.Time) bool { return true }, nil
+	}
+
+	resource, err := s.resources.GetResource(resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !resource.IsActive {
+		return nil, ErrResourceInactive
+	}
+	s.resourceCache.Set(resourceID, resource)
+
+	windows, err := s.resources.GetOpeningWindows(resourceID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(start, end time.Time) bool {
+		return coversPeriod(windows, start, end)
+	}, nil
+}
+
+// cachedResource returns the resource from the details cache, fetching it from
+// resource-service when it is not cached
+func (s *BookingService) cachedResource(resourceID int) (*Resource, error) {
+	resource, cached := s.resourceCache.Get(resourceID)
+	if !cached {
+		fetched, err := s.resources.GetResource(resourceID)
+		if err != nil {
+			return nil, err
+		}
+		s.resourceCache.Set(resourceID, fetched)
+		resource = fetched
+	}
+	if resource == nil {
+		return nil, ErrResourceNotFound
+	}
+
+	return resource, nil
+}
+
 // CheckAvailability checks if a resource is available for booking
 func (s *BookingService) CheckAvailability(req AvailabilityCheckRequest) (*AvailabilityCheckResponse, error) {
-	conflicts, err := s.repository.GetConflictingBookings(req.ResourceID, req.StartTime, req.EndTime)
+	occupancy, err := s.occupancy(req.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := s.conflictingBookings(req.ResourceID, req.StartTime, req.EndTime, occupancy.Buffers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check availability: %w", err)
 	}
 
+	remaining := occupancy.RemainingSeats(conflicts, req.StartTime, req.EndTime)
 	response := &AvailabilityCheckResponse{
-		Available: len(conflicts) == 0,
+		ResourceID:     req.ResourceID,
+		Available:      remaining >= req.SeatsNeeded(),
+		RemainingSeats: remaining,
+	}
+	// Overlapping bookings of a shared resource only conflict once it is full
+	if !response.Available {
+		response.Conflicts = toBookingConflicts(conflicts, occupancy.Buffers)
 	}
 
-	if len(conflicts) > 0 {
-		for _, conflict := range conflicts {
-			response.Conflicts = append(response.Conflicts, BookingConflict{
-				ConflictingBookingID: conflict.ID,
-				ConflictStartTime:    conflict.StartTime,
-				ConflictEndTime:      conflict.EndTime,
-				Message:              fmt.Sprintf("Booking #%d conflicts with requested time", conflict.ID),
+	return response, nil
+}
+
+// CheckAvailabilityBatch checks several resources across several time windows and
+// returns one response per resource. A resource is available only if every
+// window has the requested seats free.
+func (s *BookingService) CheckAvailabilityBatch(req AvailabilityCheckRequest) ([]*AvailabilityCheckResponse, error) {
+	windows := req.AllWindows()
+	var responses []*AvailabilityCheckResponse
+
+	for _, resourceID := range req.AllResourceIDs() {
+		response := &AvailabilityCheckResponse{
+			ResourceID: resourceID,
+			Available:  true,
+		}
+		seen := make(map[int]bool)
+
+		occupancy, err := s.occupancy(resourceID)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, window := range windows {
+			conflicts, err := s.conflictingBookings(resourceID, window.StartTime, window.EndTime, occupancy.Buffers)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check availability: %w", err)
+			}
+
+			remaining := occupancy.RemainingSeats(conflicts, window.StartTime, window.EndTime)
+			available := remaining >= req.SeatsNeeded()
+			response.Windows = append(response.Windows, WindowAvailability{
+				StartTime:      window.StartTime,
+				EndTime:        window.EndTime,
+				Available:      available,
+				RemainingSeats: remaining,
 			})
+			if i == 0 || remaining < response.RemainingSeats {
+				response.RemainingSeats = remaining
+			}
+			if available {
+				continue
+			}
+			response.Available = false
+
+			// A booking spanning several windows is reported once
+			for _, conflict := range toBookingConflicts(conflicts, occupancy.Buffers) {
+				if !seen[conflict.ConflictingBookingID] {
+					seen[conflict.ConflictingBookingID] = true
+					response.Conflicts = append(response.Conflicts, conflict)
+				}
+			}
 		}
+
+		responses = append(responses, response)
 	}
 
-	return response, nil
+	return responses, nil
+}
+
+// toBookingConflicts describes conflicting bookings for API responses, including
+// the setup and teardown time they block
+func toBookingConflicts(conflicts []*Booking, buffers Buffers) []BookingConflict {
+	var result []BookingConflict
+	for _, conflict := range conflicts {
+		blockedStart, blockedEnd := buffers.Block(conflict.StartTime, conflict.EndTime)
+		result = append(result, BookingConflict{
+			ConflictingBookingID: conflict.ID,
+			ConflictStartTime:    conflict.StartTime,
+			ConflictEndTime:      conflict.EndTime,
+			Seats:                conflict.Seats,
+			BlockedStartTime:     blockedStart,
+			BlockedEndTime:       blockedEnd,
+			Message:              fmt.Sprintf("Booking #%d conflicts with requested time", conflict.ID),
+		})
+	}
+	return result
 }
 
 // GetUpcomingBookings gets upcoming bookings for a user
@@ -253,18 +457,3 @@ func (s *BookingService) GetUpcomingBookings(userID int) ([]*Booking, error) {
 
 	return bookings, nil
 }
-
-// publishEvent publishes a booking event (stub implementation)
-// TODO: Implement actual message publishing to RabbitMQ
-func (s *BookingService) publishEvent(eventType BookingEventType, booking *Booking) {
-	event := BookingEvent{
-		Type:      string(eventType),
-		BookingID: booking.ID,
-		UserID:    booking.UserID,
-		Timestamp: time.Now(),
-		Data:      *booking,
-	}
-
-	// TODO: Publish to message queue
-	fmt.Printf("Publishing event: %s for booking %d\n", event.Type, event.BookingID)
-}
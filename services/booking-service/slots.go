@@ -0,0 +1,362 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Limits of slot searches
+const (
+	DefaultSlotSearchLimit = 10
+	MaxSlotSearchLimit     = 50
+	MaxSlotSearchDays      = 31
+)
+
+// slotSearchStep is the spacing of the start times tried by a slot search
+const slotSearchStep = 15 * time.Minute
+
+// ErrInvalidPreferredHours is returned when the preferred hours of a slot search cannot be parsed
+var ErrInvalidPreferredHours = errors.New("invalid preferred hours")
+
+// SlotSearchRequest asks for the earliest free slots of a given length within a
+// time window, on any active resource matching the filters
+type SlotSearchRequest struct {
+	DurationMinutes int        `json:"duration_minutes"`
+	Window          TimeWindow `json:"window"`
+	ResourceType    string     `json:"resource_type,omitempty"`
+	// Location matches resources whose location contains it
+	Location    string `json:"location,omitempty"`
+	MinCapacity int    `json:"min_capacity,omitempty"`
+	// Seats needed on shared resources; one when omitted
+	Seats int `json:"seats,omitempty"`
+	// PreferredHours ranks slots within these hours of the day first
+	PreferredHours *PreferredHours `json:"preferred_hours,omitempty"`
+	// Limit is how many slots are returned; DefaultSlotSearchLimit when omitted
+	Limit int `json:"limit,omitempty"`
+}
+
+// Duration returns the length of the slots searched for
+func (r *SlotSearchRequest) Duration() time.Duration {
+	return time.Duration(r.DurationMinutes) * time.Minute
+}
+
+// SeatsNeeded returns the seats the search looks for, one when omitted
+func (r *SlotSearchRequest) SeatsNeeded() int {
+	return max(r.Seats, 1)
+}
+
+// ResultLimit returns how many slots the search returns
+func (r *SlotSearchRequest) ResultLimit() int {
+	if r.Limit <= 0 {
+		return DefaultSlotSearchLimit
+	}
+	return r.Limit
+}
+
+// PreferredHours is a range of hours of the day, such as 09:00 to 13:00, in a
+// time zone; UTC when none is given
+type PreferredHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// dailyHours is a parsed PreferredHours, in minutes after midnight
+type dailyHours struct {
+	start, end int
+	location   *time.Location
+}
+
+func (p *PreferredHours) parse() (*dailyHours, error) {
+	hours := &dailyHours{location: time.UTC}
+
+	var err error
+	if hours.start, err = minuteOfDay(p.Start); err != nil {
+		return nil, fmt.Errorf("%w: start: %v", ErrInvalidPreferredHours, err)
+	}
+	if hours.end, err = minuteOfDay(p.End); err != nil {
+		return nil, fmt.Errorf("%w: end: %v", ErrInvalidPreferredHours, err)
+	}
+	if hours.end <= hours.start {
+		return nil, fmt.Errorf("%w: end must be after start", ErrInvalidPreferredHours)
+	}
+	if p.TimeZone != "" {
+		if hours.location, err = time.LoadLocation(p.TimeZone); err != nil {
+			return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidPreferredHours, p.TimeZone)
+		}
+	}
+
+	return hours, nil
+}
+
+// minuteOfDay parses an "HH:MM" time of day; "24:00" is the end of the day
+func minuteOfDay(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an HH:MM time", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether the period from start to end lies within the hours of
+// the day it starts on. Nil hours contain nothing.
+func (h *dailyHours) contains(start, end time.Time) bool {
+	if h == nil {
+		return false
+	}
+
+	local := start.In(h.location)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, h.start, 0, 0, h.location)
+	to := time.Date(local.Year(), local.Month(), local.Day(), 0, h.end, 0, 0, h.location)
+	return !start.Before(from) && !end.After(to)
+}
+
+// FreeSlot is a period a resource can be booked for
+type FreeSlot struct {
+	ResourceID     int       `json:"resource_id"`
+	ResourceName   string    `json:"resource_name"`
+	ResourceType   string    `json:"resource_type"`
+	Location       string    `json:"location,omitempty"`
+	Capacity       int       `json:"capacity"`
+	StartTime      time.Time `json:"start_time"`
+	EndTime        time.Time `json:"end_time"`
+	RemainingSeats int       `json:"remaining_seats"`
+	// Preferred is set on slots within the requested preferred hours
+	Preferred bool `json:"preferred,omitempty"`
+}
+
+// SlotSearchResponse lists the best free slots found, best first
+type SlotSearchResponse struct {
+	Slots []FreeSlot `json:"slots"`
+	// ResourcesSearched is how many resources matched the filters
+	ResourcesSearched int `json:"resources_searched"`
+}
+
+// FindSlots returns the earliest free slots across the resources matching the
+// request. A slot lies within the resource's opening hours, fits next to its
+// bookings and passes the booking policies for the caller. Slots within the
+// preferred hours come first, then earlier slots, then slots on the smallest
+// resource, so larger rooms stay free for larger groups.
+func (s *BookingService) FindSlots(caller Identity, req SlotSearchRequest) (*SlotSearchResponse, error) {
+	var preferred *dailyHours
+	if req.PreferredHours != nil {
+		var err error
+		if preferred, err = req.PreferredHours.parse(); err != nil {
+			return nil, err
+		}
+	}
+
+	listed, err := s.resources.ListResources(ResourceQuery{
+		Type:        req.ResourceType,
+		Location:    req.Location,
+		MinCapacity: req.MinCapacity,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	seen := make(map[int]bool)
+	for _, resource := range listed {
+		if seen[resource.ID] || !resource.IsActive || resource.Capacity < req.MinCapacity ||
+			(resource.Shared && resource.Capacity < req.SeatsNeeded()) {
+			continue
+		}
+		seen[resource.ID] = true
+		s.resourceCache.Set(resource.ID, resource)
+		resources = append(resources, resource)
+	}
+
+	// Every resource contributes its own earliest slots
+	limit := req.ResultLimit()
+	found := make([][]FreeSlot, len(resources))
+	errs := make([]error, len(resources))
+	forEachResource(resources, func(i int, resource *Resource) {
+		found[i], errs[i] = s.earliestSlots(caller, resource, req, preferred, limit)
+	})
+
+	slots := []FreeSlot{}
+	for i := range resources {
+		// Resources deleted while searching are left out
+		if errs[i] != nil && !errors.Is(errs[i], ErrResourceNotFound) {
+			return nil, errs[i]
+		}
+		slots = append(slots, found[i]...)
+	}
+
+	sort.SliceStable(slots, func(i, j int) bool {
+		a, b := slots[i], slots[j]
+		if a.Preferred != b.Preferred {
+			return a.Preferred
+		}
+		if !a.StartTime.Equal(b.StartTime) {
+			return a.StartTime.Before(b.StartTime)
+		}
+		if a.Capacity != b.Capacity {
+			return a.Capacity < b.Capacity
+		}
+		return a.ResourceID < b.ResourceID
+	})
+	if len(slots) > limit {
+		slots = slots[:limit]
+	}
+
+	return &SlotSearchResponse{Slots: slots, ResourcesSearched: len(resources)}, nil
+}
+
+// earliestSlots returns the earliest free slots of a resource that do not overlap
+// each other: up to limit within the preferred hours and up to limit outside
+// them, enough for the ranking across resources either way
+func (s *BookingService) earliestSlots(caller Identity, resource *Resource, req SlotSearchRequest, preferred *dailyHours, limit int) ([]FreeSlot, error) {
+	var slots []FreeSlot
+	var counts [2]int
+	var lastEnd [2]time.Time
+
+	err := s.freeSlots(caller, resource, req.Window.StartTime, req.Window.EndTime, req.Duration(), req.SeatsNeeded(), 0,
+		func(slot FreeSlot) bool {
+			slot.Preferred = preferred.contains(slot.StartTime, slot.EndTime)
+			category := 0
+			if slot.Preferred {
+				category = 1
+			}
+			if counts[category] < limit && !slot.StartTime.Before(lastEnd[category]) {
+				slots = append(slots, slot)
+				counts[category]++
+				lastEnd[category] = slot.EndTime
+			}
+
+			// Without preferred hours no slot is preferred
+			return counts[0] < limit || (preferred != nil && counts[1] < limit)
+		})
+
+	return slots, err
+}
+
+// freeSlots calls yield with every free period of the given duration on the
+// resource between from and to, trying a start time every slotSearchStep, until
+// yield returns false. Periods in the past are skipped, and the booking with
+// ignoreID does not take up room, so a booking being moved is not in its own way.
+func (s *BookingService) freeSlots(caller Identity, resource *Resource, from, to time.Time, duration time.Duration, seats, ignoreID int, yield func(FreeSlot) bool) error {
+	now := time.Now()
+	if from.Before(now) {
+		from = now
+	}
+	if !from.Before(to) {
+		return nil
+	}
+
+	windows, err := s.resources.GetOpeningWindows(resource.ID, from, to)
+	if err != nil {
+		return err
+	}
+
+	occupancy := resource.Occupancy()
+	bookings, err := s.conflictingBookings(resource.ID, from, to, occupancy.Buffers)
+	if err != nil {
+		return fmt.Errorf("failed to list bookings: %w", err)
+	}
+	bookings = slices.DeleteFunc(bookings, func(booking *Booking) bool {
+		return booking.ID == ignoreID
+	})
+	sort.Slice(bookings, func(i, j int) bool {
+		return bookings[i].StartTime.Before(bookings[j].StartTime)
+	})
+
+	// Start times only grow, so the bookings in the way of the current period are
+	// kept in active: later bookings join once they start before its buffered end,
+	// and earlier ones leave once they end before its buffered start
+	gap := occupancy.Gap()
+	var active []*Booking
+	next := 0
+
+	for _, open := range mergeOpeningWindows(windows) {
+		start := open.StartTime
+		if start.Before(from) {
+			start = from
+		}
+		if aligned := start.Truncate(slotSearchStep); aligned.Before(start) {
+			start = aligned.Add(slotSearchStep)
+		}
+
+		for ; !start.Add(duration).After(open.EndTime) && !start.Add(duration).After(to); start = start.Add(slotSearchStep) {
+			end := start.Add(duration)
+
+			violations := s.policies.Evaluate(resource.ID, resource.Type, caller.Role, start, end, now)
+			if len(violations) > 0 {
+				if passesLater(violations) {
+					continue
+				}
+				return nil
+			}
+
+			for next < len(bookings) && bookings[next].StartTime.Before(end.Add(gap)) {
+				active = append(active, bookings[next])
+				next++
+			}
+			active = slices.DeleteFunc(active, func(booking *Booking) bool {
+				return !booking.EndTime.After(start.Add(-gap))
+			})
+
+			candidate := &Booking{ResourceID: resource.ID, StartTime: start, EndTime: end, Seats: seats}
+			if !occupancy.Fits(candidate, active) {
+				continue
+			}
+
+			if !yield(newFreeSlot(resource, start, end, occupancy.RemainingSeats(active, start, end))) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// newFreeSlot describes a free period of a resource
+func newFreeSlot(resource *Resource, start, end time.Time, remainingSeats int) FreeSlot {
+	return FreeSlot{
+		ResourceID:     resource.ID,
+		ResourceName:   resource.Name,
+		ResourceType:   resource.Type,
+		Location:       resource.Location,
+		Capacity:       resource.Capacity,
+		StartTime:      start,
+		EndTime:        end,
+		RemainingSeats: remainingSeats,
+	}
+}
+
+// forEachResource calls fn for every resource, at most maxConcurrentLookups at a
+// time, and waits for all of them
+func forEachResource(resources []*Resource, fn func(i int, resource *Resource)) {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, maxConcurrentLookups)
+	for i, resource := range resources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			fn(i, resource)
+		}()
+	}
+	wg.Wait()
+}
+
+// passesLater reports whether a later start time could pass every violated
+// policy rule. The lead time and granularity depend on the start time; the
+// advance limit only gets further away, and the other rules never change.
+func passesLater(violations []PolicyViolation) bool {
+	for _, violation := range violations {
+		if violation.Rule != PolicyRuleMinLeadTime && violation.Rule != PolicyRuleGranularity {
+			return false
+		}
+	}
+	return true
+}
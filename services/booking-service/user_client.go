@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUserNotFound is returned when user-service does not know the user
+	ErrUserNotFound = errors.New("user does not exist")
+	// ErrUserServiceUnavailable is returned when user-service cannot be reached or fails
+	ErrUserServiceUnavailable = errors.New("user service is unavailable")
+)
+
+// User is the part of a user-service user the booking service relies on
+type User struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	IsActive bool   `json:"is_active"`
+}
+
+// UserClient calls the user-service REST API
+type UserClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewUserClient(baseURL string, timeout time.Duration) *UserClient {
+	return &UserClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// GetUser fetches a user by ID. It returns ErrUserNotFound for unknown users and
+// ErrUserServiceUnavailable when the service cannot answer.
+func (c *UserClient) GetUser(id int) (*User, error) {
+	resp, err := c.httpClient.Get(fmt.Sprintf("%s/api/v1/users/%d", c.baseURL, id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUserServiceUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrUserNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: user %d returned %d", ErrUserServiceUnavailable, id, resp.StatusCode)
+	}
+
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("%w: invalid response for user %d: %v", ErrUserServiceUnavailable, id, err)
+	}
+
+	return &user, nil
+}
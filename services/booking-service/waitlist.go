@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	// ErrSlotAvailable is returned when joining the waitlist for a slot that can be booked directly
+	ErrSlotAvailable = errors.New("resource is available for the selected time slot; create a booking instead")
+	// ErrWaitlistEntryClosed is returned when canceling an entry that is no longer queued
+	ErrWaitlistEntryClosed = errors.New("waitlist entry is no longer open")
+	// ErrNoWaitlistOffer is returned when accepting an entry that holds no offer
+	ErrNoWaitlistOffer = errors.New("waitlist entry has no outstanding offer")
+)
+
+// JoinWaitlist queues a user for a resource and time window that is currently taken
+func (s *BookingService) JoinWaitlist(caller Identity, req JoinWaitlistRequest) (*WaitlistEntry, error) {
+	if err := s.validateResource(req.ResourceID, req.StartTime, req.EndTime); err != nil {
+		return nil, err
+	}
+
+	// Promotion books the slot without the caller, so the quota is checked now
+	if err := s.checkQuota(caller, &Booking{UserID: caller.UserID, StartTime: req.StartTime, EndTime: req.EndTime}); err != nil {
+		return nil, err
+	}
+
+	occupancy, err := s.occupancy(req.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	seats, err := seats(req.Seats, occupancy)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := s.conflictingBookings(req.ResourceID, req.StartTime, req.EndTime, occupancy.Buffers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check conflicts: %w", err)
+	}
+
+	if occupancy.RemainingSeats(conflicts, req.StartTime, req.EndTime) >= seats {
+		return nil, ErrSlotAvailable
+	}
+
+	now := time.Now()
+	entry := &WaitlistEntry{
+		UserID:     caller.UserID,
+		ResourceID: req.ResourceID,
+		StartTime:  req.StartTime,
+		EndTime:    req.EndTime,
+		Status:     WaitlistStatusWaiting,
+		Notes:      req.Notes,
+		Seats:      seats,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	if err := s.repository.CreateWaitlistEntry(entry); err != nil {
+		return nil, fmt.Errorf("failed to join waitlist: %w", err)
+	}
+
+	return entry, nil
+}
+
+// GetWaitlistEntry retrieves a waitlist entry by ID
+func (s *BookingService) GetWaitlistEntry(id int) (*WaitlistEntry, error) {
+	entry, err := s.repository.GetWaitlistEntry(id)
+	if err != nil {
+		return nil, fmt.Errorf("waitlist entry not found: %w", err)
+	}
+
+	return entry, nil
+}
+
+// ListWaitlist retrieves waitlist entries in queue order
+func (s *BookingService) ListWaitlist(query ListWaitlistQuery) ([]*WaitlistEntry, error) {
+	// Set defaults
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Size <= 0 {
+		query.Size = 20
+	}
+
+	offset := (query.Page - 1) * query.Size
+
+	entries, err := s.repository.ListWaitlistEntries(query, query.Size, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list waitlist: %w", err)
+	}
+
+	return entries, nil
+}
+
+// CancelWaitlistEntry removes a user from the waitlist. An outstanding offer is
+// released by canceling its pending booking, which promotes the next waiter.
+func (s *BookingService) CancelWaitlistEntry(id int) error {
+	entry, err := s.repository.GetWaitlistEntry(id)
+	if err != nil {
+		return fmt.Errorf("waitlist entry not found: %w", err)
+	}
+
+	if !entry.IsOpen() {
+		return ErrWaitlistEntryClosed
+	}
+
+	fromStatus := entry.Status
+	entry.Status = WaitlistStatusCanceled
+	entry.UpdatedAt = time.Now()
+
+	if err := s.repository.UpdateWaitlistEntry(entry, fromStatus); err != nil {
+		return fmt.Errorf("failed to cancel waitlist entry: %w", err)
+	}
+
+	if fromStatus == WaitlistStatusOffered && entry.BookingID != nil {
+		s.releaseOffer(*entry.BookingID, "")
+	}
+
+	return nil
+}
+
+// AcceptWaitlistOffer confirms the booking offered to a waitlist entry before the
+// offer expires
+func (s *BookingService) AcceptWaitlistOffer(id int) (*Booking, error) {
+	entry, err := s.repository.GetWaitlistEntry(id)
+	if err != nil {
+		return nil, fmt.Errorf("waitlist entry not found: %w", err)
+	}
+
+	if entry.Status != WaitlistStatusOffered || entry.BookingID == nil {
+		return nil, ErrNoWaitlistOffer
+	}
+
+	return s.Confirm(*entry.BookingID)
+}
+
+// ExpireWaitlistOffers releases offers that were not confirmed in time and passes
+// each slot on to the next waiter
+func (s *BookingService) ExpireWaitlistOffers(now time.Time) error {
+	entries, err := s.repository.GetExpiredOffers(now)
+	if err != nil {
+		return fmt.Errorf("failed to get expired waitlist offers: %w", err)
+	}
+
+	for _, entry := range entries {
+		entry.Status = WaitlistStatusExpired
+		entry.UpdatedAt = now
+
+		// Another replica or a concurrent confirmation may have handled it already
+		if err := s.repository.UpdateWaitlistEntry(entry, WaitlistStatusOffered); err != nil {
+			if !errors.Is(err, ErrWaitlistEntryChanged) {
+				log.Printf("Error expiring waitlist entry %d: %v", entry.ID, err)
+			}
+			continue
+		}
+
+		if entry.BookingID != nil {
+			s.releaseOffer(*entry.BookingID, CancellationReasonHoldExpired)
+		}
+	}
+
+	return nil
+}
+
+// RunWaitlistWorker periodically expires waitlist offers until the context is done
+func (s *BookingService) RunWaitlistWorker(ctx context.Context) {
+	runPeriodically(ctx, "Waitlist", s.config.WaitlistSweepInterval, s.ExpireWaitlistOffers)
+}
+
+// promoteWaitlist offers a freed period to the waiters queued for it, oldest first.
+// Each waiter whose whole window now has room gets a PENDING booking that must be
+// confirmed within the configured offer timeout.
+func (s *BookingService) promoteWaitlist(resourceID int, startTime, endTime time.Time) {
+	occupancy, err := s.occupancy(resourceID)
+	if err != nil {
+		log.Printf("Error loading occupancy of resource %d: %v", resourceID, err)
+		return
+	}
+
+	// Waiters blocked only by the freed booking's buffers can be served too
+	gap := occupancy.Gap()
+	entries, err := s.repository.GetWaitingEntries(resourceID, startTime.Add(-gap), endTime.Add(gap))
+	if err != nil {
+		log.Printf("Error loading waitlist for resource %d: %v", resourceID, err)
+		return
+	}
+
+	for _, entry := range entries {
+		now := time.Now()
+
+		// Slots that already started can no longer be offered
+		if !entry.StartTime.After(now) {
+			entry.Status = WaitlistStatusExpired
+			entry.UpdatedAt = now
+			if err := s.repository.UpdateWaitlistEntry(entry, WaitlistStatusWaiting); err != nil &&
+				!errors.Is(err, ErrWaitlistEntryChanged) {
+				log.Printf("Error expiring waitlist entry %d: %v", entry.ID, err)
+			}
+			continue
+		}
+
+		// The offered booking is held exactly as long as the offer
+		expiresAt := now.Add(s.config.WaitlistOfferTimeout)
+		booking := Booking{
+			UserID:     entry.UserID,
+			ResourceID: entry.ResourceID,
+			StartTime:  entry.StartTime,
+			EndTime:    entry.EndTime,
+			Status:     BookingStatusPending,
+			Notes:      entry.Notes,
+			Seats:      entry.Seats,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			ExpiresAt:  &expiresAt,
+		}
+
+		// The waiter's window may still be partly taken; keep them queued
+		if err := s.repository.CreateIfAvailable(&booking, occupancy, BookingEventCreated, BookingEventWaitlistOffered); err != nil {
+			if !errors.Is(err, ErrBookingConflict) {
+				log.Printf("Error offering slot to waitlist entry %d: %v", entry.ID, err)
+			}
+			continue
+		}
+
+		entry.Status = WaitlistStatusOffered
+		entry.BookingID = &booking.ID
+		entry.OfferExpiresAt = &expiresAt
+		entry.UpdatedAt = now
+
+		if err := s.repository.UpdateWaitlistEntry(entry, WaitlistStatusWaiting); err != nil {
+			// The entry was canceled meanwhile; give the slot back
+			booking.Status = BookingStatusCanceled
+			booking.CanceledAt = &now
+			if err := s.repository.UpdateIfStatus(&booking, BookingStatusPending, BookingEventCanceled); err != nil {
+				log.Printf("Error releasing booking %d: %v", booking.ID, err)
+			}
+			continue
+		}
+	}
+}
+
+// releaseOffer cancels a booking offered from the waitlist if it is still pending
+func (s *BookingService) releaseOffer(bookingID int, reason string) {
+	booking, err := s.repository.GetByID(bookingID)
+	if err != nil {
+		log.Printf("Error loading offered booking %d: %v", bookingID, err)
+		return
+	}
+
+	if booking.Status != BookingStatusPending {
+		return
+	}
+
+	if err := s.cancel(booking, reason); err != nil && !errors.Is(err, ErrBookingChanged) {
+		log.Printf("Error releasing offered booking %d: %v", booking.ID, err)
+	}
+}
+
+// closeWaitlistOffer marks the waitlist offer holding a booking as fulfilled or canceled
+func (s *BookingService) closeWaitlistOffer(bookingID int, status WaitlistStatus) {
+	entries, err := s.repository.ListWaitlistEntries(ListWaitlistQuery{
+		BookingID: bookingID,
+		Status:    WaitlistStatusOffered,
+	}, 1, 0)
+	if err != nil {
+		log.Printf("Error loading waitlist offer for booking %d: %v", bookingID, err)
+		return
+	}
+
+	for _, entry := range entries {
+		entry.Status = status
+		entry.UpdatedAt = time.Now()
+		if err := s.repository.UpdateWaitlistEntry(entry, WaitlistStatusOffered); err != nil &&
+			!errors.Is(err, ErrWaitlistEntryChanged) {
+			log.Printf("Error closing waitlist entry %d: %v", entry.ID, err)
+		}
+	}
+}
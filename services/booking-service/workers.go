@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runPeriodically calls task on every tick of interval until the context is done.
+// Errors are logged and the next tick runs as usual.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, task func(now time.Time) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := task(now); err != nil {
+				log.Printf("%s worker error: %v", name, err)
+			}
+		}
+	}
+}
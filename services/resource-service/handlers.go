@@ -30,6 +30,11 @@ func (h *ResourceHandler) CreateResource(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if req.SetupBufferMinutes < 0 || req.TeardownBufferMinutes < 0 {
+		http.Error(w, "Buffers cannot be negative", http.StatusBadRequest)
+		return
+	}
+
 	resource, err := h.resourceService.Create(req)
 	if err != nil {
 		log.Printf("Error creating resource: %v", err)
@@ -130,6 +135,12 @@ func (h *ResourceHandler) UpdateResource(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if (req.SetupBufferMinutes != nil && *req.SetupBufferMinutes < 0) ||
+		(req.TeardownBufferMinutes != nil && *req.TeardownBufferMinutes < 0) {
+		http.Error(w, "Buffers cannot be negative", http.StatusBadRequest)
+		return
+	}
+
 	resource, err := h.resourceService.Update(id, req)
 	if err != nil {
 		log.Printf("Error updating resource: %v", err)
@@ -14,8 +14,13 @@ type Resource struct {
 	Location    string                 `json:"location" db:"location"`
 	Properties  map[string]interface{} `json:"properties" db:"properties"` // Flexible properties (JSON)
 	IsActive    bool                   `json:"is_active" db:"is_active"`
-	CreatedAt   time.Time              `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time              `json:"updated_at" db:"updated_at"`
+	// Shared resources (coworking areas, labs) accept overlapping bookings up to Capacity seats
+	Shared bool `json:"shared" db:"shared"`
+	// Setup and teardown time kept free before and after every booking
+	SetupBufferMinutes    int       `json:"setup_buffer_minutes" db:"setup_buffer_minutes"`
+	TeardownBufferMinutes int       `json:"teardown_buffer_minutes" db:"teardown_buffer_minutes"`
+	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // AvailabilitySlot represents time slots when a resource is available
@@ -47,6 +52,10 @@ type CreateResourceRequest struct {
 	Capacity    int                    `json:"capacity" validate:"required,min=1"`
 	Location    string                 `json:"location" validate:"required,max=200"`
 	Properties  map[string]interface{} `json:"properties,omitempty"`
+	Shared      bool                   `json:"shared,omitempty"`
+
+	SetupBufferMinutes    int `json:"setup_buffer_minutes,omitempty" validate:"min=0"`
+	TeardownBufferMinutes int `json:"teardown_buffer_minutes,omitempty" validate:"min=0"`
 }
 
 // UpdateResourceRequest represents the request to update a resource
@@ -58,6 +67,10 @@ type UpdateResourceRequest struct {
 	Location    *string                `json:"location,omitempty" validate:"omitempty,max=200"`
 	Properties  map[string]interface{} `json:"properties,omitempty"`
 	IsActive    *bool                  `json:"is_active,omitempty"`
+	Shared      *bool                  `json:"shared,omitempty"`
+
+	SetupBufferMinutes    *int `json:"setup_buffer_minutes,omitempty" validate:"omitempty,min=0"`
+	TeardownBufferMinutes *int `json:"teardown_buffer_minutes,omitempty" validate:"omitempty,min=0"`
 }
 
 // CreateAvailabilitySlotRequest represents the request to create availability slot
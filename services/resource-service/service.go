@@ -25,8 +25,12 @@ func (s *ResourceService) Create(req CreateResourceRequest) (*Resource, error) {
 		Location:    req.Location,
 		Properties:  req.Properties,
 		IsActive:    true,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Shared:      req.Shared,
+
+		SetupBufferMinutes:    req.SetupBufferMinutes,
+		TeardownBufferMinutes: req.TeardownBufferMinutes,
+		CreatedAt:             time.Now(),
+		UpdatedAt:             time.Now(),
 	}
 
 	if err := s.repository.Create(&resource); err != nil {
@@ -95,6 +99,15 @@ func (s *ResourceService) Update(id int, req UpdateResourceRequest) (*Resource,
 	if req.IsActive != nil {
 		resource.IsActive = *req.IsActive
 	}
+	if req.Shared != nil {
+		resource.Shared = *req.Shared
+	}
+	if req.SetupBufferMinutes != nil {
+		resource.SetupBufferMinutes = *req.SetupBufferMinutes
+	}
+	if req.TeardownBufferMinutes != nil {
+		resource.TeardownBufferMinutes = *req.TeardownBufferMinutes
+	}
 
 	resource.UpdatedAt = time.Now()
 
@@ -6,3 +6,5 @@ require (
 	github.com/gorilla/mux v1.8.0
 	golang.org/x/crypto v0.17.0
 )
+
+require github.com/golang-jwt/jwt/v5 v5.2.2
@@ -15,10 +15,15 @@ type UserHandler struct {
 }
 
 // NewUserHandler creates a new UserHandler instance
-func NewUserHandler() *UserHandler {
-	return &UserHandler{
-		userService: NewUserService(),
+func NewUserHandler() (*UserHandler, error) {
+	userService, err := NewUserService()
+	if err != nil {
+		return nil, err
 	}
+
+	return &UserHandler{
+		userService: userService,
+	}, nil
 }
 
 // CreateUser handles POST /api/v1/users
@@ -134,7 +139,7 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	response := LoginResponse{
 		Token:     token,
-		ExpiresIn: 3600, // 1 hour
+		ExpiresIn: int(TokenTTL.Seconds()),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -161,7 +166,7 @@ func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 
 	response := LoginResponse{
 		Token:     token,
-		ExpiresIn: 3600,
+		ExpiresIn: int(TokenTTL.Seconds()),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -32,7 +32,11 @@ func main() {
 	r := mux.NewRouter()
 
 	// Initialize handlers
-	userHandler := NewUserHandler()
+	userHandler, err := NewUserHandler()
+	if err != nil {
+		log.Printf("User Service failed to initialize: %v", err)
+		os.Exit(1)
+	}
 
 	// Setup routes
 	setupRoutes(r, userHandler)
@@ -2,6 +2,8 @@ package main
 
 import (
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // User represents the user entity
@@ -21,14 +23,14 @@ type CreateUserRequest struct {
 	Name     string `json:"name" validate:"required,min=2,max=100"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=8"`
-	Role     string `json:"role" validate:"oneof=admin user"`
+	Role     string `json:"role" validate:"oneof=admin manager user"`
 }
 
 // UpdateUserRequest represents the request payload for updating a user
 type UpdateUserRequest struct {
 	Name     *string `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
 	Email    *string `json:"email,omitempty" validate:"omitempty,email"`
-	Role     *string `json:"role,omitempty" validate:"omitempty,oneof=admin user"`
+	Role     *string `json:"role,omitempty" validate:"omitempty,oneof=admin manager user"`
 	IsActive *bool   `json:"is_active,omitempty"`
 }
 
@@ -44,6 +46,13 @@ type LoginResponse struct {
 	ExpiresIn int    `json:"expires_in"`
 }
 
+// TokenClaims are the claims of an access token. The subject is the user ID;
+// other services read the role from it for authorization.
+type TokenClaims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
 // RefreshTokenRequest represents the refresh token request
 type RefreshTokenRequest struct {
 	Token string `json:"token" validate:"required"`
@@ -3,21 +3,37 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// TokenTTL is how long an access token is valid
+const TokenTTL = time.Hour
+
+// ErrMissingJWTSecret is returned when no secret to sign tokens is configured
+var ErrMissingJWTSecret = errors.New("JWT_SECRET is not set")
+
 type UserService struct {
 	repository UserRepository
 	jwtSecret  string
 }
 
-func NewUserService() *UserService {
+// NewUserService returns ErrMissingJWTSecret when JWT_SECRET is not set, so
+// tokens are never signed with a well-known key
+func NewUserService() (*UserService, error) {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return nil, ErrMissingJWTSecret
+	}
+
 	return &UserService{
 		repository: NewUserRepository(),
-		jwtSecret:  "your-secret-key", // TODO: Move to environment variable
-	}
+		jwtSecret:  jwtSecret,
+	}, nil
 }
 
 // Create creates a new user
@@ -124,37 +140,59 @@ func (s *UserService) Login(email, password string) (string, error) {
 		return "", errors.New("invalid credentials")
 	}
 
-	// Generate JWT token (stub - implement actual JWT generation)
-	token := fmt.Sprintf("jwt_token_for_user_%d_%d", user.ID, time.Now().Unix())
-
-	return token, nil
+	return s.issueToken(user)
 }
 
-// RefreshToken refreshes a JWT token
+// RefreshToken exchanges a valid token for a new one with a fresh expiry. The
+// role is read again so that role changes take effect on refresh.
 func (s *UserService) RefreshToken(token string) (string, error) {
-	// TODO: Implement actual JWT validation and refresh
-	// This is a stub implementation
+	userID, err := s.ValidateToken(token)
+	if err != nil {
+		return "", err
+	}
 
-	// Validate existing token
-	if token == "" {
+	user, err := s.repository.GetByID(userID)
+	if err != nil || !user.IsActive {
 		return "", errors.New("invalid token")
 	}
 
-	// Generate new token (stub)
-	newToken := fmt.Sprintf("refreshed_%s_%d", token, time.Now().Unix())
-
-	return newToken, nil
+	return s.issueToken(user)
 }
 
 // ValidateToken validates a JWT token and returns user ID
 func (s *UserService) ValidateToken(token string) (int, error) {
-	// TODO: Implement actual JWT validation
-	// This is a stub implementation
+	claims := &TokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(s.jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, errors.New("invalid token")
+	}
 
-	if token == "" {
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
 		return 0, errors.New("invalid token")
 	}
 
-	// Stub: extract user ID from token (in real implementation, parse JWT)
-	return 1, nil
+	return userID, nil
+}
+
+// issueToken signs an HS256 access token carrying the user's ID and role
+func (s *UserService) issueToken(user *User) (string, error) {
+	now := time.Now()
+	claims := TokenClaims{
+		Role: user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(user.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(TokenTTL)),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.jwtSecret))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return token, nil
 }